@@ -1,4 +1,4 @@
-//go:build windows
+//go:build windows || linux
 
 package capture
 
@@ -14,6 +14,7 @@ type Region struct {
 }
 
 // Capture は指定範囲をキャプチャして image.Image を返します。
+// Linux では X11 の画面を（使えれば MIT-SHM 拡張で）読み取ります。
 func Capture(region Region) (image.Image, error) {
 	bounds := image.Rect(region.X, region.Y, region.X+region.Width, region.Y+region.Height)
 	img, err := screenshot.CaptureRect(bounds)
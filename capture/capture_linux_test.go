@@ -0,0 +1,29 @@
+//go:build linux
+
+package capture
+
+import (
+	"image"
+	"testing"
+
+	"github.com/jezek/xgb"
+)
+
+// TestCaptureX11 は X サーバの画面の一部をキャプチャします。X サーバに接続できない環境では飛ばします。
+func TestCaptureX11(t *testing.T) {
+	c, err := xgb.NewConn()
+	if err != nil {
+		t.Skipf("X サーバに接続できません: %v", err)
+	}
+	c.Close()
+	img, err := Capture(Region{X: 1, Y: 2, Width: 8, Height: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := img.Bounds().Size(); got != image.Pt(8, 4) {
+		t.Errorf("captured size = %v, want 8x4", got)
+	}
+	if dpi, ok := DisplayDPI(); ok && dpi <= 0 {
+		t.Errorf("DisplayDPI = %v", dpi)
+	}
+}
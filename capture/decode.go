@@ -0,0 +1,114 @@
+package capture
+
+import (
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/draw"
+	"image/gif"
+	"io"
+
+	"golang.org/x/image/tiff"
+)
+
+// decodeTIFFPages はマルチページ TIFF のすべてのページを順に返します。
+// x/image/tiff は先頭の IFD しか読まないため、ヘッダの IFD オフセットを各ページのものに差し替えて読ませます。
+func decodeTIFFPages(data []byte) ([]image.Image, error) {
+	if len(data) < 8 {
+		return nil, errors.New("TIFF のヘッダが短すぎます")
+	}
+	var order binary.ByteOrder
+	switch string(data[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, errors.New("TIFF ではありません")
+	}
+	var pages []image.Image
+	seen := map[uint32]bool{}
+	for off := order.Uint32(data[4:8]); off != 0; {
+		if seen[off] || int(off)+2 > len(data) {
+			return nil, errors.New("TIFF の IFD が壊れています")
+		}
+		seen[off] = true
+		r := &patchedReader{data: data}
+		copy(r.head[:], data[:8])
+		order.PutUint32(r.head[4:], off)
+		img, err := tiff.Decode(r)
+		if err != nil {
+			return nil, err
+		}
+		pages = append(pages, img)
+
+		n := int(order.Uint16(data[off:]))
+		next := int(off) + 2 + n*12
+		if next+4 > len(data) {
+			break
+		}
+		off = order.Uint32(data[next:])
+	}
+	return pages, nil
+}
+
+// patchedReader は先頭 8 バイトを head に差し替えて data を読ませる io.ReaderAt です。
+type patchedReader struct {
+	data []byte
+	head [8]byte
+	pos  int64
+}
+
+func (r *patchedReader) ReadAt(p []byte, off int64) (int, error) {
+	if off >= int64(len(r.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, r.data[off:])
+	if off < int64(len(r.head)) {
+		copy(p, r.head[off:])
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (r *patchedReader) Read(p []byte) (int, error) {
+	n, err := r.ReadAt(p, r.pos)
+	r.pos += int64(n)
+	return n, err
+}
+
+// decodeGIFFrames はアニメーション GIF の各フレームを、破棄方法に従って重ねた完成画像として返します。
+func decodeGIFFrames(r io.Reader) ([]image.Image, error) {
+	g, err := gif.DecodeAll(r)
+	if err != nil {
+		return nil, err
+	}
+	bounds := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	canvas := image.NewRGBA(bounds)
+	frames := make([]image.Image, 0, len(g.Image))
+	for i, p := range g.Image {
+		var prev *image.RGBA
+		disposal := byte(0)
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+		if disposal == gif.DisposalPrevious {
+			prev = image.NewRGBA(bounds)
+			draw.Draw(prev, bounds, canvas, image.Point{}, draw.Src)
+		}
+		draw.Draw(canvas, p.Bounds(), p, p.Bounds().Min, draw.Over)
+		frame := image.NewRGBA(bounds)
+		draw.Draw(frame, bounds, canvas, image.Point{}, draw.Src)
+		frames = append(frames, frame)
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, p.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = prev
+		}
+	}
+	return frames, nil
+}
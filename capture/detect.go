@@ -0,0 +1,175 @@
+package capture
+
+import (
+	"image"
+	"image/color"
+
+	"AutoScreenShot/imgutil"
+)
+
+// DetectOptions は DetectContent の設定です。
+type DetectOptions struct {
+	Threshold int     // 画素を変化とみなす RGB の差（0 なら 24）
+	Cell      int     // 変化をまとめるマス目の大きさ（0 なら 8 ピクセル）
+	Gap       int     // この数までのマス目の隙間は同じ領域とみなす（0 なら 4。行間や段落の間をつなぐ）
+	Paper     float64 // 外側の行・列の画素のうち、この割合以上がページの色なら広げる（0 なら 0.5）
+	Uniform   float64 // 行・列の画素のうち、この割合以上が同じ色なら一様とみなす（0 なら 0.98）
+}
+
+// DetectContent はページ送りの前後の2枚の画像を比べ、ページの内容が表示されている範囲を推定します。
+// 変化した部分のうち最も大きなまとまりを選び、その外側にページ（まとまりの縁と同じ色が大半の行・列）が続く限り広げてから、
+// 周囲に残った一様な色の帯（ビューアの背景など）を削ります。変化が無ければ false を返します。
+// 返す矩形は after の座標系です。
+func DetectContent(before, after image.Image, opt DetectOptions) (image.Rectangle, bool) {
+	if opt.Threshold <= 0 {
+		opt.Threshold = 24
+	}
+	if opt.Cell <= 0 {
+		opt.Cell = 8
+	}
+	if opt.Gap <= 0 {
+		opt.Gap = 4
+	}
+	if opt.Paper <= 0 {
+		opt.Paper = 0.5
+	}
+	if opt.Uniform <= 0 {
+		opt.Uniform = 0.98
+	}
+	bounds := before.Bounds().Intersect(after.Bounds())
+	if bounds.Empty() {
+		return image.Rectangle{}, false
+	}
+	box, ok := changedBox(before, after, bounds, opt)
+	if !ok {
+		return image.Rectangle{}, false
+	}
+	bg := imgutil.EdgeColor(after, box)
+	box = growMargin(after, box, bounds, bg, opt)
+	box = trimUniform(after, box, bg, opt)
+	return box, !box.Empty()
+}
+
+// changedBox は変化した画素をマス目に集計し、隙間を埋めてつながった最大のまとまりの外接矩形を返します。
+func changedBox(before, after image.Image, bounds image.Rectangle, opt DetectOptions) (image.Rectangle, bool) {
+	cw := (bounds.Dx() + opt.Cell - 1) / opt.Cell
+	ch := (bounds.Dy() + opt.Cell - 1) / opt.Cell
+	counts := make([]int, cw*ch)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if imgutil.ColorDiff(before.At(x, y), after.At(x, y)) > opt.Threshold {
+				counts[((y-bounds.Min.Y)/opt.Cell)*cw+(x-bounds.Min.X)/opt.Cell]++
+			}
+		}
+	}
+
+	// 隣り合う（Gap マス以内の）変化したマス目を1つのまとまりとし、変化した画素の数が最も多いものを選ぶ
+	seen := make([]bool, len(counts))
+	var best image.Rectangle
+	bestCount := 0
+	for i, c := range counts {
+		if c == 0 || seen[i] {
+			continue
+		}
+		r := image.Rect(i%cw, i/cw, i%cw+1, i/cw+1)
+		total := 0
+		stack := []int{i}
+		seen[i] = true
+		for len(stack) > 0 {
+			j := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			x, y := j%cw, j/cw
+			total += counts[j]
+			r = r.Union(image.Rect(x, y, x+1, y+1))
+			for ny := max(0, y-opt.Gap); ny <= min(ch-1, y+opt.Gap); ny++ {
+				for nx := max(0, x-opt.Gap); nx <= min(cw-1, x+opt.Gap); nx++ {
+					k := ny*cw + nx
+					if counts[k] > 0 && !seen[k] {
+						seen[k] = true
+						stack = append(stack, k)
+					}
+				}
+			}
+		}
+		if total > bestCount {
+			best, bestCount = r, total
+		}
+	}
+	if bestCount == 0 {
+		return image.Rectangle{}, false
+	}
+	r := image.Rect(best.Min.X*opt.Cell, best.Min.Y*opt.Cell, best.Max.X*opt.Cell, best.Max.Y*opt.Cell)
+	return r.Add(bounds.Min).Intersect(bounds), true
+}
+
+// growMargin は r の各辺を、外側の行・列がページの一部（bg の色が Paper の割合以上）である限り外へ広げます。
+// 変化しなかった見出しや本文の行も含めて、ページの端（ビューアの背景など）まで広がります。
+// 見出しの帯のように濃い行が続いても、Gap マス以内でページの色に戻ればその先まで広げます。
+func growMargin(img image.Image, r, bounds image.Rectangle, bg color.Color, opt DetectOptions) image.Rectangle {
+	paper := func(line image.Rectangle) bool {
+		return lineRatio(img, line, bg, opt.Threshold) >= opt.Paper
+	}
+	// grow は外側へ 1 つずつ進み、ページの一部である最も遠い行・列までの距離を返します。
+	grow := func(limit int, line func(d int) image.Rectangle) int {
+		last := 0
+		for d := 1; d <= limit && d-last <= opt.Gap*opt.Cell; d++ {
+			if paper(line(d)) {
+				last = d
+			}
+		}
+		return last
+	}
+	top := grow(r.Min.Y-bounds.Min.Y, func(d int) image.Rectangle {
+		return image.Rect(r.Min.X, r.Min.Y-d, r.Max.X, r.Min.Y-d+1)
+	})
+	bottom := grow(bounds.Max.Y-r.Max.Y, func(d int) image.Rectangle {
+		return image.Rect(r.Min.X, r.Max.Y+d-1, r.Max.X, r.Max.Y+d)
+	})
+	r.Min.Y, r.Max.Y = r.Min.Y-top, r.Max.Y+bottom
+	left := grow(r.Min.X-bounds.Min.X, func(d int) image.Rectangle {
+		return image.Rect(r.Min.X-d, r.Min.Y, r.Min.X-d+1, r.Max.Y)
+	})
+	right := grow(bounds.Max.X-r.Max.X, func(d int) image.Rectangle {
+		return image.Rect(r.Max.X+d-1, r.Min.Y, r.Max.X+d, r.Max.Y)
+	})
+	r.Min.X, r.Max.X = r.Min.X-left, r.Max.X+right
+	return r
+}
+
+// trimUniform は r の縁に残った、bg と違う色の一様な帯（ビューアの背景や枠）を削ります。
+func trimUniform(img image.Image, r image.Rectangle, bg color.Color, opt DetectOptions) image.Rectangle {
+	uniform := func(line image.Rectangle) bool {
+		c := img.At(line.Min.X, line.Min.Y)
+		return imgutil.ColorDiff(c, bg) > opt.Threshold && lineRatio(img, line, c, opt.Threshold) >= opt.Uniform
+	}
+	for r.Dy() > 1 && uniform(image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+1)) {
+		r.Min.Y++
+	}
+	for r.Dy() > 1 && uniform(image.Rect(r.Min.X, r.Max.Y-1, r.Max.X, r.Max.Y)) {
+		r.Max.Y--
+	}
+	for r.Dx() > 1 && uniform(image.Rect(r.Min.X, r.Min.Y, r.Min.X+1, r.Max.Y)) {
+		r.Min.X++
+	}
+	for r.Dx() > 1 && uniform(image.Rect(r.Max.X-1, r.Min.Y, r.Max.X, r.Max.Y)) {
+		r.Max.X--
+	}
+	return r
+}
+
+// lineRatio は line の画素のうち c に近い色の割合を返します。
+func lineRatio(img image.Image, line image.Rectangle, c color.Color, tol int) float64 {
+	n, same := 0, 0
+	for y := line.Min.Y; y < line.Max.Y; y++ {
+		for x := line.Min.X; x < line.Max.X; x++ {
+			n++
+			if imgutil.ColorDiff(img.At(x, y), c) <= tol {
+				same++
+			}
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return float64(same) / float64(n)
+}
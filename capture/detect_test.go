@@ -0,0 +1,55 @@
+package capture
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+var (
+	viewerBG = color.RGBA{80, 80, 80, 255}
+	toolbar  = color.RGBA{40, 40, 40, 255}
+	ink      = color.RGBA{0, 0, 0, 255}
+)
+
+// viewerScreen はビューアの画面（上にツールバー、中央に白いページ）を描き、ページの中に page 番目の本文を書きます。
+// clock が 0 以上なら、ツールバーの時計の部分にその値の模様を描きます。
+func viewerScreen(page image.Rectangle, n, clock int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 400, 300))
+	draw.Draw(img, img.Bounds(), image.NewUniform(viewerBG), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(0, 0, 400, 30), image.NewUniform(toolbar), image.Point{}, draw.Src)
+	draw.Draw(img, page, image.NewUniform(color.White), image.Point{}, draw.Src)
+	// 本文の行（ページごとに行の長さが違う）
+	for i := 0; i < 8; i++ {
+		y := page.Min.Y + 40 + i*16
+		w := 40 + (i*37+n*53)%(page.Dx()-80)
+		draw.Draw(img, image.Rect(page.Min.X+20, y, page.Min.X+20+w, y+8), image.NewUniform(ink), image.Point{}, draw.Src)
+	}
+	if clock >= 0 {
+		draw.Draw(img, image.Rect(360, 10, 360+clock%20+5, 18), image.NewUniform(color.White), image.Point{}, draw.Src)
+	}
+	return img
+}
+
+func TestDetectContent(t *testing.T) {
+	page := image.Rect(100, 50, 300, 280)
+	tests := []struct {
+		name          string
+		before, after image.Image
+		want          image.Rectangle
+		ok            bool
+	}{
+		{"ページの範囲", viewerScreen(page, 1, -1), viewerScreen(page, 2, -1), page, true},
+		{"時計の変化は選ばない", viewerScreen(page, 1, 3), viewerScreen(page, 2, 11), page, true},
+		{"変化が無い", viewerScreen(page, 1, -1), viewerScreen(page, 1, -1), image.Rectangle{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := DetectContent(tt.before, tt.after, DetectOptions{})
+			if ok != tt.ok || got != tt.want {
+				t.Errorf("DetectContent = %v, %v, want %v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+//go:build linux
+
+package capture
+
+import (
+	"github.com/jezek/xgb"
+	"github.com/jezek/xgb/xproto"
+)
+
+// DisplayDPI は X11 の既定の画面の DPI（X サーバーが報告する画面の幅の mm から求めたもの）を返します。
+func DisplayDPI() (float64, bool) {
+	c, err := xgb.NewConn()
+	if err != nil {
+		return 0, false
+	}
+	defer c.Close()
+	s := xproto.Setup(c).DefaultScreen(c)
+	if s.WidthInMillimeters == 0 {
+		return 0, false
+	}
+	return float64(s.WidthInPixels) * 25.4 / float64(s.WidthInMillimeters), true
+}
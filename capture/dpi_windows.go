@@ -0,0 +1,17 @@
+//go:build windows
+
+package capture
+
+import "github.com/lxn/win"
+
+// DisplayDPI は画面の DPI（表示スケールが 100% なら 96、150% なら 144）を返します。
+// プロセスを DPI 対応にしてから呼んでください。対応していないと常に 96 になります。
+func DisplayDPI() (float64, bool) {
+	hdc := win.GetDC(0)
+	if hdc == 0 {
+		return 0, false
+	}
+	defer win.ReleaseDC(0, hdc)
+	dpi := win.GetDeviceCaps(hdc, win.LOGPIXELSX)
+	return float64(dpi), dpi > 0
+}
@@ -0,0 +1,163 @@
+package capture
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"AutoScreenShot/output"
+)
+
+// Replay は保存済みの画像を画面の代わりに返すキャプチャ元です。キー送信（Send）のたびに次のフレームへ進み、
+// 最後のフレームのあとは同じフレームを返し続けます。表示の無い環境で一連の処理を試したり、
+// 以前のセッションを別の設定で作り直したりするのに使います。
+type Replay struct {
+	Frames []image.Image
+
+	// Stuck はページ番号（1 から）ごとに、そのページでキーを無視する回数です。ページがめくれない状態を再現します。
+	Stuck map[int]int
+	// Blank はページ番号（1 から）ごとに、そのページを表示した直後に空白（白一色）を返すキャプチャ回数です。
+	// 読み込み中の白い画面を再現します。
+	Blank map[int]int
+
+	mu        sync.Mutex
+	pos       int // 表示中のフレーム（0 から）
+	stuck     int // 表示中のページで無視したキーの回数
+	blankLeft int // 表示中のページで残っている空白のキャプチャ回数
+	started   bool
+}
+
+// replayExts は Replay がフォルダから読み込む画像の拡張子です。
+var replayExts = map[string]bool{".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".tif": true, ".tiff": true}
+
+// LoadReplay は path の画像を読み込んだ Replay を返します。
+// path はフォルダ（画像を PDF と同じページ順に読む。中のフォルダは読まない）、マルチページ TIFF、アニメーション GIF、または1枚の画像です。
+func LoadReplay(path string) (*Replay, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	if info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if !e.IsDir() && replayExts[strings.ToLower(filepath.Ext(e.Name()))] {
+				paths = append(paths, filepath.Join(path, e.Name()))
+			}
+		}
+		sort.Slice(paths, func(i, j int) bool { return output.PageLess(filepath.Base(paths[i]), filepath.Base(paths[j])) })
+	} else {
+		paths = []string{path}
+	}
+	r := &Replay{}
+	for _, p := range paths {
+		frames, err := loadFrames(p)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p, err)
+		}
+		r.Frames = append(r.Frames, frames...)
+	}
+	if len(r.Frames) == 0 {
+		return nil, fmt.Errorf("%s に画像がありません", path)
+	}
+	return r, nil
+}
+
+// loadFrames は1つのファイルを読み込みます。TIFF と GIF はすべてのページ（フレーム）を返します。
+func loadFrames(path string) ([]image.Image, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".tif", ".tiff":
+		return decodeTIFFPages(data)
+	case ".gif":
+		return decodeGIFFrames(bytes.NewReader(data))
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return []image.Image{img}, nil
+}
+
+// Len はフレーム数を返します。
+func (r *Replay) Len() int { return len(r.Frames) }
+
+// Bounds は最初のフレームの大きさを返します。
+func (r *Replay) Bounds() image.Rectangle {
+	if len(r.Frames) == 0 {
+		return image.Rectangle{}
+	}
+	return r.Frames[0].Bounds()
+}
+
+// Capture は表示中のフレームを返します。
+func (r *Replay) Capture() (image.Image, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.Frames) == 0 {
+		return nil, fmt.Errorf("フレームがありません")
+	}
+	if !r.started {
+		r.show(0)
+	}
+	img := r.Frames[r.pos]
+	if r.blankLeft > 0 {
+		r.blankLeft--
+		return blankLike(img), nil
+	}
+	return img, nil
+}
+
+// Send はキー操作を受け取り、次のフレームへ進みます。キーの種類は区別しません。
+func (r *Replay) Send(keyOperation string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.started {
+		r.show(0)
+	}
+	if r.stuck < r.Stuck[r.pos+1] {
+		r.stuck++
+		return nil
+	}
+	if r.pos+1 < len(r.Frames) {
+		r.show(r.pos + 1)
+	}
+	return nil
+}
+
+func (r *Replay) show(pos int) {
+	r.started = true
+	r.pos = pos
+	r.stuck = 0
+	r.blankLeft = r.Blank[pos+1]
+}
+
+// Position は表示中のページ番号（1 から）を返します。
+func (r *Replay) Position() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.pos + 1
+}
+
+// blankLike は img と同じ大きさの白一色の画像を返します。
+func blankLike(img image.Image) image.Image {
+	b := img.Bounds()
+	blank := image.NewGray(b)
+	for i := range blank.Pix {
+		blank.Pix[i] = 0xff
+	}
+	return blank
+}
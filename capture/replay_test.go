@@ -0,0 +1,124 @@
+package capture
+
+import (
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/gif"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/image/tiff"
+)
+
+// frame は値 v で塗った 4x4 の画像を返します（どのフレームか見分ける印）。
+func frame(v uint8) image.Image {
+	img := image.NewGray(image.Rect(0, 0, 4, 4))
+	for i := range img.Pix {
+		img.Pix[i] = v
+	}
+	return img
+}
+
+// shown は img の左上の画素の値を返します。
+func shown(img image.Image) uint8 {
+	return color.GrayModel.Convert(img.At(0, 0)).(color.Gray).Y
+}
+
+func TestReplay(t *testing.T) {
+	tests := []struct {
+		name  string
+		stuck map[int]int
+		blank map[int]int
+		ops   string  // c: キャプチャ, s: キー送信
+		want  []uint8 // キャプチャした画像の印（空白は 255）
+	}{
+		{"順に進み最後で止まる", nil, nil, "cscscscsc", []uint8{10, 20, 30, 30, 30}},
+		{"キーを無視するページ", map[int]int{2: 2}, nil, "cscscscsc", []uint8{10, 20, 20, 20, 30}},
+		{"表示直後の空白", nil, map[int]int{2: 2}, "cscccsc", []uint8{10, 255, 255, 20, 30}},
+		{"最初のページの空白", nil, map[int]int{1: 1}, "ccsc", []uint8{255, 10, 20}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Replay{Frames: []image.Image{frame(10), frame(20), frame(30)}, Stuck: tt.stuck, Blank: tt.blank}
+			var got []uint8
+			for _, op := range tt.ops {
+				if op == 's' {
+					if err := r.Send("Enter"); err != nil {
+						t.Fatal(err)
+					}
+					continue
+				}
+				img, err := r.Capture()
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, shown(img))
+			}
+			if string(got) != string(tt.want) {
+				t.Errorf("captured %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadReplay(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, encode func(f *os.File) error) {
+		f, err := os.Create(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if err := encode(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 分割したページは番号の数値順（_2 が _10 より前）、形式の違う名前はその後ろ
+	write("screenshot_00001_10.tif", func(f *os.File) error { return tiff.Encode(f, frame(20), nil) })
+	write("screenshot_00001_2.png", func(f *os.File) error { return png.Encode(f, frame(10)) })
+	write("cover.gif", func(f *os.File) error {
+		anim := &gif.GIF{}
+		for _, v := range []uint8{30, 40} {
+			p := image.NewPaletted(image.Rect(0, 0, 4, 4), palette.Plan9)
+			for i := range p.Pix {
+				p.Pix[i] = uint8(p.Palette.Index(color.Gray{v}))
+			}
+			anim.Image = append(anim.Image, p)
+			anim.Delay = append(anim.Delay, 0)
+		}
+		return gif.EncodeAll(f, anim)
+	})
+	write("memo.txt", func(f *os.File) error { _, err := f.WriteString("x"); return err })
+	// 書き出したマルチページ TIFF（tiff フォルダ）はフレームにしない
+	if err := os.Mkdir(filepath.Join(dir, "tiff"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	write(filepath.Join("tiff", "本.tiff"), func(f *os.File) error { return tiff.Encode(f, frame(99), nil) })
+
+	r, err := LoadReplay(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Len() != 4 {
+		t.Fatalf("Len = %d, want 4", r.Len())
+	}
+	// screenshot_00001_2.png, screenshot_00001_10.tif, cover.gif の2フレーム
+	for i, want := range []uint8{10, 20, 30, 40} {
+		if got := shown(r.Frames[i]); absDiff(got, want) > 8 {
+			t.Errorf("frame %d = %d, want about %d", i, got, want)
+		}
+	}
+	if _, err := LoadReplay(t.TempDir()); err == nil {
+		t.Error("LoadReplay of an empty folder succeeded")
+	}
+}
+
+func absDiff(a, b uint8) uint8 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
@@ -0,0 +1,84 @@
+package capture
+
+import (
+	"context"
+	"image"
+	"time"
+)
+
+// StableOptions は画面が落ち着くまで待つときの設定です。
+type StableOptions struct {
+	Frames   int           // 連続で一致したら安定とみなすフレーム数（2 未満なら 2）
+	MinWait  time.Duration // キャプチャを始めるまでの最小待機時間
+	MaxWait  time.Duration // 安定しなくても諦めるまでの最大待機時間（0 なら 10 秒）
+	Interval time.Duration // キャプチャの間隔（0 なら 50ms）
+}
+
+// StableFrame は WaitStable の結果です。
+type StableFrame struct {
+	Image  image.Image
+	Hash   []byte        // Image のハッシュ（WaitStable に渡した sum の値）
+	Settle time.Duration // 呼び出しから安定（または打ち切り）までの時間
+	Stable bool          // false なら MaxWait で打ち切った
+}
+
+// WaitStable は grab で繰り返しキャプチャし、sum で求めたハッシュを same で比較して、
+// Frames 枚連続で一致したら最後のフレームを返します。
+// MaxWait を過ぎても安定しなければ、その時点の最新フレームを Stable=false で返します。
+// 待っている間に ctx がキャンセルされたら、そのエラーを返します。
+func WaitStable(ctx context.Context, grab func() (image.Image, error), sum func(image.Image) ([]byte, error), same func(a, b []byte) bool, opt StableOptions) (StableFrame, error) {
+	if opt.Frames < 2 {
+		opt.Frames = 2
+	}
+	if opt.Interval <= 0 {
+		opt.Interval = 50 * time.Millisecond
+	}
+	if opt.MaxWait <= 0 {
+		opt.MaxWait = 10 * time.Second
+	}
+	start := time.Now()
+	if err := Sleep(ctx, opt.MinWait); err != nil {
+		return StableFrame{}, err
+	}
+
+	var last StableFrame
+	streak := 0
+	for {
+		img, err := grab()
+		if err != nil {
+			return StableFrame{}, err
+		}
+		h, err := sum(img)
+		if err != nil {
+			return StableFrame{}, err
+		}
+		if same(last.Hash, h) {
+			streak++
+		} else {
+			streak = 1
+		}
+		last = StableFrame{Image: img, Hash: h, Settle: time.Since(start)}
+		if streak >= opt.Frames {
+			last.Stable = true
+			return last, nil
+		}
+		if time.Since(start) >= opt.MaxWait {
+			return last, nil
+		}
+		if err := Sleep(ctx, opt.Interval); err != nil {
+			return StableFrame{}, err
+		}
+	}
+}
+
+// Sleep は d だけ待ちます。途中で ctx がキャンセルされたらそのエラーを返します。
+func Sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,59 @@
+package capture
+
+import (
+	"context"
+	"errors"
+	"image"
+	"testing"
+	"time"
+)
+
+func TestWaitStable(t *testing.T) {
+	tests := []struct {
+		name    string
+		hashes  []byte // キャプチャごとのハッシュ（最後の値を繰り返す）
+		opt     StableOptions
+		cancel  bool // 呼び出してから少しあとにキャンセルする
+		stable  bool
+		hash    byte
+		wantErr error
+	}{
+		{"2枚連続で安定", []byte{1, 2, 2}, StableOptions{Interval: time.Millisecond}, false, true, 2, nil},
+		{"3枚連続で安定", []byte{1, 1, 2, 2, 2}, StableOptions{Frames: 3, Interval: time.Millisecond}, false, true, 2, nil},
+		{"安定せず打ち切り", []byte{1, 2, 3, 4, 5, 6, 7, 8, 9}, StableOptions{Interval: time.Millisecond, MaxWait: 5 * time.Millisecond}, false, false, 0, nil},
+		{"待っている間にキャンセル", []byte{1, 2, 3, 4, 5, 6, 7, 8, 9}, StableOptions{Interval: time.Hour, MaxWait: time.Hour}, true, false, 0, context.Canceled},
+		{"最初の待機中にキャンセル", []byte{1}, StableOptions{MinWait: time.Hour}, true, false, 0, context.Canceled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancel {
+				time.AfterFunc(10*time.Millisecond, cancel)
+			}
+			n := 0
+			grab := func() (image.Image, error) {
+				n++
+				return image.NewGray(image.Rect(0, 0, 1, 1)), nil
+			}
+			sum := func(image.Image) ([]byte, error) {
+				i := min(n, len(tt.hashes)) - 1
+				return []byte{tt.hashes[i]}, nil
+			}
+			same := func(a, b []byte) bool { return a != nil && b != nil && a[0] == b[0] }
+			f, err := WaitStable(ctx, grab, sum, same, tt.opt)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if f.Stable != tt.stable {
+				t.Errorf("Stable = %v, want %v", f.Stable, tt.stable)
+			}
+			if tt.stable && f.Hash[0] != tt.hash {
+				t.Errorf("Hash = %d, want %d", f.Hash[0], tt.hash)
+			}
+		})
+	}
+}
@@ -0,0 +1,252 @@
+package chapter
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FileName は出力フォルダに置く、章の区切りとページ番号の表示を書いたファイルの名前です。
+// 実行中にホットキーや規則で付けた区切りもこのファイルに追記するので、後から編集して PDF を作り直せます。
+const FileName = "chapters.txt"
+
+// Mark は章の区切り（PDF のしおり）です。
+type Mark struct {
+	Page  int    // 章が始まるページの番号（screenshot_00012.jpg の 12。連結したページは page_00003.jpg の 3）
+	Title string // しおりの名前
+	Level int    // 階層（0 が最上位）
+}
+
+// ページ番号の表示の種類（PDF の /PageLabels の /S）
+const (
+	StyleNone       = ""  // 番号を付けない（Prefix だけ）
+	StyleDecimal    = "D" // 1, 2, 3
+	StyleRomanLower = "r" // i, ii, iii
+	StyleRomanUpper = "R" // I, II, III
+	StyleAlphaLower = "a" // a, b, c
+	StyleAlphaUpper = "A" // A, B, C
+)
+
+// Label はあるページから先のページ番号の表示です。
+type Label struct {
+	Page   int    // 表示を切り替えるページの番号（Mark.Page と同じ番号）
+	Style  string // StyleDecimal など
+	Start  int    // 最初のページの番号（0 なら 1）
+	Prefix string // 番号の前に付ける文字列（"付録-" など）
+}
+
+// List は章の区切りとページ番号の表示の一覧です。
+type List struct {
+	Marks  []Mark
+	Labels []Label
+}
+
+// Empty は区切りも表示の指定も無いか返します。
+func (l List) Empty() bool { return len(l.Marks) == 0 && len(l.Labels) == 0 }
+
+// header は新しく作るファイルの先頭に書く説明です。
+const header = `# 章の区切り: 「ページ番号 タイトル」（ページ番号は screenshot_00012.jpg の 12）
+# 行頭を字下げ（タブか空白2つ）すると、1つ下の階層のしおりになります。
+# ページ番号の表示: 「@ページ番号 最初の番号」（例: @1 i は i, ii, iii…、@5 1 は 1, 2, 3…、@200 付録-1）
+`
+
+// Load は dir の chapters.txt を読み込みます。ファイルが無ければ空の List を返します。
+func Load(dir string) (List, error) {
+	f, err := os.Open(filepath.Join(dir, FileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return List{}, nil
+	}
+	if err != nil {
+		return List{}, err
+	}
+	defer f.Close()
+	l, err := Parse(f)
+	if err != nil {
+		return List{}, fmt.Errorf("%s: %w", FileName, err)
+	}
+	return l, nil
+}
+
+// Parse は chapters.txt の形式を読み込みます。区切りと表示はページ番号順に並べ替えます（同じページなら書いた順）。
+func Parse(r io.Reader) (List, error) {
+	var l List
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimRight(sc.Text(), " \t\r")
+		if n == 1 {
+			line = strings.TrimPrefix(line, "\ufeff") // メモ帳が付ける BOM
+		}
+		body := strings.TrimLeft(line, " \t　")
+		if body == "" || strings.HasPrefix(body, "#") {
+			continue
+		}
+		if spec, ok := strings.CutPrefix(body, "@"); ok {
+			page, rest, err := pageNumber(spec)
+			if err != nil {
+				return List{}, fmt.Errorf("%d 行目: %w", n, err)
+			}
+			lb, err := ParseLabel(rest)
+			if err != nil {
+				return List{}, fmt.Errorf("%d 行目: %w", n, err)
+			}
+			lb.Page = page
+			l.Labels = append(l.Labels, lb)
+			continue
+		}
+		page, title, err := pageNumber(body)
+		if err != nil {
+			return List{}, fmt.Errorf("%d 行目: %w", n, err)
+		}
+		if title == "" {
+			title = fmt.Sprintf("%d ページ", page)
+		}
+		l.Marks = append(l.Marks, Mark{Page: page, Title: title, Level: indentLevel(line[:len(line)-len(body)])})
+	}
+	if err := sc.Err(); err != nil {
+		return List{}, err
+	}
+	sort.SliceStable(l.Marks, func(i, j int) bool { return l.Marks[i].Page < l.Marks[j].Page })
+	sort.SliceStable(l.Labels, func(i, j int) bool { return l.Labels[i].Page < l.Labels[j].Page })
+	return l, nil
+}
+
+// pageNumber は行の先頭のページ番号と、残りの文字列を返します。
+func pageNumber(s string) (int, string, error) {
+	num, rest, _ := strings.Cut(s, " ")
+	if i := strings.IndexByte(num, '\t'); i >= 0 {
+		num, rest = num[:i], num[i+1:]+" "+rest
+	}
+	page, err := strconv.Atoi(num)
+	if err != nil || page < 1 {
+		return 0, "", fmt.Errorf("ページ番号 %q が正しくありません", num)
+	}
+	return page, strings.TrimSpace(rest), nil
+}
+
+// indentLevel は字下げの文字列を階層に変換します。タブ1つか空白2つ（全角空白は1つ）で1階層です。
+func indentLevel(indent string) int {
+	level, spaces := 0, 0
+	for _, r := range indent {
+		switch r {
+		case '\t', '　':
+			level++
+		case ' ':
+			spaces++
+		}
+	}
+	return level + spaces/2
+}
+
+// ParseLabel は最初のページの表示（"i", "1", "A", "付録-1" など）からページ番号の表示を作ります。
+// 全体が数字なら算用数字、ローマ数字として読めればローマ数字、英字1文字ならアルファベット、
+// 末尾が数字なら残りを前に付ける文字列とした算用数字、それ以外は番号を付けずにその文字列だけを表示します。
+func ParseLabel(spec string) (Label, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return Label{}, fmt.Errorf("ページ番号の表示がありません")
+	}
+	if n, err := strconv.Atoi(spec); err == nil && n > 0 {
+		return Label{Style: StyleDecimal, Start: n}, nil
+	}
+	if n, ok := parseRoman(strings.ToLower(spec)); ok {
+		if spec == strings.ToLower(spec) {
+			return Label{Style: StyleRomanLower, Start: n}, nil
+		}
+		if spec == strings.ToUpper(spec) {
+			return Label{Style: StyleRomanUpper, Start: n}, nil
+		}
+	}
+	if len(spec) == 1 && spec[0] >= 'a' && spec[0] <= 'z' {
+		return Label{Style: StyleAlphaLower, Start: int(spec[0]-'a') + 1}, nil
+	}
+	if len(spec) == 1 && spec[0] >= 'A' && spec[0] <= 'Z' {
+		return Label{Style: StyleAlphaUpper, Start: int(spec[0]-'A') + 1}, nil
+	}
+	digits := len(spec)
+	for digits > 0 && spec[digits-1] >= '0' && spec[digits-1] <= '9' {
+		digits--
+	}
+	if digits < len(spec) {
+		n, _ := strconv.Atoi(spec[digits:])
+		if n > 0 {
+			return Label{Style: StyleDecimal, Start: n, Prefix: spec[:digits]}, nil
+		}
+	}
+	return Label{Style: StyleNone, Prefix: spec}, nil
+}
+
+// parseRoman は小文字のローマ数字を読みます。
+func parseRoman(s string) (int, bool) {
+	values := map[byte]int{'i': 1, 'v': 5, 'x': 10, 'l': 50, 'c': 100, 'd': 500, 'm': 1000}
+	n := 0
+	for i := 0; i < len(s); i++ {
+		v, ok := values[s[i]]
+		if !ok {
+			return 0, false
+		}
+		if i+1 < len(s) && values[s[i+1]] > v {
+			n -= v
+		} else {
+			n += v
+		}
+	}
+	// "iiv" のような書き方は受け付けない
+	return n, n > 0 && formatRoman(n) == s
+}
+
+func formatRoman(n int) string {
+	var b strings.Builder
+	for _, p := range []struct {
+		v int
+		s string
+	}{{1000, "m"}, {900, "cm"}, {500, "d"}, {400, "cd"}, {100, "c"}, {90, "xc"}, {50, "l"}, {40, "xl"}, {10, "x"}, {9, "ix"}, {5, "v"}, {4, "iv"}, {1, "i"}} {
+		for n >= p.v {
+			b.WriteString(p.s)
+			n -= p.v
+		}
+	}
+	return b.String()
+}
+
+// Append は dir の chapters.txt の末尾に区切りを書き足します。ファイルが無ければ説明を書いて作ります。
+func Append(dir string, marks []Mark) error {
+	if len(marks) == 0 {
+		return nil
+	}
+	path := filepath.Join(dir, FileName)
+	old, readErr := os.ReadFile(path)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	switch {
+	case errors.Is(readErr, fs.ErrNotExist):
+		w.WriteString(header)
+	case len(old) > 0 && old[len(old)-1] != '\n':
+		w.WriteByte('\n') // 手で編集して最後の行に改行が無い
+	}
+	for _, m := range marks {
+		fmt.Fprintf(w, "%s%d %s\n", strings.Repeat("\t", m.Level), m.Page, m.Title)
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Title は実行中に付ける区切りの名前を、書式 format の {n} を章の番号 n に置き換えて作ります。format が空なら "第{n}章" です。
+func Title(format string, n int) string {
+	if format == "" {
+		format = "第{n}章"
+	}
+	return strings.ReplaceAll(format, "{n}", strconv.Itoa(n))
+}
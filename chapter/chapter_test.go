@@ -0,0 +1,152 @@
+package chapter
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		want    List
+		wantErr string
+	}{
+		{
+			"区切りと階層",
+			"\ufeff# コメント\n\n12 第1章 はじめに\n\t15 1.1 節\n    16\t1.1.1 小節\n　20 1.2\n30\n",
+			List{Marks: []Mark{
+				{Page: 12, Title: "第1章 はじめに"},
+				{Page: 15, Title: "1.1 節", Level: 1},
+				{Page: 16, Title: "1.1.1 小節", Level: 2},
+				{Page: 20, Title: "1.2", Level: 1},
+				{Page: 30, Title: "30 ページ"},
+			}},
+			"",
+		},
+		{
+			"ページ番号順に並べ替え、同じページは書いた順",
+			"@5 1\n9 後\n@1 i\n3 前\n9 後の次\r\n",
+			List{
+				Marks: []Mark{{Page: 3, Title: "前"}, {Page: 9, Title: "後"}, {Page: 9, Title: "後の次"}},
+				Labels: []Label{
+					{Page: 1, Style: StyleRomanLower, Start: 1},
+					{Page: 5, Style: StyleDecimal, Start: 1},
+				},
+			},
+			"",
+		},
+		{"ページ番号が無い", "1 表紙\n第2章\n", List{}, "2 行目"},
+		{"ページ番号が 0", "0 表紙\n", List{}, "1 行目"},
+		{"表示が無い", "@3\n", List{}, "1 行目"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(strings.NewReader(tt.text))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Parse error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLabel(t *testing.T) {
+	tests := []struct {
+		spec string
+		want Label
+	}{
+		{"1", Label{Style: StyleDecimal, Start: 1}},
+		{" 12 ", Label{Style: StyleDecimal, Start: 12}},
+		{"i", Label{Style: StyleRomanLower, Start: 1}},
+		{"xiv", Label{Style: StyleRomanLower, Start: 14}},
+		{"IX", Label{Style: StyleRomanUpper, Start: 9}},
+		{"a", Label{Style: StyleAlphaLower, Start: 1}},
+		{"C", Label{Style: StyleRomanUpper, Start: 100}},
+		{"B", Label{Style: StyleAlphaUpper, Start: 2}},
+		{"付録-1", Label{Style: StyleDecimal, Start: 1, Prefix: "付録-"}},
+		{"A-3", Label{Style: StyleDecimal, Start: 3, Prefix: "A-"}},
+		{"iiv", Label{Style: StyleNone, Prefix: "iiv"}},
+		{"Xi", Label{Style: StyleNone, Prefix: "Xi"}},
+		{"表紙", Label{Style: StyleNone, Prefix: "表紙"}},
+		{"p0", Label{Style: StyleNone, Prefix: "p0"}},
+	}
+	for _, tt := range tests {
+		got, err := ParseLabel(tt.spec)
+		if err != nil {
+			t.Errorf("ParseLabel(%q): %v", tt.spec, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseLabel(%q) = %+v, want %+v", tt.spec, got, tt.want)
+		}
+	}
+	if _, err := ParseLabel(" "); err == nil {
+		t.Error(`ParseLabel(" ") should fail`)
+	}
+}
+
+func TestAppendLoad(t *testing.T) {
+	dir := t.TempDir()
+	if l, err := Load(dir); err != nil || !l.Empty() {
+		t.Fatalf("Load without the file = %+v, %v", l, err)
+	}
+	if err := Append(dir, []Mark{{Page: 3, Title: "第1章"}, {Page: 5, Title: "節", Level: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	// 手で編集して最後の行の改行が消えていても、次の区切りは新しい行に書く
+	path := filepath.Join(dir, FileName)
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(b), header) {
+		t.Errorf("new file does not start with the header: %q", b)
+	}
+	if err := os.WriteFile(path, append(b, "@1 i"...), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Append(dir, []Mark{{Page: 8, Title: "第2章"}}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := List{
+		Marks:  []Mark{{Page: 3, Title: "第1章"}, {Page: 5, Title: "節", Level: 1}, {Page: 8, Title: "第2章"}},
+		Labels: []Label{{Page: 1, Style: StyleRomanLower, Start: 1}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Load = %+v, want %+v", got, want)
+	}
+}
+
+func TestTitle(t *testing.T) {
+	tests := []struct {
+		format string
+		n      int
+		want   string
+	}{
+		{"", 3, "第3章"},
+		{"Chapter {n}", 12, "Chapter 12"},
+		{"{n}-{n}", 2, "2-2"},
+		{"付録", 1, "付録"},
+	}
+	for _, tt := range tests {
+		if got := Title(tt.format, tt.n); got != tt.want {
+			t.Errorf("Title(%q, %d) = %q, want %q", tt.format, tt.n, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+// replay は保存済みの画像（フォルダ、マルチページ TIFF、アニメーション GIF）を画面の代わりに使って、
+// キャプチャから PDF 出力までを実行します。表示の無い環境での動作確認や、以前のセッションの作り直しに使います。
+//
+//	replay -src 画像のフォルダ|TIFF|GIF [-out 出力先] [-settings session.json] [-stuck 3,7:2] [-blank 5]
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"AutoScreenShot/capture"
+	"AutoScreenShot/chapter"
+	"AutoScreenShot/layout"
+	"AutoScreenShot/session"
+	"AutoScreenShot/trim"
+)
+
+func main() {
+	src := flag.String("src", "", "再生する画像のフォルダ、マルチページ TIFF またはアニメーション GIF")
+	outDir := flag.String("out", "", "出力先フォルダ（省略時は -src の名前に _replay を付けたフォルダ）")
+	settingsPath := flag.String("settings", "", "使う設定の session.json（省略時は -src のフォルダにあればそれを、無ければ初期値を使う）")
+	stuck := flag.String("stuck", "", "キーを無視するページ。ページ番号[:回数] をカンマ区切りで指定（例: 3,7:2）")
+	blank := flag.String("blank", "", "表示直後に空白を返すページ。ページ番号[:キャプチャ回数] をカンマ区切りで指定")
+	realtime := flag.Bool("realtime", false, "設定どおりの待機時間で実行する（省略時は待たない）")
+	flag.Parse()
+
+	if *src == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	replay, err := capture.LoadReplay(*src)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "画像の読み込みに失敗しました: %v\n", err)
+		os.Exit(1)
+	}
+	if replay.Stuck, err = parsePageCounts(*stuck); err != nil {
+		fmt.Fprintf(os.Stderr, "-stuck が不正です: %v\n", err)
+		os.Exit(2)
+	}
+	if replay.Blank, err = parsePageCounts(*blank); err != nil {
+		fmt.Fprintf(os.Stderr, "-blank が不正です: %v\n", err)
+		os.Exit(2)
+	}
+
+	settings := session.DefaultSettings()
+	var displayDPI float64 // 元のセッションで記録した画面の DPI
+	fromOutput := isOutputFolder(*src)
+	if *settingsPath == "" && fromOutput {
+		*settingsPath = *src
+	}
+	if *settingsPath != "" {
+		record, err := session.LoadRecord(*settingsPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "設定の読み込みに失敗しました: %v\n", err)
+			os.Exit(1)
+		}
+		settings = record.Settings
+		if record.Result != nil {
+			displayDPI = record.Result.DisplayDPI
+		}
+	}
+	if *outDir == "" {
+		*outDir = strings.TrimSuffix(filepath.Clean(*src), filepath.Ext(*src)) + "_replay"
+	}
+	settings = replaySettings(settings, *outDir, replay.Bounds(), fromOutput)
+
+	cfg, err := settings.Config()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "設定が不正です: %v\n", err)
+		os.Exit(1)
+	}
+	if !*realtime {
+		// 画面の描画を待つ必要が無いので、待機をほぼ無くす
+		cfg.Delay = time.Millisecond
+		cfg.StableOptions.MinWait = 0
+		cfg.StableOptions.Interval = time.Millisecond
+	}
+
+	if err := os.MkdirAll(*outDir, 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "フォルダ作成に失敗しました: %v\n", err)
+		os.Exit(1)
+	}
+	// 元のセッションの章の区切り（後から編集したものを含む）を引き継ぐ
+	if data, err := os.ReadFile(filepath.Join(*src, chapter.FileName)); err == nil {
+		if err := os.WriteFile(filepath.Join(*outDir, chapter.FileName), data, 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "章の区切りをコピーできません: %v\n", err)
+		}
+	}
+	job := session.Job{
+		Dir:        *outDir,
+		Settings:   settings,
+		Config:     cfg,
+		Capturer:   replay,
+		Input:      replay,
+		DisplayDPI: displayDPI,
+	}
+	res, out, err := job.Run(context.Background())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Printf("完了: %d フレーム中 %d 枚保存（削除 %d / スキップ %d / 重複 %d）、%s に PDF を出力しました。終了理由: %s\n",
+		replay.Len(), res.Count, res.Removed, res.Skipped, out.Duplicates, out.PDFPath, res.StopReason)
+	for _, p := range out.Exports() {
+		fmt.Printf("書き出し: %s\n", p)
+	}
+}
+
+// isOutputFolder は src がこのツールの出力フォルダ（session.json があるフォルダ）か返します。
+func isOutputFolder(src string) bool {
+	_, err := os.Stat(filepath.Join(src, session.RecordFileName))
+	return err == nil
+}
+
+// replaySettings は s を、大きさ frame の画像を再生して outDir に出力する設定にします。
+// fromOutput なら、元の画像は出力フォルダのページで分割と余白の切り取りを済ませてあるので、どちらも行いません。
+func replaySettings(s session.Settings, outDir string, frame image.Rectangle, fromOutput bool) session.Settings {
+	s.OutputFolder = outDir
+	s.Region = session.Region{Width: frame.Dx(), Height: frame.Dy()}
+	s.FocusWindowTitle = ""
+	if fromOutput {
+		s.SplitMode = layout.SplitNone
+		s.TrimMode = trim.ModeOff
+	}
+	return s
+}
+
+// parsePageCounts は "3,7:2" のような指定を ページ番号 → 回数 に変換します。回数を省略すると 1 です。
+func parsePageCounts(s string) (map[int]int, error) {
+	m := map[int]int{}
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		page, count, hasCount := strings.Cut(f, ":")
+		p, err := strconv.Atoi(page)
+		if err != nil || p < 1 {
+			return nil, fmt.Errorf("ページ番号 %q", page)
+		}
+		n := 1
+		if hasCount {
+			if n, err = strconv.Atoi(count); err != nil || n < 0 {
+				return nil, fmt.Errorf("回数 %q", count)
+			}
+		}
+		m[p] = n
+	}
+	return m, nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"image"
+	"reflect"
+	"testing"
+
+	"AutoScreenShot/layout"
+	"AutoScreenShot/session"
+	"AutoScreenShot/trim"
+)
+
+func TestParsePageCounts(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    map[int]int
+		wantErr bool
+	}{
+		{"", map[int]int{}, false},
+		{"3", map[int]int{3: 1}, false},
+		{"3, 7:2", map[int]int{3: 1, 7: 2}, false},
+		{"5:0", map[int]int{5: 0}, false},
+		{"0", nil, true},
+		{"a", nil, true},
+		{"3:x", nil, true},
+		{"3:-1", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parsePageCounts(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePageCounts(%q) error = %v", tt.in, err)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parsePageCounts(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplaySettings(t *testing.T) {
+	out := t.TempDir()
+	s := session.DefaultSettings()
+	s.SplitMode = layout.SplitCenter
+	s.TrimMode = trim.ModePage
+	s.FocusWindowTitle = "ビューア"
+	if err := session.SaveRecord(out, session.Record{Settings: s}); err != nil {
+		t.Fatal(err)
+	}
+	if !isOutputFolder(out) {
+		t.Error("a folder with session.json should be an output folder")
+	}
+	if isOutputFolder(t.TempDir()) {
+		t.Error("a folder without session.json should not be an output folder")
+	}
+
+	frame := image.Rect(0, 0, 640, 480)
+	tests := []struct {
+		name       string
+		fromOutput bool
+		split      string
+		trim       string
+	}{
+		// 出力フォルダのページは分割と切り取りを済ませてある
+		{"出力フォルダ", true, layout.SplitNone, trim.ModeOff},
+		{"キャプチャした画像", false, layout.SplitCenter, trim.ModePage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := replaySettings(s, "replay", frame, tt.fromOutput)
+			if got.SplitMode != tt.split || got.TrimMode != tt.trim {
+				t.Errorf("SplitMode, TrimMode = %q, %q, want %q, %q", got.SplitMode, got.TrimMode, tt.split, tt.trim)
+			}
+			if got.OutputFolder != "replay" || got.Region != (session.Region{Width: 640, Height: 480}) || got.FocusWindowTitle != "" {
+				t.Errorf("settings = %+v", got)
+			}
+		})
+	}
+}
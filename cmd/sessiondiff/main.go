@@ -0,0 +1,60 @@
+// sessiondiff は2回分のキャプチャ結果（出力フォルダ）をページごとに比較し、
+// 差分画像と HTML レポートを書き出します。
+//
+//	sessiondiff -a 前回のフォルダ -b 今回のフォルダ [-out 出力先] [-align index|hash]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"AutoScreenShot/regress"
+)
+
+func main() {
+	dirA := flag.String("a", "", "比較元（前回）の出力フォルダ")
+	dirB := flag.String("b", "", "比較先（今回）の出力フォルダ")
+	outDir := flag.String("out", "", "差分画像とレポートの出力先（省略時は -b のフォルダ内の diff）")
+	align := flag.String("align", regress.AlignIndex, "ページの対応付け: index（ページ番号順）または hash（知覚ハッシュ）")
+	maxDist := flag.Int("distance", 12, "-align hash で同じページとみなす最大ハミング距離")
+	threshold := flag.Int("threshold", 24, "画素を変更とみなす色の差（0〜255）")
+	flag.Parse()
+
+	if *dirA == "" || *dirB == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *outDir == "" {
+		*outDir = filepath.Join(*dirB, "diff")
+	}
+
+	results, err := regress.Compare(*dirA, *dirB, *outDir, regress.Options{
+		Align:       *align,
+		MaxDistance: *maxDist,
+		Threshold:   *threshold,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "比較に失敗しました: %v\n", err)
+		os.Exit(1)
+	}
+	changed := 0
+	for _, r := range results {
+		if r.Status != regress.StatusSame {
+			changed++
+		}
+		fmt.Printf("%5d  %-8s  %-24s  %-24s  %6.2f%%\n", r.Index, r.Status, baseName(r.A), baseName(r.B), r.Similarity*100)
+	}
+	fmt.Printf("完了: %d 組中 %d 組に差分があります。レポート: %s\n", len(results), changed, filepath.Join(*outDir, regress.ReportFileName))
+	if changed > 0 {
+		os.Exit(3)
+	}
+}
+
+func baseName(path string) string {
+	if path == "" {
+		return "-"
+	}
+	return filepath.Base(path)
+}
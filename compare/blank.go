@@ -0,0 +1,78 @@
+package compare
+
+import (
+	"image"
+	"math"
+)
+
+// 空白ページの扱い（設定値）
+const (
+	BlankKeep      = ""          // 何もしない（通常のページと同じ）
+	BlankSkip      = "skip"      // 保存しない
+	BlankMark      = "mark"      // 保存して空白ページとして記録する
+	BlankRecapture = "recapture" // 少し待って撮り直し、それでも空白なら記録して保存する
+)
+
+// BlankOptions は空白ページ判定のしきい値です。ゼロ値の項目は既定値を使います。
+type BlankOptions struct {
+	MaxStdDev float64 // 輝度（0〜255）の標準偏差がこれ以下なら空白（既定 3）
+	MaxInk    float64 // 背景と違う画素の割合がこれ以下なら空白（既定 0.002 = 0.2%）
+	InkDelta  float64 // 平均輝度からこれ以上離れた画素を「インク」とみなす（既定 40）
+}
+
+func (o BlankOptions) withDefaults() BlankOptions {
+	if o.MaxStdDev <= 0 {
+		o.MaxStdDev = 3
+	}
+	if o.MaxInk <= 0 {
+		o.MaxInk = 0.002
+	}
+	if o.InkDelta <= 0 {
+		o.InkDelta = 40
+	}
+	return o
+}
+
+// maxBlankSamples は空白判定で調べる画素数の上限です（大きな画像は間引いて調べる）。
+const maxBlankSamples = 250000
+
+// BlankStats は画像の輝度の標準偏差と、平均輝度から inkDelta 以上離れた画素の割合を返します。
+func BlankStats(img image.Image, inkDelta float64) (stdDev, ink float64) {
+	b := img.Bounds()
+	if b.Empty() {
+		return 0, 0
+	}
+	step := 1
+	for (b.Dx()/step)*(b.Dy()/step) > maxBlankSamples {
+		step++
+	}
+	var lum []float64
+	var sum float64
+	for y := b.Min.Y; y < b.Max.Y; y += step {
+		for x := b.Min.X; x < b.Max.X; x += step {
+			r, g, bl, _ := img.At(x, y).RGBA()
+			l := (0.299*float64(r) + 0.587*float64(g) + 0.114*float64(bl)) / 257
+			lum = append(lum, l)
+			sum += l
+		}
+	}
+	mean := sum / float64(len(lum))
+	var sq float64
+	inked := 0
+	for _, l := range lum {
+		d := l - mean
+		sq += d * d
+		if math.Abs(d) >= inkDelta {
+			inked++
+		}
+	}
+	return math.Sqrt(sq / float64(len(lum))), float64(inked) / float64(len(lum))
+}
+
+// IsBlank は画像が空白（またはほぼ空白）のページか返します。
+// 輝度のばらつきが小さいか、背景と違う画素（文字や絵）がごくわずかなら空白とみなします。
+func IsBlank(img image.Image, opt BlankOptions) bool {
+	opt = opt.withDefaults()
+	stdDev, ink := BlankStats(img, opt.InkDelta)
+	return stdDev <= opt.MaxStdDev || ink <= opt.MaxInk
+}
@@ -0,0 +1,80 @@
+package compare
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+// fill は大きさ w×h で、画素 (x, y) を at(x, y) の灰色にした画像を返します。
+func fill(w, h int, at func(x, y int) uint8) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetGray(x, y, color.Gray{at(x, y)})
+		}
+	}
+	return img
+}
+
+func TestBlankStats(t *testing.T) {
+	tests := []struct {
+		name      string
+		img       image.Image
+		stdDev    float64
+		ink       float64
+		inkDelta  float64
+		tolerance float64
+	}{
+		{"白", fill(10, 10, func(x, y int) uint8 { return 255 }), 0, 0, 40, 1e-9},
+		{"白黒半分", fill(10, 10, func(x, y int) uint8 { return uint8(255 * (x / 5)) }), 127.5, 1, 40, 1e-6},
+		{"白黒半分（インクの閾値が大きい）", fill(10, 10, func(x, y int) uint8 { return uint8(255 * (x / 5)) }), 127.5, 0, 200, 1e-6},
+		{"空の画像", image.NewGray(image.Rect(0, 0, 0, 0)), 0, 0, 40, 1e-9},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdDev, ink := BlankStats(tt.img, tt.inkDelta)
+			if math.Abs(stdDev-tt.stdDev) > tt.tolerance || math.Abs(ink-tt.ink) > tt.tolerance {
+				t.Errorf("BlankStats = %v, %v, want %v, %v", stdDev, ink, tt.stdDev, tt.ink)
+			}
+		})
+	}
+}
+
+func TestIsBlank(t *testing.T) {
+	// dots は白いページに黒い点を n 個打った画像です（100×100 = 10000 画素）。
+	dots := func(n int) image.Image {
+		return fill(100, 100, func(x, y int) uint8 {
+			if y == 50 && x < n {
+				return 0
+			}
+			return 255
+		})
+	}
+	tests := []struct {
+		name  string
+		img   image.Image
+		opt   BlankOptions
+		blank bool
+	}{
+		{"白", fill(100, 100, func(x, y int) uint8 { return 255 }), BlankOptions{}, true},
+		{"黒", fill(100, 100, func(x, y int) uint8 { return 0 }), BlankOptions{}, true},
+		{"わずかなノイズ", fill(100, 100, func(x, y int) uint8 { return uint8(250 + (x*7+y*3)%5) }), BlankOptions{}, true},
+		{"点が 0.1%（インクの既定 0.2% 以下）", dots(10), BlankOptions{}, true},
+		{"点が 0.1%（インクの上限 0.05%）", dots(10), BlankOptions{MaxInk: 0.0005}, false},
+		{"点が 1%", dots(100), BlankOptions{}, false},
+		{"文字のあるページ", testPage(6), BlankOptions{}, false},
+		{"文字のあるページ（ばらつきの上限を大きく）", testPage(6), BlankOptions{MaxStdDev: 200}, true},
+		{"グラデーション", fill(100, 100, func(x, y int) uint8 { return uint8(x * 255 / 99) }), BlankOptions{}, false},
+		{"間引いて調べる大きなページ", fill(1200, 900, func(x, y int) uint8 { return 255 }), BlankOptions{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsBlank(tt.img, tt.opt); got != tt.blank {
+				stdDev, ink := BlankStats(tt.img, 40)
+				t.Errorf("IsBlank = %v, want %v (stdDev %.2f, ink %.4f)", got, tt.blank, stdDev, ink)
+			}
+		})
+	}
+}
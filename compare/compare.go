@@ -1,24 +1,39 @@
 package compare
 
 import (
-	"bytes"
 	"crypto/sha256"
+	"encoding/binary"
 	"image"
-	"image/jpeg"
+	"image/color"
 	"io"
 )
 
-// Hash は画像の JPEG エンコード後の SHA256 ハッシュを返します。
-func Hash(img image.Image, quality int) ([]byte, error) {
-	var buf bytes.Buffer
-	if quality <= 0 {
-		quality = 85
+// PixelHash は画像の大きさと画素（8 ビットの RGBA）の SHA256 ハッシュを返します。
+// エンコードせずに求めるので速く、画素が1つでも違えば違う値になります。
+func PixelHash(img image.Image) []byte {
+	b := img.Bounds()
+	h := sha256.New()
+	var size [8]byte
+	binary.BigEndian.PutUint32(size[:4], uint32(b.Dx()))
+	binary.BigEndian.PutUint32(size[4:], uint32(b.Dy()))
+	h.Write(size[:])
+	if rgba, ok := img.(*image.RGBA); ok {
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			i := rgba.PixOffset(b.Min.X, y)
+			h.Write(rgba.Pix[i : i+4*b.Dx()])
+		}
+		return h.Sum(nil)
 	}
-	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
-		return nil, err
+	row := make([]byte, 0, 4*b.Dx())
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		row = row[:0]
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+			row = append(row, c.R, c.G, c.B, c.A)
+		}
+		h.Write(row)
 	}
-	h := sha256.Sum256(buf.Bytes())
-	return h[:], nil
+	return h.Sum(nil)
 }
 
 // HashFromReader は読み込み済み画像データの SHA256 を返します（保存前のバイト列と一致させる用）。
@@ -29,11 +44,3 @@ func HashFromReader(r io.Reader) ([]byte, error) {
 	}
 	return h.Sum(nil), nil
 }
-
-// ThreeSame は a, b, c の3つのハッシュがすべて一致するか返します。
-func ThreeSame(a, b, c []byte) bool {
-	if a == nil || b == nil || c == nil {
-		return false
-	}
-	return bytes.Equal(a, b) && bytes.Equal(b, c)
-}
@@ -0,0 +1,86 @@
+package compare
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+func TestPixelHash(t *testing.T) {
+	base := func() *image.RGBA {
+		img := image.NewRGBA(image.Rect(0, 0, 8, 6))
+		for i := range img.Pix {
+			img.Pix[i] = uint8(i * 7)
+		}
+		return img
+	}
+	changed := base()
+	changed.Set(7, 5, color.RGBA{1, 2, 3, 255})
+	nrgba := image.NewNRGBA(image.Rect(0, 0, 8, 6))
+	for y := 0; y < 6; y++ {
+		for x := 0; x < 8; x++ {
+			c := base().RGBAAt(x, y)
+			nrgba.Set(x, y, color.RGBA{c.R, c.G, c.B, 255})
+		}
+	}
+	opaque := base()
+	for i := 3; i < len(opaque.Pix); i += 4 {
+		opaque.Pix[i] = 255
+	}
+	tests := []struct {
+		name string
+		a, b image.Image
+		same bool
+	}{
+		{"同じ画素", base(), base(), true},
+		{"1画素だけ違う", base(), changed, false},
+		{"切り出した範囲", base().SubImage(image.Rect(2, 1, 6, 5)), base().SubImage(image.Rect(2, 1, 6, 5)), true},
+		{"画素の並びが同じでも大きさが違う", image.NewRGBA(image.Rect(0, 0, 4, 2)), image.NewRGBA(image.Rect(0, 0, 2, 4)), false},
+		{"型が違っても画素が同じ", opaque, nrgba, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := bytes.Equal(PixelHash(tt.a), PixelHash(tt.b)); got != tt.same {
+				t.Errorf("same = %v, want %v", got, tt.same)
+			}
+		})
+	}
+}
+
+func TestComparerExactComparesPixels(t *testing.T) {
+	gray := func(v uint8) *image.Gray {
+		img := image.NewGray(image.Rect(0, 0, 16, 16))
+		for i := range img.Pix {
+			img.Pix[i] = 128
+		}
+		img.Pix[5*16+5] = v
+		return img
+	}
+	a, b := gray(128), gray(129) // 1画素の明るさが 1 だけ違う
+	encode := func(img image.Image) []byte {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85}); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	// JPEG にすると消えてしまう違いでも
+	if !bytes.Equal(encode(a), encode(b)) {
+		t.Fatal("the difference should vanish in JPEG for this test")
+	}
+	// 完全一致では別のフレームとみなす
+	var c Comparer
+	ha, err := c.Sum(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hb, err := c.Sum(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Same(ha, hb) {
+		t.Error("ModeExact should tell frames that differ by one level in one pixel apart")
+	}
+}
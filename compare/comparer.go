@@ -0,0 +1,103 @@
+package compare
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"math/bits"
+)
+
+// Mode は同一判定に使うハッシュの種類です。
+type Mode string
+
+const (
+	ModeExact      Mode = "exact" // 画素の SHA256（画素がすべて一致）
+	ModeAverage    Mode = "ahash" // 平均ハッシュ
+	ModeDifference Mode = "dhash" // 差分ハッシュ
+	ModeDCT        Mode = "phash" // DCT ハッシュ
+)
+
+// ParseMode は設定文字列を Mode に変換します。空文字は ModeExact として扱います。
+func ParseMode(s string) (Mode, error) {
+	switch m := Mode(s); m {
+	case "":
+		return ModeExact, nil
+	case ModeExact, ModeAverage, ModeDifference, ModeDCT:
+		return m, nil
+	}
+	return "", fmt.Errorf("不明な比較モードです: %q", s)
+}
+
+// Perceptual は知覚ハッシュ（見た目の近さで比較するハッシュ）のモードなら true を返します。
+func (m Mode) Perceptual() bool {
+	return m == ModeAverage || m == ModeDifference || m == ModeDCT
+}
+
+// Comparer は画像のハッシュ計算と同一判定をまとめたものです。
+// ゼロ値は完全一致（画素の SHA256）で比較します。完全一致は画素が1つでも違えば別のフレームとみなします。
+// 以前のように JPEG にエンコードしたバイト列で比べると、圧縮で消えるほど小さな違い（明るさが 1 違うなど）は
+// 同じになりましたが、画素で比べるのでそうした違いも変化として扱います。
+type Comparer struct {
+	Mode      Mode
+	Tolerance int // 知覚ハッシュで同一とみなす最大ハミング距離（0〜64）
+	// Masks はハッシュ計算から除外する範囲です（画像の左上を原点とする座標）。
+	// ページ番号や時計など、毎回変わる部分を指定します。
+	Masks []image.Rectangle
+}
+
+// Sum は画像のハッシュを返します。
+// ModeExact では PixelHash と同じ値、知覚ハッシュでは 64 ビット値をビッグエンディアンの 8 バイトで返します。
+// Masks が設定されていれば、その範囲を塗りつぶしてから計算します。
+func (c Comparer) Sum(img image.Image) ([]byte, error) {
+	img = Masked(img, c.Masks)
+	var h uint64
+	switch c.Mode {
+	case "", ModeExact:
+		return PixelHash(img), nil
+	case ModeAverage:
+		h = AverageHash(img)
+	case ModeDifference:
+		h = DifferenceHash(img)
+	case ModeDCT:
+		h = DCTHash(img)
+	default:
+		return nil, fmt.Errorf("不明な比較モードです: %q", c.Mode)
+	}
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, h)
+	return b, nil
+}
+
+// Same は Sum で得た 2 つのハッシュが同一とみなせるか返します。
+func (c Comparer) Same(a, b []byte) bool {
+	if a == nil || b == nil {
+		return false
+	}
+	if !c.Mode.Perceptual() {
+		return bytes.Equal(a, b)
+	}
+	d := Distance(a, b)
+	return d >= 0 && d <= c.Tolerance
+}
+
+// Distance は Sum で得た 2 つのハッシュのハミング距離を返します。
+// 知覚ハッシュ以外のモード（SHA256 の距離には意味が無い）や、どちらかのハッシュが無いときは -1 を返します。
+func (c Comparer) Distance(a, b []byte) int {
+	if !c.Mode.Perceptual() || a == nil || b == nil {
+		return -1
+	}
+	return Distance(a, b)
+}
+
+// Distance は 2 つのハッシュのハミング距離を返します。長さが異なる場合は -1 を返します。
+func Distance(a, b []byte) int {
+	if len(a) != len(b) {
+		return -1
+	}
+	d := 0
+	for i := range a {
+		d += bits.OnesCount8(a[i] ^ b[i])
+	}
+	return d
+}
@@ -0,0 +1,28 @@
+package compare
+
+import "testing"
+
+func TestComparerDistance(t *testing.T) {
+	a := []byte{0, 0, 0, 0, 0, 0, 0, 0x0f}
+	b := []byte{0, 0, 0, 0, 0, 0, 0, 0xff}
+	tests := []struct {
+		name string
+		mode Mode
+		a, b []byte
+		want int
+	}{
+		{"完全一致では求めない", ModeExact, a, b, -1},
+		{"既定のモードも完全一致", "", a, b, -1},
+		{"aHash", ModeAverage, a, b, 4},
+		{"dHash", ModeDifference, a, a, 0},
+		{"pHash", ModeDCT, a, b, 4},
+		{"直前のフレームが無い", ModeDCT, nil, b, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (Comparer{Mode: tt.mode}).Distance(tt.a, tt.b); got != tt.want {
+				t.Errorf("Distance = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
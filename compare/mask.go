@@ -0,0 +1,28 @@
+package compare
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// Masked は masks の範囲を黒で塗りつぶした画像のコピーを返します。
+// masks は img の左上を原点とする座標（キャプチャ範囲からの相対座標）で指定します。
+// masks が空なら img をそのまま返します。
+func Masked(img image.Image, masks []image.Rectangle) image.Image {
+	if len(masks) == 0 {
+		return img
+	}
+	b := img.Bounds()
+	dst := image.NewRGBA(b)
+	draw.Draw(dst, b, img, b.Min, draw.Src)
+	black := image.NewUniform(color.Black)
+	for _, m := range masks {
+		r := m.Add(b.Min).Intersect(b)
+		if r.Empty() {
+			continue
+		}
+		draw.Draw(dst, r, black, image.Point{}, draw.Src)
+	}
+	return dst
+}
@@ -0,0 +1,93 @@
+package compare
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestMasked(t *testing.T) {
+	white := func(r image.Rectangle) *image.RGBA {
+		img := image.NewRGBA(r)
+		for i := range img.Pix {
+			img.Pix[i] = 255
+		}
+		return img
+	}
+	tests := []struct {
+		name   string
+		bounds image.Rectangle
+		masks  []image.Rectangle
+		black  []image.Point // 黒になる画素（画像の左上からの位置）
+		white  []image.Point
+	}{
+		{"範囲を塗る", image.Rect(0, 0, 10, 10), []image.Rectangle{image.Rect(2, 3, 4, 5)},
+			[]image.Point{{2, 3}, {3, 4}}, []image.Point{{1, 3}, {4, 4}, {2, 5}}},
+		{"画像からはみ出す範囲は切り詰める", image.Rect(0, 0, 10, 10), []image.Rectangle{image.Rect(8, -5, 20, 2)},
+			[]image.Point{{9, 0}, {8, 1}}, []image.Point{{7, 0}, {9, 2}}},
+		{"画像の外の範囲は無視する", image.Rect(0, 0, 10, 10), []image.Rectangle{image.Rect(20, 20, 30, 30)},
+			nil, []image.Point{{0, 0}, {9, 9}}},
+		{"原点が 0 でない画像は左上からの位置", image.Rect(5, 5, 15, 15), []image.Rectangle{image.Rect(0, 0, 1, 1)},
+			[]image.Point{{0, 0}}, []image.Point{{1, 1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := white(tt.bounds)
+			got := Masked(src, tt.masks)
+			if got.Bounds() != tt.bounds {
+				t.Fatalf("Bounds = %v, want %v", got.Bounds(), tt.bounds)
+			}
+			at := func(p image.Point) color.Color { return got.At(tt.bounds.Min.X+p.X, tt.bounds.Min.Y+p.Y) }
+			for _, p := range tt.black {
+				if r, _, _, _ := at(p).RGBA(); r != 0 {
+					t.Errorf("%v is not masked", p)
+				}
+			}
+			for _, p := range tt.white {
+				if r, _, _, _ := at(p).RGBA(); r != 0xffff {
+					t.Errorf("%v is masked", p)
+				}
+			}
+			for i, v := range src.Pix {
+				if v != 255 {
+					t.Fatalf("Masked changed the source image at %d", i)
+				}
+			}
+		})
+	}
+	img := white(image.Rect(0, 0, 2, 2))
+	if got := Masked(img, nil); got != image.Image(img) {
+		t.Error("Masked without masks should return the image itself")
+	}
+}
+
+func TestComparerSumMasks(t *testing.T) {
+	a := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	b := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	b.Set(14, 1, color.RGBA{255, 0, 0, 255}) // 時計の部分だけ違う
+	tests := []struct {
+		name  string
+		masks []image.Rectangle
+		same  bool
+	}{
+		{"マスクなし", nil, false},
+		{"違う部分をマスク", []image.Rectangle{image.Rect(12, 0, 16, 4)}, true},
+		{"別の部分をマスク", []image.Rectangle{image.Rect(0, 12, 4, 16)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Comparer{Masks: tt.masks}
+			ha, err := c.Sum(a)
+			if err != nil {
+				t.Fatal(err)
+			}
+			hb, err := c.Sum(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := c.Same(ha, hb); got != tt.same {
+				t.Errorf("Same = %v, want %v", got, tt.same)
+			}
+		})
+	}
+}
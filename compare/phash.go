@@ -0,0 +1,142 @@
+package compare
+
+import (
+	"image"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+// AverageHash は画像の平均ハッシュ（aHash）を返します。
+// 8x8 のグレースケールに縮小し、各画素が平均より明るいかどうかを 64 ビットに詰めます。
+func AverageHash(img image.Image) uint64 {
+	px := grayResize(img, 8, 8)
+	var sum float64
+	for _, v := range px {
+		sum += v
+	}
+	mean := sum / float64(len(px))
+	var h uint64
+	for i, v := range px {
+		if v > mean {
+			h |= 1 << uint(i)
+		}
+	}
+	return h
+}
+
+// DifferenceHash は画像の差分ハッシュ（dHash）を返します。
+// 9x8 のグレースケールに縮小し、横に隣り合う画素の明暗差を 64 ビットに詰めます。
+func DifferenceHash(img image.Image) uint64 {
+	px := grayResize(img, 9, 8)
+	var h uint64
+	bit := 0
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			if px[y*9+x] < px[y*9+x+1] {
+				h |= 1 << uint(bit)
+			}
+			bit++
+		}
+	}
+	return h
+}
+
+// DCTHash は画像の DCT ハッシュ（pHash）を返します。
+// 32x32 のグレースケールに縮小して 2 次元 DCT をかけ、低周波成分 8x8（直流成分を除く）が
+// 中央値より大きいかどうかを 64 ビットに詰めます。
+func DCTHash(img image.Image) uint64 {
+	const n = 32
+	px := grayResize(img, n, n)
+	coef := dct2D(px, n)
+
+	low := make([]float64, 0, 64)
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			low = append(low, coef[y*n+x])
+		}
+	}
+	// 直流成分（全体の明るさ）は判定に使わない
+	sorted := append([]float64(nil), low[1:]...)
+	sort.Float64s(sorted)
+	median := sorted[len(sorted)/2]
+
+	var h uint64
+	for i, v := range low {
+		if v > median {
+			h |= 1 << uint(i)
+		}
+	}
+	return h
+}
+
+// Hamming は 2 つの 64 ビットハッシュのハミング距離（異なるビット数）を返します。
+func Hamming(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// Similarity は 2 つの 64 ビットハッシュの類似度を 0.0〜1.0 で返します（1.0 が完全一致）。
+func Similarity(a, b uint64) float64 {
+	return 1 - float64(Hamming(a, b))/64
+}
+
+// grayResize は画像を w x h のグレースケール（0〜255）に面積平均で縮小します。
+func grayResize(img image.Image, w, h int) []float64 {
+	b := img.Bounds()
+	sum := make([]float64, w*h)
+	cnt := make([]float64, w*h)
+	bw, bh := b.Dx(), b.Dy()
+	if bw <= 0 || bh <= 0 {
+		return sum
+	}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		cy := (y - b.Min.Y) * h / bh
+		for x := b.Min.X; x < b.Max.X; x++ {
+			cx := (x - b.Min.X) * w / bw
+			r, g, bl, _ := img.At(x, y).RGBA()
+			// ITU-R BT.601 の輝度
+			l := (0.299*float64(r) + 0.587*float64(g) + 0.114*float64(bl)) / 257
+			sum[cy*w+cx] += l
+			cnt[cy*w+cx]++
+		}
+	}
+	for i := range sum {
+		if cnt[i] > 0 {
+			sum[i] /= cnt[i]
+		}
+	}
+	return sum
+}
+
+// dct2D は n x n の値に 2 次元 DCT-II をかけた係数を返します。
+func dct2D(px []float64, n int) []float64 {
+	cos := make([]float64, n*n)
+	for k := 0; k < n; k++ {
+		for i := 0; i < n; i++ {
+			cos[k*n+i] = math.Cos(math.Pi / float64(n) * (float64(i) + 0.5) * float64(k))
+		}
+	}
+	// 行方向
+	tmp := make([]float64, n*n)
+	for y := 0; y < n; y++ {
+		for k := 0; k < n; k++ {
+			var s float64
+			for x := 0; x < n; x++ {
+				s += px[y*n+x] * cos[k*n+x]
+			}
+			tmp[y*n+k] = s
+		}
+	}
+	// 列方向
+	out := make([]float64, n*n)
+	for x := 0; x < n; x++ {
+		for k := 0; k < n; k++ {
+			var s float64
+			for y := 0; y < n; y++ {
+				s += tmp[y*n+x] * cos[k*n+y]
+			}
+			out[k*n+x] = s
+		}
+	}
+	return out
+}
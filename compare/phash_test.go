@@ -0,0 +1,150 @@
+package compare
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// testPage は行 lines 本の「文字」（濃い横棒）を並べたページの画像を返します。
+func testPage(lines int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 160, 120))
+	for i := range img.Pix {
+		img.Pix[i] = 255
+	}
+	for l := 0; l < lines; l++ {
+		y := 10 + l*12
+		w := 40 + (l*37)%100
+		for yy := y; yy < y+6; yy++ {
+			for x := 10; x < 10+w; x++ {
+				img.Set(x, yy, color.RGBA{20, 20, 20, 255})
+			}
+		}
+	}
+	return img
+}
+
+func TestPerceptualHashes(t *testing.T) {
+	base := testPage(6)
+	caret := testPage(6) // 点滅するカーソル（2x6 画素）だけ違う
+	for y := 100; y < 106; y++ {
+		caret.Set(150, y, color.Black)
+		caret.Set(151, y, color.Black)
+	}
+	other := testPage(3)
+	inverted := testPage(6)
+	for i := range inverted.Pix {
+		if i%4 != 3 {
+			inverted.Pix[i] = 255 - inverted.Pix[i]
+		}
+	}
+	hashes := []struct {
+		name string
+		hash func(image.Image) uint64
+	}{
+		{"aHash", AverageHash},
+		{"dHash", DifferenceHash},
+		{"pHash", DCTHash},
+	}
+	for _, h := range hashes {
+		t.Run(h.name, func(t *testing.T) {
+			if d := Hamming(h.hash(base), h.hash(testPage(6))); d != 0 {
+				t.Errorf("same page: distance %d, want 0", d)
+			}
+			if d := Hamming(h.hash(base), h.hash(caret)); d > 4 {
+				t.Errorf("caret: distance %d, want <= 4", d)
+			}
+			if d := Hamming(h.hash(base), h.hash(other)); d < 8 {
+				t.Errorf("other page: distance %d, want >= 8", d)
+			}
+			if d := Hamming(h.hash(base), h.hash(inverted)); d < 32 {
+				t.Errorf("inverted page: distance %d, want >= 32", d)
+			}
+		})
+	}
+}
+
+func TestHammingSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b uint64
+		d    int
+		sim  float64
+	}{
+		{0, 0, 0, 1},
+		{0, 0xff, 8, 0.875},
+		{0x0f, 0xf0, 8, 0.875},
+		{0, ^uint64(0), 64, 0},
+	}
+	for _, tt := range tests {
+		if got := Hamming(tt.a, tt.b); got != tt.d {
+			t.Errorf("Hamming(%x, %x) = %d, want %d", tt.a, tt.b, got, tt.d)
+		}
+		if got := Similarity(tt.a, tt.b); got != tt.sim {
+			t.Errorf("Similarity(%x, %x) = %v, want %v", tt.a, tt.b, got, tt.sim)
+		}
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	tests := []struct {
+		s    string
+		want Mode
+		ok   bool
+	}{
+		{"", ModeExact, true},
+		{"exact", ModeExact, true},
+		{"ahash", ModeAverage, true},
+		{"dhash", ModeDifference, true},
+		{"phash", ModeDCT, true},
+		{"PHASH", "", false},
+		{"sha1", "", false},
+	}
+	for _, tt := range tests {
+		got, err := ParseMode(tt.s)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("ParseMode(%q) = %q, %v, want %q, ok=%v", tt.s, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestComparerSame(t *testing.T) {
+	base := testPage(6)
+	caret := testPage(6)
+	caret.Set(150, 100, color.Black)
+	tests := []struct {
+		name      string
+		mode      Mode
+		tolerance int
+		a, b      image.Image
+		same      bool
+	}{
+		{"完全一致で同じ画像", ModeExact, 0, base, testPage(6), true},
+		{"完全一致は1画素の違いも区別する", ModeExact, 64, base, caret, false},
+		{"既定のモードは完全一致", "", 0, base, caret, false},
+		{"許容距離の内側", ModeDCT, 4, base, caret, true},
+		{"別のページ", ModeDCT, 4, base, testPage(2), false},
+		{"許容距離 0 の知覚ハッシュ", ModeAverage, 0, base, testPage(6), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Comparer{Mode: tt.mode, Tolerance: tt.tolerance}
+			a, err := c.Sum(tt.a)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := c.Sum(tt.b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := c.Same(a, b); got != tt.same {
+				t.Errorf("Same = %v, want %v (distance %d)", got, tt.same, Distance(a, b))
+			}
+			if c.Same(nil, b) {
+				t.Error("Same(nil, b) should be false")
+			}
+		})
+	}
+	if _, err := (Comparer{Mode: "sha1"}).Sum(base); err == nil {
+		t.Error("Sum with an unknown mode should fail")
+	}
+}
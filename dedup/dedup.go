@@ -0,0 +1,149 @@
+package dedup
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"AutoScreenShot/compare"
+
+	_ "golang.org/x/image/tiff"
+)
+
+// 重複ページの扱い
+const (
+	ActionOff    = ""       // 何もしない
+	ActionRemove = "remove" // 削除する
+	ActionFlag   = "flag"   // DuplicatesDirName に移動して PDF から外す
+)
+
+// DuplicatesDirName は ActionFlag で重複ページを移動するサブフォルダ名です。
+const DuplicatesDirName = "duplicates"
+
+// ReportFileName は重複ページの報告を書き出すファイル名です。
+const ReportFileName = "dedup_report.txt"
+
+// Duplicate は重複と判定されたページです。
+type Duplicate struct {
+	Path     string // 重複したページ
+	Original string // 同一とみなした、先に現れたページ
+	Exact    bool   // ファイルの内容が完全に一致した
+	Distance int    // 知覚ハッシュのハミング距離（Exact のときは 0）
+}
+
+// Find は paths（ページ順）の中から、それより前のページと同一または見た目がほぼ同じページを探します。
+// ファイルの内容が一致するものは常に重複とし、cmp が知覚ハッシュのモードなら cmp.Same で近いものも重複とします。
+// 比較は重複でないページ同士だけで行うため、重複したページはすべて最初に現れたページを指します。
+// cmp.Masks はキャプチャ範囲の座標なので、大きさが frame（キャプチャ範囲の幅と高さ）と同じページにだけ使い、
+// 分けたページや余白を切り取ったページは除外範囲を使わずに比較します。
+func Find(paths []string, cmp compare.Comparer, frame image.Point) ([]Duplicate, error) {
+	type page struct {
+		path  string
+		exact []byte
+		sum   []byte
+	}
+	var kept []page
+	var dups []Duplicate
+	for _, p := range paths {
+		f, err := os.Open(p)
+		if err != nil {
+			return nil, err
+		}
+		exact, err := compare.HashFromReader(f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		var sum []byte
+		if cmp.Mode.Perceptual() {
+			if sum, err = sumFile(p, cmp, frame); err != nil {
+				return nil, fmt.Errorf("%s: %w", filepath.Base(p), err)
+			}
+		}
+
+		var dup *Duplicate
+		for _, k := range kept {
+			if bytes.Equal(k.exact, exact) {
+				dup = &Duplicate{Path: p, Original: k.path, Exact: true}
+				break
+			}
+			if sum != nil && cmp.Same(k.sum, sum) {
+				dup = &Duplicate{Path: p, Original: k.path, Distance: compare.Distance(k.sum, sum)}
+				break
+			}
+		}
+		if dup != nil {
+			dups = append(dups, *dup)
+			continue
+		}
+		kept = append(kept, page{path: p, exact: exact, sum: sum})
+	}
+	return dups, nil
+}
+
+// Apply は action に従って重複ページを削除または移動し、dir に報告を書き出します。
+func Apply(dir string, dups []Duplicate, action string) error {
+	switch action {
+	case ActionOff:
+		return nil
+	case ActionRemove, ActionFlag:
+	default:
+		return fmt.Errorf("不明な重複ページの扱いです: %q", action)
+	}
+	if action == ActionFlag && len(dups) > 0 {
+		if err := os.MkdirAll(filepath.Join(dir, DuplicatesDirName), 0755); err != nil {
+			return err
+		}
+	}
+	for _, d := range dups {
+		var err error
+		if action == ActionRemove {
+			err = os.Remove(d.Path)
+		} else {
+			err = os.Rename(d.Path, filepath.Join(dir, DuplicatesDirName, filepath.Base(d.Path)))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(filepath.Join(dir, ReportFileName), []byte(Report(dups, action)), 0644)
+}
+
+// Report は重複ページの一覧を人が読める形式で返します。
+func Report(dups []Duplicate, action string) string {
+	var b strings.Builder
+	verb := "削除"
+	if action == ActionFlag {
+		verb = DuplicatesDirName + " へ移動"
+	}
+	fmt.Fprintf(&b, "重複ページ: %d 枚（%s）\n", len(dups), verb)
+	for _, d := range dups {
+		reason := "完全一致"
+		if !d.Exact {
+			reason = fmt.Sprintf("ほぼ同一（距離 %d）", d.Distance)
+		}
+		fmt.Fprintf(&b, "%s\t%s と%s\n", filepath.Base(d.Path), filepath.Base(d.Original), reason)
+	}
+	return b.String()
+}
+
+func sumFile(path string, cmp compare.Comparer, frame image.Point) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, err
+	}
+	if img.Bounds().Size() != frame {
+		cmp.Masks = nil
+	}
+	return cmp.Sum(img)
+}
@@ -0,0 +1,91 @@
+package dedup
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"AutoScreenShot/compare"
+)
+
+// page は w×h の白い画像の black の範囲を黒く塗った画像を dir に name で保存します。
+func page(t *testing.T, dir, name string, w, h int, black image.Rectangle) string {
+	t.Helper()
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(img, black, image.NewUniform(color.Black), image.Point{}, draw.Src)
+	path := filepath.Join(dir, name)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFindMasks(t *testing.T) {
+	frame := image.Pt(64, 64)
+	cmp := compare.Comparer{Mode: compare.ModeAverage, Masks: []image.Rectangle{image.Rect(0, 0, 32, 64)}}
+	tests := []struct {
+		name  string
+		w, h  int
+		a, b  image.Rectangle // 黒く塗る範囲
+		cmp   compare.Comparer
+		dups  int
+		exact bool
+	}{
+		{"同じ内容", 64, 64, image.Rect(0, 0, 10, 10), image.Rect(0, 0, 10, 10), compare.Comparer{}, 1, true},
+		{"完全一致モードでは見た目は比べない", 64, 64, image.Rect(0, 0, 10, 10), image.Rect(0, 0, 10, 11), compare.Comparer{}, 0, false},
+		{"除外範囲の中だけが違う", 64, 64, image.Rect(0, 0, 32, 32), image.Rect(0, 32, 32, 64), cmp, 1, false},
+		{"除外範囲の外が違う", 64, 64, image.Rect(32, 0, 64, 32), image.Rect(32, 32, 64, 64), cmp, 0, false},
+		// 分けたページ（キャプチャ範囲より小さい）には除外範囲を使わない
+		{"分けたページ", 32, 64, image.Rect(0, 0, 16, 64), image.Rect(0, 0, 32, 32), cmp, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			paths := []string{
+				page(t, dir, "screenshot_00001.png", tt.w, tt.h, tt.a),
+				page(t, dir, "screenshot_00002.png", tt.w, tt.h, tt.b),
+			}
+			dups, err := Find(paths, tt.cmp, frame)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(dups) != tt.dups {
+				t.Fatalf("重複 %d 枚, want %d: %+v", len(dups), tt.dups, dups)
+			}
+			if tt.dups > 0 {
+				d := dups[0]
+				if d.Path != paths[1] || d.Original != paths[0] || d.Exact != tt.exact {
+					t.Errorf("重複 %+v", d)
+				}
+			}
+		})
+	}
+}
+
+func TestFindPointsToFirst(t *testing.T) {
+	dir := t.TempDir()
+	r := image.Rect(0, 0, 8, 8)
+	paths := []string{
+		page(t, dir, "1.png", 16, 16, r),
+		page(t, dir, "2.png", 16, 16, image.Rect(8, 8, 16, 16)),
+		page(t, dir, "3.png", 16, 16, r),
+		page(t, dir, "4.png", 16, 16, r),
+	}
+	dups, err := Find(paths, compare.Comparer{}, image.Pt(16, 16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dups) != 2 || dups[0].Original != paths[0] || dups[1].Original != paths[0] {
+		t.Errorf("重複 %+v", dups)
+	}
+}
@@ -0,0 +1,170 @@
+//go:build linux
+
+package focus
+
+import (
+	"image"
+	"slices"
+	"sync"
+
+	"github.com/jezek/xgb"
+	"github.com/jezek/xgb/xproto"
+)
+
+var (
+	connOnce sync.Once
+	conn     *xgb.Conn
+	connErr  error
+)
+
+// display は X サーバへの接続を返します。
+func display() (*xgb.Conn, error) {
+	connOnce.Do(func() { conn, connErr = xgb.NewConn() })
+	return conn, connErr
+}
+
+// atom は名前に対応するアトムを返します。
+func atom(c *xgb.Conn, name string) xproto.Atom {
+	r, err := xproto.InternAtom(c, false, uint16(len(name)), name).Reply()
+	if err != nil {
+		return xproto.AtomNone
+	}
+	return r.Atom
+}
+
+// values32 は Format 32 のプロパティの値（ウィンドウやアトムの並び）を読み出します。半端なバイトは無視します。
+func values32(b []byte) []uint32 {
+	v := make([]uint32, 0, len(b)/4)
+	for i := 0; i+4 <= len(b); i += 4 {
+		v = append(v, xgb.Get32(b[i:]))
+	}
+	return v
+}
+
+// pickTitle は _NET_WM_NAME（UTF-8）があればそれを、無ければ WM_NAME をタイトルにします。
+func pickTitle(netName, wmName []byte) string {
+	if len(netName) > 0 {
+		return string(netName)
+	}
+	return string(wmName)
+}
+
+// clientWindows はウィンドウマネージャが管理するトップレベルウィンドウ（_NET_CLIENT_LIST）を返します。
+func clientWindows(c *xgb.Conn) []xproto.Window {
+	root := xproto.Setup(c).DefaultScreen(c).Root
+	r, err := xproto.GetProperty(c, false, root, atom(c, "_NET_CLIENT_LIST"), xproto.AtomWindow, 0, 1<<16).Reply()
+	if err != nil || r.Format != 32 {
+		return nil
+	}
+	wins := make([]xproto.Window, 0, r.ValueLen)
+	for _, v := range values32(r.Value) {
+		wins = append(wins, xproto.Window(v))
+	}
+	return wins
+}
+
+// windowTitle はウィンドウのタイトル（_NET_WM_NAME、無ければ WM_NAME）を返します。
+func windowTitle(c *xgb.Conn, w xproto.Window) string {
+	var netName, wmName []byte
+	if r, err := xproto.GetProperty(c, false, w, atom(c, "_NET_WM_NAME"), atom(c, "UTF8_STRING"), 0, 1<<10).Reply(); err == nil {
+		netName = r.Value
+	}
+	if len(netName) == 0 {
+		if r, err := xproto.GetProperty(c, false, w, xproto.AtomWmName, xproto.AtomString, 0, 1<<10).Reply(); err == nil {
+			wmName = r.Value
+		}
+	}
+	return pickTitle(netName, wmName)
+}
+
+// hidden は最小化されている（_NET_WM_STATE に _NET_WM_STATE_HIDDEN がある）ウィンドウなら true を返します。
+func hidden(c *xgb.Conn, w xproto.Window) bool {
+	r, err := xproto.GetProperty(c, false, w, atom(c, "_NET_WM_STATE"), xproto.AtomAtom, 0, 64).Reply()
+	if err != nil {
+		return false
+	}
+	return slices.Contains(values32(r.Value), uint32(atom(c, "_NET_WM_STATE_HIDDEN")))
+}
+
+// ListVisibleWindowTitles は表示されているトップレベルウィンドウのタイトル一覧を返します。
+func ListVisibleWindowTitles() []string {
+	c, err := display()
+	if err != nil {
+		return nil
+	}
+	var titles []string
+	for _, w := range clientWindows(c) {
+		if hidden(c, w) {
+			continue
+		}
+		if t := windowTitle(c, w); t != "" {
+			titles = append(titles, t)
+		}
+	}
+	return titles
+}
+
+// FindByTitle は指定したタイトルに完全一致する最初のウィンドウを返します。
+func FindByTitle(title string) (Window, bool) {
+	c, err := display()
+	if err != nil {
+		return 0, false
+	}
+	for _, w := range clientWindows(c) {
+		if windowTitle(c, w) == title {
+			return Window(w), true
+		}
+	}
+	return 0, false
+}
+
+// SetForegroundByTitle は指定したタイトルに完全一致する最初のウィンドウを、
+// _NET_ACTIVE_WINDOW の要求でウィンドウマネージャにアクティブにさせます。見つからなければ false を返します。
+func SetForegroundByTitle(title string) bool {
+	w, ok := FindByTitle(title)
+	if !ok {
+		return false
+	}
+	c, err := display()
+	if err != nil {
+		return false
+	}
+	found := xproto.Window(w)
+	root := xproto.Setup(c).DefaultScreen(c).Root
+	// data[0] = 1 はアプリケーションからの要求、data[1] = 0 は現在時刻
+	ev := xproto.ClientMessageEvent{
+		Format: 32,
+		Window: found,
+		Type:   atom(c, "_NET_ACTIVE_WINDOW"),
+		Data:   xproto.ClientMessageDataUnionData32New([]uint32{1, 0, 0, 0, 0}),
+	}
+	mask := uint32(xproto.EventMaskSubstructureRedirect | xproto.EventMaskSubstructureNotify)
+	if err := xproto.SendEventChecked(c, false, root, mask, string(ev.Bytes())).Check(); err != nil {
+		return false
+	}
+	return true
+}
+
+// ClientRect はウィンドウのクライアント領域（ウィンドウマネージャの枠を含まない X11 のウィンドウ）をスクリーン座標で返します。
+// 最小化されているか閉じられている場合は、その状態と空の矩形を返します。
+func ClientRect(w Window) (image.Rectangle, WindowState) {
+	c, err := display()
+	if err != nil {
+		return image.Rectangle{}, StateClosed
+	}
+	win := xproto.Window(w)
+	geom, err := xproto.GetGeometry(c, xproto.Drawable(win)).Reply()
+	if err != nil {
+		return image.Rectangle{}, StateClosed
+	}
+	if hidden(c, win) {
+		return image.Rectangle{}, StateMinimized
+	}
+	root := xproto.Setup(c).DefaultScreen(c).Root
+	pos, err := xproto.TranslateCoordinates(c, win, root, 0, 0).Reply()
+	if err != nil {
+		return image.Rectangle{}, StateClosed
+	}
+	x, y := int(pos.DstX), int(pos.DstY)
+	return image.Rect(x, y, x+int(geom.Width), y+int(geom.Height)), StateNormal
+}
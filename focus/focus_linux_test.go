@@ -0,0 +1,106 @@
+//go:build linux
+
+package focus
+
+import (
+	"image"
+	"reflect"
+	"testing"
+
+	"github.com/jezek/xgb"
+	"github.com/jezek/xgb/xproto"
+)
+
+func TestValues32(t *testing.T) {
+	tests := []struct {
+		b    []byte
+		want []uint32
+	}{
+		{nil, []uint32{}},
+		{[]byte{1, 0, 0, 0, 0x78, 0x56, 0x34, 0x12}, []uint32{1, 0x12345678}},
+		{[]byte{2, 0, 0, 0, 9, 9}, []uint32{2}}, // 半端なバイトは無視する
+	}
+	for _, tt := range tests {
+		if got := values32(tt.b); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("values32(%v) = %v, want %v", tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestPickTitle(t *testing.T) {
+	tests := []struct {
+		net, wm string
+		want    string
+	}{
+		{"ビューア - 本", "viewer", "ビューア - 本"},
+		{"", "viewer", "viewer"},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		if got := pickTitle([]byte(tt.net), []byte(tt.wm)); got != tt.want {
+			t.Errorf("pickTitle(%q, %q) = %q, want %q", tt.net, tt.wm, got, tt.want)
+		}
+	}
+}
+
+// TestX11Window は X サーバにウィンドウを作って、タイトル・最小化・位置の読み取りを確かめます。
+// X サーバに接続できない環境（DISPLAY が無いなど）では飛ばします。
+func TestX11Window(t *testing.T) {
+	c, err := display()
+	if err != nil {
+		t.Skipf("X サーバに接続できません: %v", err)
+	}
+	screen := xproto.Setup(c).DefaultScreen(c)
+	create := func(x, y int16, w, h uint16) xproto.Window {
+		t.Helper()
+		id, err := xproto.NewWindowId(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := xproto.CreateWindowChecked(c, screen.RootDepth, id, screen.Root, x, y, w, h, 0,
+			xproto.WindowClassInputOutput, screen.RootVisual, 0, nil).Check(); err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	setProp := func(w xproto.Window, prop, typ xproto.Atom, format byte, data []byte) {
+		t.Helper()
+		if err := xproto.ChangePropertyChecked(c, xproto.PropModeReplace, w, prop, typ, format,
+			uint32(len(data))/uint32(format/8), data).Check(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	win := create(5, 7, 40, 30)
+	defer xproto.DestroyWindow(c, win)
+	setProp(win, atom(c, "_NET_WM_NAME"), atom(c, "UTF8_STRING"), 8, []byte("ビューア - 本"))
+	setProp(win, xproto.AtomWmName, xproto.AtomString, 8, []byte("viewer"))
+	if got := windowTitle(c, win); got != "ビューア - 本" {
+		t.Errorf("windowTitle = %q, want the _NET_WM_NAME", got)
+	}
+	if r, state := ClientRect(Window(win)); state != StateNormal || r != image.Rect(5, 7, 45, 37) {
+		t.Errorf("ClientRect = %v, %v", r, state)
+	}
+
+	old := create(0, 0, 10, 10)
+	defer xproto.DestroyWindow(c, old)
+	setProp(old, xproto.AtomWmName, xproto.AtomString, 8, []byte("viewer"))
+	if got := windowTitle(c, old); got != "viewer" {
+		t.Errorf("windowTitle = %q, want the WM_NAME", got)
+	}
+	state := make([]byte, 4)
+	xgb.Put32(state, uint32(atom(c, "_NET_WM_STATE_HIDDEN")))
+	setProp(old, atom(c, "_NET_WM_STATE"), xproto.AtomAtom, 32, state)
+	if !hidden(c, old) {
+		t.Error("a window with _NET_WM_STATE_HIDDEN should be hidden")
+	}
+	if _, state := ClientRect(Window(old)); state != StateMinimized {
+		t.Errorf("ClientRect state = %v, want minimized", state)
+	}
+
+	gone := create(0, 0, 10, 10)
+	xproto.DestroyWindow(c, gone)
+	if _, state := ClientRect(Window(gone)); state != StateClosed {
+		t.Errorf("ClientRect of a destroyed window = %v, want closed", state)
+	}
+}
@@ -3,6 +3,7 @@
 package focus
 
 import (
+	"image"
 	"syscall"
 	"unsafe"
 
@@ -13,6 +14,7 @@ var (
 	user32             = syscall.NewLazyDLL("user32.dll")
 	procEnumWindows    = user32.NewProc("EnumWindows")
 	procGetWindowTextW = user32.NewProc("GetWindowTextW")
+	procIsWindow       = user32.NewProc("IsWindow")
 )
 
 // ListVisibleWindowTitles は表示されているトップレベルウィンドウのタイトル一覧を返します。
@@ -46,9 +48,8 @@ func getWindowText(hwnd win.HWND, buf []uint16) (int, error) {
 	return n, nil
 }
 
-// SetForegroundByTitle は指定したタイトルに完全一致する最初の表示中ウィンドウを前面にします。
-// 見つからなければ false を返します。
-func SetForegroundByTitle(title string) bool {
+// FindByTitle は指定したタイトルに完全一致する最初の表示中ウィンドウを返します。
+func FindByTitle(title string) (Window, bool) {
 	var found win.HWND
 	cb := syscall.NewCallback(func(hwnd win.HWND, lParam uintptr) uintptr {
 		if win.IsWindowVisible(hwnd) {
@@ -62,8 +63,34 @@ func SetForegroundByTitle(title string) bool {
 		return 1
 	})
 	_, _, _ = procEnumWindows.Call(cb, 0)
-	if found == 0 {
+	return Window(found), found != 0
+}
+
+// SetForegroundByTitle は指定したタイトルに完全一致する最初の表示中ウィンドウを前面にします。
+// 見つからなければ false を返します。
+func SetForegroundByTitle(title string) bool {
+	w, ok := FindByTitle(title)
+	if !ok {
 		return false
 	}
-	return win.SetForegroundWindow(found)
+	return win.SetForegroundWindow(win.HWND(w))
+}
+
+// ClientRect はウィンドウのクライアント領域をスクリーン座標で返します。
+// 最小化されているか閉じられている場合は、その状態と空の矩形を返します。
+func ClientRect(w Window) (image.Rectangle, WindowState) {
+	hwnd := win.HWND(w)
+	if r, _, _ := procIsWindow.Call(uintptr(hwnd)); r == 0 {
+		return image.Rectangle{}, StateClosed
+	}
+	if win.IsIconic(hwnd) {
+		return image.Rectangle{}, StateMinimized
+	}
+	var rc win.RECT
+	if !win.GetClientRect(hwnd, &rc) {
+		return image.Rectangle{}, StateClosed
+	}
+	pt := win.POINT{}
+	win.ClientToScreen(hwnd, &pt)
+	return image.Rect(int(pt.X), int(pt.Y), int(pt.X+rc.Right-rc.Left), int(pt.Y+rc.Bottom-rc.Top)), StateNormal
 }
@@ -0,0 +1,13 @@
+package focus
+
+// Window は FindByTitle で見つけたウィンドウです（Windows は HWND、Linux は X11 のウィンドウ ID）。
+type Window uintptr
+
+// WindowState はウィンドウの状態です。
+type WindowState int
+
+const (
+	StateNormal    WindowState = iota // 表示されている
+	StateMinimized                    // 最小化されている
+	StateClosed                       // 閉じられた（ウィンドウが存在しない）
+)
@@ -4,15 +4,16 @@ go 1.21
 
 require (
 	github.com/dacapoday/sendinput v0.1.2
+	github.com/jezek/xgb v1.1.0
 	github.com/jung-kurt/gofpdf v1.16.2
 	github.com/kbinani/screenshot v0.0.0-20230812210009-b87d31814237
 	github.com/lxn/walk v0.0.0-20210112085537-c389da54e794
 	github.com/lxn/win v0.0.0-20210218163916-a377121e959e
+	golang.org/x/image v0.12.0
+	gopkg.in/Knetic/govaluate.v3 v3.0.0
 )
 
 require (
 	github.com/gen2brain/shm v0.0.0-20230802011745-f2460f5984f7 // indirect
-	github.com/jezek/xgb v1.1.0 // indirect
 	golang.org/x/sys v0.11.0 // indirect
-	gopkg.in/Knetic/govaluate.v3 v3.0.0 // indirect
 )
@@ -0,0 +1,56 @@
+package imgutil
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// Crop は img の r の部分を左上が (0, 0) の新しい画像にコピーして返します（元の画像を保持し続けないように）。
+// パレット画像はパレットのままにします。
+func Crop(img image.Image, r image.Rectangle) image.Image {
+	if p, ok := img.(*image.Paletted); ok {
+		dst := image.NewPaletted(image.Rect(0, 0, r.Dx(), r.Dy()), p.Palette)
+		draw.Draw(dst, dst.Bounds(), img, r.Min, draw.Src)
+		return dst
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, r.Min, draw.Src)
+	return dst
+}
+
+// EdgeColor は r の縁の画素で最も多い色（ページの余白の色）を返します。JPG のノイズでばらけないよう、色は下位ビットを落として数えます。
+func EdgeColor(img image.Image, r image.Rectangle) color.Color {
+	counts := map[color.RGBA]int{}
+	var best color.RGBA
+	add := func(x, y int) {
+		c := color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+		c = color.RGBA{R: c.R &^ 7, G: c.G &^ 7, B: c.B &^ 7, A: 255}
+		counts[c]++
+		if counts[c] > counts[best] {
+			best = c
+		}
+	}
+	for x := r.Min.X; x < r.Max.X; x++ {
+		add(x, r.Min.Y)
+		add(x, r.Max.Y-1)
+	}
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		add(r.Min.X, y)
+		add(r.Max.X-1, y)
+	}
+	return best
+}
+
+// ColorDiff は2色の RGB の差の最大値（0〜255）を返します。
+func ColorDiff(a, b color.Color) int {
+	r1, g1, b1, _ := a.RGBA()
+	r2, g2, b2, _ := b.RGBA()
+	d := func(x, y uint32) int {
+		if x > y {
+			return int(x-y) >> 8
+		}
+		return int(y-x) >> 8
+	}
+	return max(d(r1, r2), d(g1, g2), d(b1, b2))
+}
@@ -0,0 +1,71 @@
+package imgutil
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestColorDiff(t *testing.T) {
+	tests := []struct {
+		a, b color.Color
+		want int
+	}{
+		{color.White, color.White, 0},
+		{color.White, color.Black, 255},
+		{color.RGBA{R: 10, G: 20, B: 30, A: 255}, color.RGBA{R: 15, G: 5, B: 30, A: 255}, 15},
+		{color.Gray{Y: 100}, color.RGBA{R: 100, G: 100, B: 100, A: 255}, 0},
+	}
+	for _, tt := range tests {
+		if got := ColorDiff(tt.a, tt.b); got != tt.want {
+			t.Errorf("ColorDiff(%v, %v) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestEdgeColor(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 10; x++ {
+			img.Set(x, y, color.RGBA{R: 250, G: 250, B: 250, A: 255})
+		}
+	}
+	img.Set(0, 0, color.Black)
+	img.Set(5, 5, color.Black)
+	// 下位ビットを落とした色になる
+	if got, want := EdgeColor(img, img.Bounds()), (color.RGBA{R: 248, G: 248, B: 248, A: 255}); got != want {
+		t.Errorf("EdgeColor = %v, want %v", got, want)
+	}
+}
+
+func TestCrop(t *testing.T) {
+	pal := color.Palette{color.White, color.Black}
+	tests := []struct {
+		name string
+		img  image.Image
+	}{
+		{"RGBA", image.NewRGBA(image.Rect(0, 0, 20, 10))},
+		{"パレット画像", image.NewPaletted(image.Rect(0, 0, 20, 10), pal)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := tt.img.(interface {
+				image.Image
+				Set(x, y int, c color.Color)
+			})
+			src.Set(12, 4, color.Black)
+			got := Crop(tt.img, image.Rect(10, 2, 15, 8))
+			if got.Bounds() != image.Rect(0, 0, 5, 6) {
+				t.Fatalf("Bounds = %v", got.Bounds())
+			}
+			if r, _, _, _ := got.At(2, 2).RGBA(); r != 0 {
+				t.Errorf("切り出した位置の色が違います: %v", got.At(2, 2))
+			}
+			if _, ok := tt.img.(*image.Paletted); ok {
+				if _, ok := got.(*image.Paletted); !ok {
+					t.Errorf("パレット画像のままではありません: %T", got)
+				}
+			}
+		})
+	}
+}
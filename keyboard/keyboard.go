@@ -14,8 +14,28 @@ func Send(keyOperation string) error {
 	if keyOperation == "" {
 		return nil
 	}
+	modifiers, main := parse(keyOperation)
+	// 修飾キーを押す
+	for _, m := range modifiers {
+		_ = sendinput.SendKeyboardInput(m, true)
+	}
+	// メインキーを押して離す
+	if err := sendinput.SendKeyboardInput(main, true); err != nil {
+		releaseModifiers(modifiers)
+		return err
+	}
+	if err := sendinput.SendKeyboardInput(main, false); err != nil {
+		releaseModifiers(modifiers)
+		return err
+	}
+	// 修飾キーを離す（逆順）
+	releaseModifiers(modifiers)
+	return nil
+}
+
+// parse はキー操作文字列を修飾キーとメインキーのキーコード（仮想キーコード）に分けます。
+func parse(keyOperation string) (modifiers []sendinput.KeyCode, main sendinput.KeyCode) {
 	parts := strings.Split(keyOperation, "+")
-	var modifiers []sendinput.KeyCode
 	for i, p := range parts {
 		p = strings.TrimSpace(p)
 		parts[i] = strings.ToUpper(p)
@@ -40,7 +60,7 @@ func Send(keyOperation string) error {
 	if mainKey == "" {
 		mainKey = strings.TrimSpace(strings.ToUpper(keyOperation))
 	}
-	main := sendinput.Key(mainKey)
+	main = sendinput.Key(mainKey)
 	if main == 0 && len(mainKey) == 1 {
 		main = sendinput.KeyCode(mainKey[0])
 		if main >= 0x41 && main <= 0x5A {
@@ -51,22 +71,7 @@ func Send(keyOperation string) error {
 			main = sendinput.Key(mainKey)
 		}
 	}
-	// 修飾キーを押す
-	for _, m := range modifiers {
-		_ = sendinput.SendKeyboardInput(m, true)
-	}
-	// メインキーを押して離す
-	if err := sendinput.SendKeyboardInput(main, true); err != nil {
-		releaseModifiers(modifiers)
-		return err
-	}
-	if err := sendinput.SendKeyboardInput(main, false); err != nil {
-		releaseModifiers(modifiers)
-		return err
-	}
-	// 修飾キーを離す（逆順）
-	releaseModifiers(modifiers)
-	return nil
+	return modifiers, main
 }
 
 func releaseModifiers(modifiers []sendinput.KeyCode) {
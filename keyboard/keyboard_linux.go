@@ -0,0 +1,116 @@
+//go:build linux
+
+package keyboard
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/jezek/xgb"
+	"github.com/jezek/xgb/xproto"
+	"github.com/jezek/xgb/xtest"
+)
+
+var (
+	connOnce sync.Once
+	conn     *xgb.Conn
+	connErr  error
+)
+
+// display は X サーバへの接続を返します。最初の呼び出しで接続し、XTEST 拡張を初期化します。
+func display() (*xgb.Conn, error) {
+	connOnce.Do(func() {
+		if conn, connErr = xgb.NewConn(); connErr != nil {
+			return
+		}
+		if connErr = xtest.Init(conn); connErr != nil {
+			connErr = fmt.Errorf("XTEST 拡張が使えません: %w", connErr)
+		}
+	})
+	return conn, connErr
+}
+
+// keysyms はキー操作の名前（Windows 版と同じ名前）から X11 の keysym への対応です。
+var keysyms = map[string]xproto.Keysym{
+	"ENTER": 0xff0d, "RETURN": 0xff0d, "TAB": 0xff09, "SPACE": 0x20, "BACKSPACE": 0xff08,
+	"ESCAPE": 0xff1b, "ESC": 0xff1b, "INSERT": 0xff63, "DELETE": 0xffff,
+	"HOME": 0xff50, "END": 0xff57, "PAGEUP": 0xff55, "PAGEDOWN": 0xff56, "PRIOR": 0xff55, "NEXT": 0xff56,
+	"ARROWLEFT": 0xff51, "ARROWUP": 0xff52, "ARROWRIGHT": 0xff53, "ARROWDOWN": 0xff54,
+	"LEFT": 0xff51, "UP": 0xff52, "RIGHT": 0xff53, "DOWN": 0xff54,
+	"CTRL": 0xffe3, "CONTROL": 0xffe3, "SHIFT": 0xffe1, "ALT": 0xffe9, "WIN": 0xffeb,
+}
+
+// keysym はキーの名前を keysym に変換します。1文字の英数字はその文字の keysym です。
+func keysym(name string) (xproto.Keysym, bool) {
+	if s, ok := keysyms[name]; ok {
+		return s, true
+	}
+	if len(name) == 1 && (name[0] >= 'A' && name[0] <= 'Z' || name[0] >= '0' && name[0] <= '9') {
+		// 英字は小文字の keysym（Shift なしで入力される文字）を使う
+		return xproto.Keysym(strings.ToLower(name)[0]), true
+	}
+	var n int
+	if _, err := fmt.Sscanf(name, "F%d", &n); err == nil && n >= 1 && n <= 24 {
+		return xproto.Keysym(0xffbe + n - 1), true
+	}
+	return 0, false
+}
+
+// Send はキー操作文字列（例: "Enter", "Tab", "Ctrl+C"）を XTEST 拡張で1回送信します。
+func Send(keyOperation string) error {
+	keyOperation = strings.TrimSpace(keyOperation)
+	if keyOperation == "" {
+		return nil
+	}
+	c, err := display()
+	if err != nil {
+		return err
+	}
+	codes, err := keycodes(c, strings.Split(keyOperation, "+"))
+	if err != nil {
+		return err
+	}
+	root := xproto.Setup(c).DefaultScreen(c).Root
+	// 修飾キー、メインキーの順に押し、逆順に離す
+	for _, k := range codes {
+		xtest.FakeInput(c, xproto.KeyPress, byte(k), 0, root, 0, 0, 0)
+	}
+	for i := len(codes) - 1; i >= 0; i-- {
+		xtest.FakeInput(c, xproto.KeyRelease, byte(codes[i]), 0, root, 0, 0, 0)
+	}
+	// 往復するリクエストで、送信したイベントが処理されるまで待つ
+	_, err = xproto.GetInputFocus(c).Reply()
+	return err
+}
+
+// keycodes は "Ctrl", "C" のような各部分を、現在のキーボード配列のキーコードに変換します。
+func keycodes(c *xgb.Conn, parts []string) ([]xproto.Keycode, error) {
+	setup := xproto.Setup(c)
+	count := byte(setup.MaxKeycode - setup.MinKeycode + 1)
+	m, err := xproto.GetKeyboardMapping(c, setup.MinKeycode, count).Reply()
+	if err != nil {
+		return nil, err
+	}
+	per := int(m.KeysymsPerKeycode)
+	codes := make([]xproto.Keycode, 0, len(parts))
+	for _, p := range parts {
+		name := strings.ToUpper(strings.TrimSpace(p))
+		sym, ok := keysym(name)
+		if !ok {
+			return nil, fmt.Errorf("キー %q には対応していません", p)
+		}
+		code := xproto.Keycode(0)
+		for i, s := range m.Keysyms {
+			if s == sym {
+				code = setup.MinKeycode + xproto.Keycode(i/per)
+				break
+			}
+		}
+		if code == 0 {
+			return nil, fmt.Errorf("キー %q がキーボード配列にありません", p)
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
@@ -0,0 +1,37 @@
+//go:build linux
+
+package keyboard
+
+import (
+	"testing"
+
+	"github.com/jezek/xgb/xproto"
+)
+
+func TestKeysym(t *testing.T) {
+	tests := []struct {
+		name string
+		want xproto.Keysym
+		ok   bool
+	}{
+		{"ENTER", 0xff0d, true},
+		{"PAGEDOWN", 0xff56, true},
+		{"RIGHT", 0xff53, true},
+		{"CTRL", 0xffe3, true},
+		{"C", 'c', true},
+		{"7", '7', true},
+		{"F1", 0xffbe, true},
+		{"F12", 0xffc9, true},
+		{"F25", 0, false},
+		{"HYPER", 0, false},
+		{"@", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := keysym(tt.name)
+			if ok != tt.ok || got != tt.want {
+				t.Errorf("keysym(%q) = %#x, %v, want %#x, %v", tt.name, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package keyboard
+
+import (
+	"context"
+	"time"
+)
+
+// watchInterval はホットキーの状態を調べる間隔です。
+const watchInterval = 30 * time.Millisecond
+
+// watch は ctx が終わるまで pressed を調べ続け、押されていない状態から押された状態に変わるたびに onPress を呼びます。
+func watch(ctx context.Context, pressed func() (bool, error), onPress func()) error {
+	down := true // 監視を始めた時点で押されていたキーは、一度離すまで数えない
+	t := time.NewTicker(watchInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+		}
+		now, err := pressed()
+		if err != nil {
+			return err
+		}
+		if now && !down {
+			onPress()
+		}
+		down = now
+	}
+}
@@ -0,0 +1,40 @@
+//go:build linux
+
+package keyboard
+
+import (
+	"context"
+	"strings"
+
+	"github.com/jezek/xgb/xproto"
+)
+
+// Watch は ctx が終わるまでキー操作 keyOperation（例: "F9", "Ctrl+M"）が押されるのを監視し、押されるたびに onPress を呼びます。
+// キーは対象アプリにもそのまま届くため、アプリが使わないキーを選んでください。
+func Watch(ctx context.Context, keyOperation string, onPress func()) error {
+	keyOperation = strings.TrimSpace(keyOperation)
+	if keyOperation == "" {
+		return nil
+	}
+	c, err := display()
+	if err != nil {
+		return err
+	}
+	codes, err := keycodes(c, strings.Split(keyOperation, "+"))
+	if err != nil {
+		return err
+	}
+	return watch(ctx, func() (bool, error) {
+		// 押されているキーの一覧（キーコードごとに1ビット）
+		m, err := xproto.QueryKeymap(c).Reply()
+		if err != nil {
+			return false, err
+		}
+		for _, k := range codes {
+			if m.Keys[k/8]&(1<<(k%8)) == 0 {
+				return false, nil
+			}
+		}
+		return true, nil
+	}, onPress)
+}
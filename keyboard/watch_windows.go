@@ -0,0 +1,49 @@
+//go:build windows
+
+package keyboard
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"syscall"
+
+	"github.com/dacapoday/sendinput"
+)
+
+var procGetAsyncKeyState = syscall.NewLazyDLL("user32.dll").NewProc("GetAsyncKeyState")
+
+// Watch は ctx が終わるまでキー操作 keyOperation（例: "F9", "Ctrl+M"）が押されるのを監視し、押されるたびに onPress を呼びます。
+// キーは対象アプリにもそのまま届くため、アプリが使わないキーを選んでください。
+func Watch(ctx context.Context, keyOperation string, onPress func()) error {
+	keyOperation = strings.TrimSpace(keyOperation)
+	if keyOperation == "" {
+		return nil
+	}
+	modifiers, main := parse(keyOperation)
+	if main == 0 {
+		return fmt.Errorf("キー %q には対応していません", keyOperation)
+	}
+	keys := []sendinput.KeyCode{main}
+	for _, m := range modifiers {
+		// 左右どちらの修飾キーでもよい
+		switch m {
+		case sendinput.KEY_LCONTROL:
+			m = sendinput.KEY_CONTROL
+		case sendinput.KEY_LMENU:
+			m = sendinput.KEY_MENU
+		case sendinput.KEY_LSHIFT:
+			m = sendinput.KEY_SHIFT
+		}
+		keys = append(keys, m)
+	}
+	return watch(ctx, func() (bool, error) {
+		for _, k := range keys {
+			state, _, _ := procGetAsyncKeyState.Call(uintptr(k))
+			if state&0x8000 == 0 {
+				return false, nil
+			}
+		}
+		return true, nil
+	}, onPress)
+}
@@ -0,0 +1,124 @@
+package layout
+
+import (
+	"image"
+	"image/color"
+
+	"AutoScreenShot/imgutil"
+)
+
+// 1回のキャプチャを複数ページに分ける方法
+const (
+	SplitNone   = ""       // 分けない
+	SplitCenter = "center" // 中央で左右2ページに分ける
+	SplitGutter = "gutter" // のど（中央付近の余白や影）を検出して左右2ページに分ける
+	SplitGrid   = "grid"   // Cols × Rows の格子に分ける
+)
+
+// ページの読み順
+const (
+	OrderLTR = "ltr" // 左から右（横書き）
+	OrderRTL = "rtl" // 右から左（縦書き・マンガ）
+)
+
+// Options は分割の設定です。
+type Options struct {
+	Mode  string // SplitNone / SplitCenter / SplitGutter / SplitGrid
+	Cols  int    // SplitGrid の列数（1 未満なら 1）
+	Rows  int    // SplitGrid の行数（1 未満なら 1）
+	Order string // OrderLTR（空も同じ）または OrderRTL
+}
+
+// Split は img を opt に従って分け、読み順に並べたページを返します。分けない場合は img だけを返します。
+// 行は上から下、行の中は Order の向きに並べます。
+func Split(img image.Image, opt Options) []image.Image {
+	b := img.Bounds()
+	var cells []image.Rectangle
+	switch opt.Mode {
+	case SplitCenter, SplitGutter:
+		x := b.Min.X + b.Dx()/2
+		if opt.Mode == SplitGutter {
+			x = FindGutter(img)
+		}
+		cells = []image.Rectangle{
+			image.Rect(b.Min.X, b.Min.Y, x, b.Max.Y),
+			image.Rect(x, b.Min.Y, b.Max.X, b.Max.Y),
+		}
+		if opt.Order == OrderRTL {
+			cells[0], cells[1] = cells[1], cells[0]
+		}
+	case SplitGrid:
+		cols, rows := max(opt.Cols, 1), max(opt.Rows, 1)
+		for r := 0; r < rows; r++ {
+			y0, y1 := b.Min.Y+b.Dy()*r/rows, b.Min.Y+b.Dy()*(r+1)/rows
+			for i := 0; i < cols; i++ {
+				c := i
+				if opt.Order == OrderRTL {
+					c = cols - 1 - i
+				}
+				x0, x1 := b.Min.X+b.Dx()*c/cols, b.Min.X+b.Dx()*(c+1)/cols
+				cells = append(cells, image.Rect(x0, y0, x1, y1))
+			}
+		}
+	}
+	if len(cells) <= 1 {
+		return []image.Image{img}
+	}
+	pages := make([]image.Image, 0, len(cells))
+	for _, c := range cells {
+		if c.Empty() {
+			continue
+		}
+		pages = append(pages, imgutil.Crop(img, c))
+	}
+	return pages
+}
+
+// gutterBand は FindGutter がのどを探す範囲（中央からの幅の割合）です。
+const gutterBand = 0.1
+
+// FindGutter は見開きの画像から、左右のページの境目（のど）の x 座標を返します。
+// 中央の前後 10% の範囲で、縦方向の明るさのばらつきが最も小さい（文字や絵が無い）列を選びます。
+// 余白と影のどちらでも一様な列になるため、どちらの見開きにも使えます。
+func FindGutter(img image.Image) int {
+	b := img.Bounds()
+	center := b.Min.X + b.Dx()/2
+	band := int(float64(b.Dx()) * gutterBand)
+	x0, x1 := max(b.Min.X, center-band), min(b.Max.X, center+band+1)
+	if x1-x0 < 1 || b.Dy() == 0 {
+		return center
+	}
+	// 列ごとの輝度の分散を求め、隣り合う列で平均して細い線に反応しにくくする
+	vars := make([]float64, x1-x0)
+	step := max(1, b.Dy()/400)
+	for x := x0; x < x1; x++ {
+		var sum, sq, n float64
+		for y := b.Min.Y; y < b.Max.Y; y += step {
+			l := float64(color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y)
+			sum += l
+			sq += l * l
+			n++
+		}
+		mean := sum / n
+		vars[x-x0] = sq/n - mean*mean
+	}
+	best, bestScore := center, -1.0
+	for i := range vars {
+		score := 0.0
+		for j := max(0, i-2); j <= min(len(vars)-1, i+2); j++ {
+			score += vars[j]
+		}
+		x := x0 + i
+		if bestScore < 0 || score < bestScore || score == bestScore && abs(x-center) < abs(best-center) {
+			best, bestScore = x, score
+		}
+	}
+	return best
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,93 @@
+package layout
+
+import (
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+// spread は幅 w の見開きを描きます。gutter の列だけを白く残し、ほかの列には縦に模様のある文字の代わりを描きます。
+func spread(w, h, gutter int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := uint8(255)
+			if (x-gutter < -2 || x-gutter > 2) && (x+y)%3 == 0 {
+				v = 0
+			}
+			img.Set(x, y, color.Gray{v})
+		}
+	}
+	return img
+}
+
+func TestSplit(t *testing.T) {
+	// 画素に座標を書いておき、分けたページがどこから切り出されたか見分ける（原点が 0 でない 100x60）
+	img := image.NewRGBA(image.Rect(10, 20, 110, 80))
+	for y := 20; y < 80; y++ {
+		for x := 10; x < 110; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 0, 255})
+		}
+	}
+	tests := []struct {
+		name string
+		opt  Options
+		want []image.Rectangle
+	}{
+		{"分けない", Options{}, []image.Rectangle{image.Rect(10, 20, 110, 80)}},
+		{"中央で左から", Options{Mode: SplitCenter}, []image.Rectangle{image.Rect(10, 20, 60, 80), image.Rect(60, 20, 110, 80)}},
+		{"中央で右から", Options{Mode: SplitCenter, Order: OrderRTL}, []image.Rectangle{image.Rect(60, 20, 110, 80), image.Rect(10, 20, 60, 80)}},
+		{"格子", Options{Mode: SplitGrid, Cols: 2, Rows: 2}, []image.Rectangle{
+			image.Rect(10, 20, 60, 50), image.Rect(60, 20, 110, 50), image.Rect(10, 50, 60, 80), image.Rect(60, 50, 110, 80),
+		}},
+		{"格子を右から", Options{Mode: SplitGrid, Cols: 2, Rows: 2, Order: OrderRTL}, []image.Rectangle{
+			image.Rect(60, 20, 110, 50), image.Rect(10, 20, 60, 50), image.Rect(60, 50, 110, 80), image.Rect(10, 50, 60, 80),
+		}},
+		{"1x1 の格子は分けない", Options{Mode: SplitGrid}, []image.Rectangle{image.Rect(10, 20, 110, 80)}},
+		{"3列", Options{Mode: SplitGrid, Cols: 3}, []image.Rectangle{
+			image.Rect(10, 20, 43, 80), image.Rect(43, 20, 76, 80), image.Rect(76, 20, 110, 80),
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []image.Rectangle
+			for _, p := range Split(img, tt.opt) {
+				b := p.Bounds()
+				c := p.At(b.Min.X, b.Min.Y).(color.RGBA)
+				got = append(got, image.Rectangle{Min: image.Pt(int(c.R), int(c.G)), Max: image.Pt(int(c.R)+b.Dx(), int(c.G)+b.Dy())})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Split = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindGutter(t *testing.T) {
+	tests := []struct {
+		name   string
+		w      int
+		gutter int
+		want   int
+	}{
+		{"中央", 200, 100, 100},
+		{"中央より左", 200, 92, 92},
+		{"中央より右", 300, 165, 165},
+		{"探す範囲の外なら範囲の中から選ぶ", 200, 40, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FindGutter(spread(tt.w, 120, tt.gutter))
+			if tt.want < 0 {
+				if band := int(float64(tt.w) * gutterBand); abs(got-tt.w/2) > band {
+					t.Errorf("FindGutter = %d, outside the band", got)
+				}
+				return
+			}
+			if got != tt.want {
+				t.Errorf("FindGutter = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
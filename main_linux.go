@@ -0,0 +1,63 @@
+//go:build linux
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"AutoScreenShot/session"
+)
+
+// Linux 版は設定ダイアログが無いため、Windows 版が出力した session.json（または同じ形式の JSON）を読み込んで実行します。
+//
+//	AutoScreenShot -settings session.json [-out 出力先]
+func main() {
+	settingsPath := flag.String("settings", "", "設定の JSON（session.json、またはそれを含むフォルダ）")
+	outDir := flag.String("out", "", "出力先フォルダ（省略時は設定の OutputFolder）")
+	flag.Parse()
+
+	if *settingsPath == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	record, err := session.LoadRecord(*settingsPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "設定の読み込みに失敗しました: %v\n", err)
+		os.Exit(1)
+	}
+	settings := record.Settings
+	if *outDir != "" {
+		settings.OutputFolder = *outDir
+	}
+	if settings.OutputFolder == "" {
+		fmt.Fprintln(os.Stderr, "出力先フォルダを指定してください")
+		os.Exit(2)
+	}
+	if settings.Region.Width <= 0 || settings.Region.Height <= 0 {
+		fmt.Fprintln(os.Stderr, "キャプチャ範囲が設定されていません")
+		os.Exit(2)
+	}
+
+	job, err := session.ScreenJob(settings)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	// Ctrl+C でキャプチャを止め、そこまでのページで PDF を作る
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	res, out, err := job.Run(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Printf("完了: %d 枚保存（同一の %d 枚を削除）、%s に PDF を出力しました。終了理由: %s\n", res.Count, res.Removed, out.PDFPath, res.StopReason)
+	for _, p := range out.Exports() {
+		fmt.Printf("書き出し: %s\n", p)
+	}
+}
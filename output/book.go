@@ -0,0 +1,113 @@
+package output
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"AutoScreenShot/compare"
+)
+
+// BookOptions は CBZ と EPUB の設定です。
+type BookOptions struct {
+	Title       string // 本のタイトル
+	RightToLeft bool   // 右から左に読む（縦書きの本やマンガ）
+	Language    string // EPUB の言語（空なら "ja"）
+	// SkipBlank が true なら、空白（またはほぼ空白）のページを含めません。
+	SkipBlank bool
+	Blank     compare.BlankOptions
+}
+
+// listPages は dir のページを ListImages の順で返します。skipBlank が true なら空白のページを除きます。
+func listPages(dir string, skipBlank bool, blank compare.BlankOptions) ([]string, error) {
+	paths, err := ListImages(dir)
+	if err != nil || !skipBlank {
+		return paths, err
+	}
+	kept := paths[:0]
+	for _, p := range paths {
+		if isBlank, err := isBlankFile(p, blank); err == nil && isBlank {
+			continue
+		}
+		kept = append(kept, p)
+	}
+	return kept, nil
+}
+
+// CountPages は dir の画像を PDF にしたときのページ数を返します。skipBlank が true なら空白のページを数えません。
+func CountPages(dir string, skipBlank bool, blank compare.BlankOptions) (int, error) {
+	paths, err := listPages(dir, skipBlank, blank)
+	return len(paths), err
+}
+
+// bookPage は本に収める1ページの画像です。
+type bookPage struct {
+	data          []byte
+	ext           string // ".jpg" または ".png"
+	mediaType     string
+	width, height int
+}
+
+// loadBookPage はページの画像を読み込みます。JPG と PNG はそのまま使い、
+// リーダーが表示できない TIFF は可逆の PNG に変換します。
+func loadBookPage(path string) (bookPage, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return bookPage{}, err
+	}
+	pg := bookPage{data: data}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jpg", ".jpeg":
+		pg.ext, pg.mediaType = ".jpg", "image/jpeg"
+	case ".png":
+		pg.ext, pg.mediaType = ".png", "image/png"
+	default:
+		img, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return bookPage{}, fmt.Errorf("%s: %w", filepath.Base(path), err)
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			return bookPage{}, err
+		}
+		pg.data, pg.ext, pg.mediaType = buf.Bytes(), ".png", "image/png"
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(pg.data))
+	if err != nil {
+		return bookPage{}, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	pg.width, pg.height = cfg.Width, cfg.Height
+	return pg, nil
+}
+
+// writeZip は outPath に ZIP を作成して fill で中身を書き込みます。失敗したらファイルを削除します。
+func writeZip(outPath string, fill func(zw *zip.Writer) error) error {
+	return writeImage(outPath, func(f *os.File) error {
+		zw := zip.NewWriter(f)
+		if err := fill(zw); err != nil {
+			zw.Close()
+			return err
+		}
+		return zw.Close()
+	})
+}
+
+// addZipFile は name のファイルを ZIP に追加します。store が true なら圧縮しません（圧縮済みの画像や EPUB の mimetype）。
+func addZipFile(zw *zip.Writer, name string, data []byte, store bool) error {
+	h := &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()}
+	if store {
+		h.Method = zip.Store
+	}
+	w, err := zw.CreateHeader(h)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
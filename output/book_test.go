@@ -0,0 +1,191 @@
+package output
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"image"
+	"image/jpeg"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/image/tiff"
+)
+
+// bookDir は JPG・PNG・TIFF のページと、ページではないファイルを置いたフォルダを返します。
+func bookDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	writePNG(t, filepath.Join(dir, "screenshot_00002.png"), colors(3))
+	f, err := os.Create(filepath.Join(dir, "screenshot_00001.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := jpeg.Encode(f, image.NewGray(image.Rect(0, 0, 30, 40)), nil); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	f, err = os.Create(filepath.Join(dir, "screenshot_00003.tif"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tiff.Encode(f, image.NewGray(image.Rect(0, 0, 20, 10)), nil); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	// 書き出したマルチページ TIFF はページのフォルダの中の別のフォルダにある
+	if err := os.Mkdir(filepath.Join(dir, TIFFExportDirName), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{filepath.Join(TIFFExportDirName, "本.tiff"), "run.log"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// readZip は ZIP の中のファイルを順に返します。
+func readZip(t *testing.T, path string) ([]*zip.File, map[string][]byte) {
+	t.Helper()
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { zr.Close() })
+	files := map[string][]byte{}
+	for _, f := range zr.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name] = b
+	}
+	return zr.File, files
+}
+
+func TestBuildCBZ(t *testing.T) {
+	tests := []struct {
+		name  string
+		rtl   bool
+		manga string
+	}{
+		{"左から右", false, "No"},
+		{"右から左", true, "YesAndRightToLeft"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := bookDir(t)
+			out := filepath.Join(dir, "本.cbz")
+			if err := BuildCBZ(dir, out, BookOptions{Title: "本", RightToLeft: tt.rtl}); err != nil {
+				t.Fatal(err)
+			}
+			entries, files := readZip(t, out)
+			var names []string
+			for _, e := range entries {
+				names = append(names, e.Name)
+				if e.Name != ComicInfoFileName && e.Method != zip.Store {
+					t.Errorf("%s is compressed", e.Name)
+				}
+			}
+			// TIFF はリーダーが表示できる PNG にする
+			if want := []string{"0001.jpg", "0002.png", "0003.png", ComicInfoFileName}; !reflect.DeepEqual(names, want) {
+				t.Fatalf("entries = %q, want %q", names, want)
+			}
+			var info comicInfo
+			if err := xml.Unmarshal(files[ComicInfoFileName], &info); err != nil {
+				t.Fatal(err)
+			}
+			if info.Title != "本" || info.PageCount != 3 || info.Manga != tt.manga {
+				t.Errorf("ComicInfo = %+v", info)
+			}
+			want := []comicPage{
+				{Image: 0, Type: "FrontCover", ImageSize: len(files["0001.jpg"]), ImageWidth: 30, ImageHeight: 40},
+				{Image: 1, ImageSize: len(files["0002.png"]), ImageWidth: 32, ImageHeight: 32},
+				{Image: 2, ImageSize: len(files["0003.png"]), ImageWidth: 20, ImageHeight: 10},
+			}
+			if !reflect.DeepEqual(info.Pages, want) {
+				t.Errorf("Pages = %+v, want %+v", info.Pages, want)
+			}
+		})
+	}
+}
+
+func TestBuildEPUB(t *testing.T) {
+	tests := []struct {
+		name      string
+		opt       BookOptions
+		direction string
+		title     string
+		lang      string
+	}{
+		{"左から右", BookOptions{Title: "本"}, "ltr", "本", "ja"},
+		{"右から左", BookOptions{Title: "本", RightToLeft: true, Language: "en"}, "rtl", "本", "en"},
+		{"タイトルをエスケープ", BookOptions{Title: `<A & "B">`}, "ltr", `<A & "B">`, "ja"},
+		{"タイトルが空", BookOptions{}, "ltr", "screenshots", "ja"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := bookDir(t)
+			out := filepath.Join(dir, "本.epub")
+			if err := BuildEPUB(dir, out, tt.opt); err != nil {
+				t.Fatal(err)
+			}
+			entries, files := readZip(t, out)
+			// mimetype は先頭に、圧縮も拡張フィールドも無しで置く
+			first := entries[0]
+			if first.Name != "mimetype" || first.Method != zip.Store || len(first.Extra) != 0 || string(files["mimetype"]) != "application/epub+zip" {
+				t.Errorf("first entry = %s (method %d, extra %d)", first.Name, first.Method, len(first.Extra))
+			}
+			for _, name := range []string{
+				"META-INF/container.xml", "OEBPS/content.opf", "OEBPS/nav.xhtml",
+				"OEBPS/text/p0001.xhtml", "OEBPS/images/p0001.jpg", "OEBPS/images/p0002.png", "OEBPS/images/p0003.png",
+			} {
+				if _, ok := files[name]; !ok {
+					t.Errorf("%s is missing", name)
+				}
+			}
+			// XML として読めること
+			for name, data := range files {
+				if !strings.HasSuffix(name, ".xml") && !strings.HasSuffix(name, ".opf") && !strings.HasSuffix(name, ".xhtml") {
+					continue
+				}
+				d := xml.NewDecoder(strings.NewReader(string(data)))
+				for {
+					if _, err := d.Token(); err == io.EOF {
+						break
+					} else if err != nil {
+						t.Fatalf("%s: %v", name, err)
+					}
+				}
+			}
+			var opf struct {
+				Lang     string `xml:"lang,attr"`
+				Title    string `xml:"metadata>title"`
+				Itemrefs []struct {
+					IDRef string `xml:"idref,attr"`
+				} `xml:"spine>itemref"`
+			}
+			if err := xml.Unmarshal(files["OEBPS/content.opf"], &opf); err != nil {
+				t.Fatal(err)
+			}
+			if opf.Title != tt.title || opf.Lang != tt.lang || len(opf.Itemrefs) != 3 {
+				t.Errorf("opf title %q lang %q %d pages, want %q %q 3", opf.Title, opf.Lang, len(opf.Itemrefs), tt.title, tt.lang)
+			}
+			if !strings.Contains(string(files["OEBPS/content.opf"]), `page-progression-direction="`+tt.direction+`"`) {
+				t.Errorf("page-progression-direction is not %s", tt.direction)
+			}
+			if !strings.Contains(string(files["OEBPS/text/p0001.xhtml"]), `content="width=30, height=40"`) {
+				t.Errorf("p0001.xhtml viewport is not the image size")
+			}
+		})
+	}
+}
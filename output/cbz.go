@@ -0,0 +1,72 @@
+package output
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+)
+
+// ComicInfoFileName は CBZ に収めるメタデータ（ComicRack 形式）のファイル名です。
+const ComicInfoFileName = "ComicInfo.xml"
+
+// comicInfo は ComicInfo.xml の内容です。
+type comicInfo struct {
+	XMLName   xml.Name    `xml:"ComicInfo"`
+	XSI       string      `xml:"xmlns:xsi,attr"`
+	XSD       string      `xml:"xmlns:xsd,attr"`
+	Title     string      `xml:"Title,omitempty"`
+	PageCount int         `xml:"PageCount"`
+	Manga     string      `xml:"Manga"` // 読む向き（YesAndRightToLeft なら右から左）
+	Pages     []comicPage `xml:"Pages>Page"`
+}
+
+type comicPage struct {
+	Image       int    `xml:"Image,attr"`
+	Type        string `xml:"Type,attr,omitempty"`
+	ImageSize   int    `xml:"ImageSize,attr"`
+	ImageWidth  int    `xml:"ImageWidth,attr"`
+	ImageHeight int    `xml:"ImageHeight,attr"`
+}
+
+// BuildCBZ は指定フォルダ内の画像を BuildPDF と同じページ順でコミックアーカイブ（CBZ）にまとめ、outPath に保存します。
+// 画像は 0001.jpg, 0002.jpg … の名前で圧縮せずに収め、タイトル・ページ数・読む向きを ComicInfo.xml に記録します。
+func BuildCBZ(dir, outPath string, opt BookOptions) error {
+	paths, err := listPages(dir, opt.SkipBlank, opt.Blank)
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+	info := comicInfo{
+		XSI:       "http://www.w3.org/2001/XMLSchema-instance",
+		XSD:       "http://www.w3.org/2001/XMLSchema",
+		Title:     opt.Title,
+		PageCount: len(paths),
+		Manga:     "No",
+	}
+	if opt.RightToLeft {
+		info.Manga = "YesAndRightToLeft"
+	}
+	return writeZip(outPath, func(zw *zip.Writer) error {
+		for i, p := range paths {
+			pg, err := loadBookPage(p)
+			if err != nil {
+				return err
+			}
+			if err := addZipFile(zw, fmt.Sprintf("%04d%s", i+1, pg.ext), pg.data, true); err != nil {
+				return err
+			}
+			cp := comicPage{Image: i, ImageSize: len(pg.data), ImageWidth: pg.width, ImageHeight: pg.height}
+			if i == 0 {
+				cp.Type = "FrontCover"
+			}
+			info.Pages = append(info.Pages, cp)
+		}
+		data, err := xml.MarshalIndent(info, "", "  ")
+		if err != nil {
+			return err
+		}
+		return addZipFile(zw, ComicInfoFileName, append([]byte(xml.Header), data...), false)
+	})
+}
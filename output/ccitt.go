@@ -0,0 +1,118 @@
+package output
+
+// bitCode は bits の下位 n ビットの符号です。
+type bitCode struct {
+	bits uint32
+	n    uint32
+}
+
+// CCITT T.6 のモード符号（表 1）
+var (
+	codePass = bitCode{0x1, 4} // "0001"
+	codeHorz = bitCode{0x1, 3} // "001"
+	codeEOL  = bitCode{0x1, 12}
+	// codeVert は垂直モードの符号で、添字は a1 - b1 + 3 です（VL3 〜 V0 〜 VR3）。
+	codeVert = [7]bitCode{
+		{0x2, 7}, // VL3 "0000010"
+		{0x2, 6}, // VL2 "000010"
+		{0x2, 3}, // VL1 "010"
+		{0x1, 1}, // V0  "1"
+		{0x3, 3}, // VR1 "011"
+		{0x3, 6}, // VR2 "000011"
+		{0x3, 7}, // VR3 "0000011"
+	}
+)
+
+// bitWriter は符号を上位ビットから詰めてバイト列にします。
+type bitWriter struct {
+	buf []byte
+	acc uint64
+	n   uint32 // acc の下位に溜まっているビット数
+}
+
+func (w *bitWriter) write(c bitCode) {
+	w.acc = w.acc<<c.n | uint64(c.bits)
+	w.n += c.n
+	for w.n >= 8 {
+		w.n -= 8
+		w.buf = append(w.buf, byte(w.acc>>w.n))
+	}
+}
+
+// bytes は残りのビットを 0 で埋めてバイト境界にそろえ、書き込んだバイト列を返します。
+func (w *bitWriter) bytes() []byte {
+	if w.n > 0 {
+		w.buf = append(w.buf, byte(w.acc<<(8-w.n)))
+		w.acc, w.n = 0, 0
+	}
+	return w.buf
+}
+
+// encodeG4 は白黒の画像（rows[y][x] が true なら黒）を CCITT T.6（G4）で符号化します。
+func encodeG4(rows [][]bool, width int) []byte {
+	w := &bitWriter{}
+	ref := make([]bool, width) // 1行目の参照行はすべて白
+	for _, cur := range rows {
+		a0, black := -1, false
+		for a0 < width {
+			a1 := nextChange(cur, a0)
+			b1 := nextChange(ref, a0)
+			// b1 は a0 の色と反対の色に変わる点（変化点の色は交互なので、多くても1つ飛ばせばよい）
+			if b1 < width && ref[b1] == black {
+				b1 = nextChange(ref, b1)
+			}
+			b2 := nextChange(ref, b1)
+			switch d := a1 - b1; {
+			case b2 < a1:
+				// パスモード: 参照行の b1〜b2 の色の区間が、符号化行の a0 の色の中に収まる
+				w.write(codePass)
+				a0 = b2
+			case -3 <= d && d <= 3:
+				w.write(codeVert[d+3])
+				a0, black = a1, !black
+			default:
+				a2 := nextChange(cur, a1)
+				w.write(codeHorz)
+				writeRun(w, a1-max(a0, 0), black)
+				writeRun(w, a2-a1, !black)
+				a0 = a2
+			}
+		}
+		ref = cur
+	}
+	// EOFB
+	w.write(codeEOL)
+	w.write(codeEOL)
+	return w.bytes()
+}
+
+// nextChange は row で a より右にある最初の変化点（左隣と色が違う画素。左端の外は白とみなす）を返します。無ければ len(row) です。
+func nextChange(row []bool, a int) int {
+	for i := max(a+1, 0); i < len(row); i++ {
+		prev := false
+		if i > 0 {
+			prev = row[i-1]
+		}
+		if row[i] != prev {
+			return i
+		}
+	}
+	return len(row)
+}
+
+// writeRun は長さ n の白または黒の連長を、補助符号と終端符号で書き込みます。
+func writeRun(w *bitWriter, n int, black bool) {
+	term, makeup := whiteTermCodes[:], whiteMakeupCodes[:]
+	if black {
+		term, makeup = blackTermCodes[:], blackMakeupCodes[:]
+	}
+	for n >= 2560 {
+		w.write(makeup[len(makeup)-1])
+		n -= 2560
+	}
+	if n >= 64 {
+		w.write(makeup[n/64-1])
+		n %= 64
+	}
+	w.write(term[n])
+}
@@ -0,0 +1,50 @@
+package output
+
+import (
+	"bytes"
+	"image"
+	"math/rand"
+	"testing"
+
+	"golang.org/x/image/ccitt"
+)
+
+func TestEncodeG4(t *testing.T) {
+	const w, h = 200, 40
+	rnd := rand.New(rand.NewSource(1))
+	tests := []struct {
+		name  string
+		black func(x, y int) bool
+	}{
+		{"白一色", func(x, y int) bool { return false }},
+		{"黒一色", func(x, y int) bool { return true }},
+		{"縦線", func(x, y int) bool { return x%10 == 0 }},
+		{"斜めの線（垂直モード）", func(x, y int) bool { return (x+y)%17 < 3 }},
+		{"長い連長（補助符号）", func(x, y int) bool { return x >= 5 && x < 5+64+y }},
+		{"右端まで黒", func(x, y int) bool { return x >= w-1-y%3 }},
+		{"ばらばら", func(x, y int) bool { return rnd.Intn(4) == 0 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows := make([][]bool, h)
+			for y := range rows {
+				rows[y] = make([]bool, w)
+				for x := range rows[y] {
+					rows[y][x] = tt.black(x, y)
+				}
+			}
+			data := encodeG4(rows, w)
+			dst := image.NewGray(image.Rect(0, 0, w, h))
+			if err := ccitt.DecodeIntoGray(dst, bytes.NewReader(data), ccitt.MSB, ccitt.Group4, nil); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			for y := range rows {
+				for x := range rows[y] {
+					if black := dst.GrayAt(x, y).Y == 0; black != rows[y][x] {
+						t.Fatalf("pixel (%d,%d) black = %v, want %v", x, y, black, rows[y][x])
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package output
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/rand"
+	"encoding/xml"
+	"fmt"
+	"hash/crc32"
+	"text/template"
+	"time"
+)
+
+// epubPage は EPUB の1ページ（XHTML と画像）です。
+type epubPage struct {
+	bookPage
+	XHTML string // OEBPS からの XHTML のパス
+	Image string // OEBPS からの画像のパス
+	ID    string
+}
+
+func (p epubPage) Width() int        { return p.width }
+func (p epubPage) Height() int       { return p.height }
+func (p epubPage) MediaType() string { return p.mediaType }
+
+// epubBook は EPUB のテンプレートに渡す内容です。
+type epubBook struct {
+	Title, Language, ID, Modified string
+	Direction                     string // ページを送る向き（ltr / rtl）
+	Pages                         []epubPage
+}
+
+var epubFuncs = template.FuncMap{
+	"x": func(s string) string {
+		var b bytes.Buffer
+		xml.EscapeText(&b, []byte(s))
+		return b.String()
+	},
+	"inc": func(i int) int { return i + 1 },
+}
+
+const epubContainer = `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`
+
+var epubOPF = template.Must(template.New("opf").Funcs(epubFuncs).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="bookid" xml:lang="{{x .Language}}" prefix="rendition: http://www.idpf.org/vocab/rendition/#">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="bookid">{{x .ID}}</dc:identifier>
+    <dc:title>{{x .Title}}</dc:title>
+    <dc:language>{{x .Language}}</dc:language>
+    <meta property="dcterms:modified">{{.Modified}}</meta>
+    <meta property="rendition:layout">pre-paginated</meta>
+    <meta property="rendition:orientation">auto</meta>
+    <meta property="rendition:spread">auto</meta>
+  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+{{- range $i, $p := .Pages}}
+    <item id="{{$p.ID}}" href="{{$p.XHTML}}" media-type="application/xhtml+xml"/>
+    <item id="{{$p.ID}}-img" href="{{$p.Image}}" media-type="{{$p.MediaType}}"{{if eq $i 0}} properties="cover-image"{{end}}/>
+{{- end}}
+  </manifest>
+  <spine page-progression-direction="{{.Direction}}">
+{{- range .Pages}}
+    <itemref idref="{{.ID}}"/>
+{{- end}}
+  </spine>
+</package>
+`))
+
+var epubNav = template.Must(template.New("nav").Funcs(epubFuncs).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="{{x .Language}}">
+<head>
+<meta charset="UTF-8"/>
+<title>{{x .Title}}</title>
+</head>
+<body>
+<nav epub:type="toc" id="toc">
+  <ol>
+    <li><a href="{{(index .Pages 0).XHTML}}">{{x .Title}}</a></li>
+  </ol>
+</nav>
+<nav epub:type="page-list" hidden="">
+  <ol>
+{{- range $i, $p := .Pages}}
+    <li><a href="{{$p.XHTML}}">{{inc $i}}</a></li>
+{{- end}}
+  </ol>
+</nav>
+</body>
+</html>
+`))
+
+var epubXHTML = template.Must(template.New("page").Funcs(epubFuncs).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="{{x .Language}}">
+<head>
+<meta charset="UTF-8"/>
+<title>{{x .Title}}</title>
+<meta name="viewport" content="width={{.Page.Width}}, height={{.Page.Height}}"/>
+<style>html, body { margin: 0; padding: 0; } img { display: block; width: 100%; height: 100%; }</style>
+</head>
+<body>
+<img src="../{{.Page.Image}}" alt="" width="{{.Page.Width}}" height="{{.Page.Height}}"/>
+</body>
+</html>
+`))
+
+// BuildEPUB は指定フォルダ内の画像を BuildPDF と同じページ順で固定レイアウトの EPUB 3 にまとめ、outPath に保存します。
+// 1ページを画像の大きさの XHTML 1つにし、目次（nav.xhtml）にはページ一覧も入れます。RightToLeft なら右から左にページを送ります。
+func BuildEPUB(dir, outPath string, opt BookOptions) error {
+	paths, err := listPages(dir, opt.SkipBlank, opt.Blank)
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+	book := epubBook{
+		Title:     opt.Title,
+		Language:  opt.Language,
+		ID:        newBookID(),
+		Modified:  time.Now().UTC().Format("2006-01-02T15:04:05Z"),
+		Direction: "ltr",
+	}
+	if book.Title == "" {
+		book.Title = "screenshots"
+	}
+	if book.Language == "" {
+		book.Language = "ja"
+	}
+	if opt.RightToLeft {
+		book.Direction = "rtl"
+	}
+	return writeZip(outPath, func(zw *zip.Writer) error {
+		if err := addMimetype(zw); err != nil {
+			return err
+		}
+		if err := addZipFile(zw, "META-INF/container.xml", []byte(epubContainer), false); err != nil {
+			return err
+		}
+		for i, p := range paths {
+			pg, err := loadBookPage(p)
+			if err != nil {
+				return err
+			}
+			ep := epubPage{
+				bookPage: pg,
+				ID:       fmt.Sprintf("p%04d", i+1),
+				XHTML:    fmt.Sprintf("text/p%04d.xhtml", i+1),
+				Image:    fmt.Sprintf("images/p%04d%s", i+1, pg.ext),
+			}
+			if err := addZipFile(zw, "OEBPS/"+ep.Image, pg.data, true); err != nil {
+				return err
+			}
+			var buf bytes.Buffer
+			if err := epubXHTML.Execute(&buf, struct {
+				epubBook
+				Page epubPage
+			}{book, ep}); err != nil {
+				return err
+			}
+			if err := addZipFile(zw, "OEBPS/"+ep.XHTML, buf.Bytes(), false); err != nil {
+				return err
+			}
+			ep.data = nil // 書き込んだ画像は早く手放す
+			book.Pages = append(book.Pages, ep)
+		}
+		for _, f := range []struct {
+			name string
+			tmpl *template.Template
+		}{{"OEBPS/content.opf", epubOPF}, {"OEBPS/nav.xhtml", epubNav}} {
+			var buf bytes.Buffer
+			if err := f.tmpl.Execute(&buf, book); err != nil {
+				return err
+			}
+			if err := addZipFile(zw, f.name, buf.Bytes(), false); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// addMimetype は EPUB の決まりどおり、先頭に圧縮も拡張フィールドも無い mimetype を置きます。
+func addMimetype(zw *zip.Writer) error {
+	data := []byte("application/epub+zip")
+	w, err := zw.CreateRaw(&zip.FileHeader{
+		Name:               "mimetype",
+		Method:             zip.Store,
+		CRC32:              crc32.ChecksumIEEE(data),
+		CompressedSize64:   uint64(len(data)),
+		UncompressedSize64: uint64(len(data)),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// newBookID は EPUB の識別子にする UUID（バージョン 4）の URN を返します。
+func newBookID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
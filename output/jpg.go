@@ -3,27 +3,53 @@ package output
 import (
 	"fmt"
 	"image"
-	"image/jpeg"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 )
 
 const defaultJpegQuality = 85
 
-// SaveJPG は画像を指定フォルダに連番の JPG として保存し、ファイルパスを返します。
-func SaveJPG(dir string, index int, img image.Image, quality int) (string, error) {
-	if quality <= 0 {
-		quality = defaultJpegQuality
+// PageFileName は index 番目のキャプチャの、拡張子 ext（".jpg" など）のファイル名を返します。
+// 1回のキャプチャを複数ページに分けた場合は sub（1 から）を付けます（例: screenshot_00012_1.jpg）。sub が 0 なら付けません。
+func PageFileName(index, sub int, ext string) string {
+	if sub > 0 {
+		return fmt.Sprintf("screenshot_%05d_%d%s", index, sub, ext)
 	}
-	name := fmt.Sprintf("screenshot_%05d.jpg", index)
-	path := filepath.Join(dir, name)
-	f, err := os.Create(path)
+	return fmt.Sprintf("screenshot_%05d%s", index, ext)
+}
+
+// ParsePageFileName は PageFileName の形式のファイル名からキャプチャの番号とページ番号を返します。
+// 形式が違えば ok は false です。
+func ParsePageFileName(name string) (index, sub int, ok bool) {
+	s, found := strings.CutPrefix(name, "screenshot_")
+	if !found {
+		return 0, 0, false
+	}
+	s = strings.TrimSuffix(s, filepath.Ext(s))
+	num, subStr, hasSub := strings.Cut(s, "_")
+	index, err := strconv.Atoi(num)
 	if err != nil {
-		return "", err
+		return 0, 0, false
+	}
+	if hasSub {
+		if sub, err = strconv.Atoi(subStr); err != nil {
+			return 0, 0, false
+		}
 	}
-	defer f.Close()
-	if err := jpeg.Encode(f, img, &jpeg.Options{Quality: quality}); err != nil {
-		os.Remove(path)
+	return index, sub, true
+}
+
+// SaveJPG は画像を指定フォルダに連番の JPG として保存し、ファイルパスを返します。
+func SaveJPG(dir string, index int, img image.Image, quality int) (string, error) {
+	return SavePage(dir, index, 0, img, JPEGWriter{Quality: quality})
+}
+
+// SavePage は1回のキャプチャを分けた sub 番目のページ（sub が 0 なら分けていないページ）を w の形式で保存し、ファイルパスを返します。
+func SavePage(dir string, index, sub int, img image.Image, w ImageWriter) (string, error) {
+	path := filepath.Join(dir, PageFileName(index, sub, w.Ext()))
+	if err := writeImage(path, func(f *os.File) error { return w.Encode(f, img) }); err != nil {
 		return "", err
 	}
 	return path, nil
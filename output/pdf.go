@@ -1,50 +1,152 @@
 package output
 
 import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	"image/png"
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"AutoScreenShot/chapter"
+	"AutoScreenShot/compare"
 
 	"github.com/jung-kurt/gofpdf"
 )
 
-// PixelsToMm は 96 DPI を基準にピクセルを mm に変換します。
+// pixelsPerInch は DPI が分からないときの画像の解像度です。
 const pixelsPerInch = 96
 const mmPerInch = 25.4
 
-func pixelsToMm(pixels int) float64 {
-	return float64(pixels) * mmPerInch / pixelsPerInch
-}
-
-// JPGsToPDF は指定フォルダ内の JPG をファイル名順で1つの PDF に結合し、outPath に保存します。
-// widthPx, heightPx はダイアログで設定したキャプチャ範囲（ピクセル）で、PDF のページサイズに反映されます。
-// title はPDFのメタデータタイトルです。
-func JPGsToPDF(dir, outPath, title string, widthPx, heightPx int) error {
+// ListImages は指定フォルダ直下の画像（JPG・PNG・TIFF）をページ順（キャプチャの番号、分割したページの番号の順）に並べたパスの一覧を返します。
+// PDF などにまとめるときのページ順です。
+func ListImages(dir string) ([]string, error) {
 	entries, err := os.ReadDir(dir)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	var jpgs []string
+	var paths []string
 	for _, e := range entries {
-		if e.IsDir() {
+		if e.IsDir() || !imageExts[strings.ToLower(filepath.Ext(e.Name()))] || e.Name() == StitchedImageName {
 			continue
 		}
-		ext := filepath.Ext(e.Name())
-		if ext != ".jpg" && ext != ".jpeg" {
-			continue
+		paths = append(paths, filepath.Join(dir, e.Name()))
+	}
+	sort.Slice(paths, func(i, j int) bool { return PageLess(filepath.Base(paths[i]), filepath.Base(paths[j])) })
+	return paths, nil
+}
+
+// PageLess はファイル名のページ順の比較です。screenshot_00012_2.jpg が screenshot_00012_10.jpg より前になるよう、
+// キャプチャの番号とページ番号を数値で比べます。形式が違うファイルは名前順で後ろに並べます。
+func PageLess(a, b string) bool {
+	ia, sa, oka := ParsePageFileName(a)
+	ib, sb, okb := ParsePageFileName(b)
+	switch {
+	case oka && okb:
+		if ia != ib {
+			return ia < ib
+		}
+		if sa != sb {
+			return sa < sb
+		}
+	case oka != okb:
+		return oka
+	}
+	return a < b
+}
+
+// PDFOptions は PDF 化の設定です。
+type PDFOptions struct {
+	Metadata PDFMetadata // 文書のプロパティ（タイトル・作成者など）
+	WidthPx  int         // 画像の大きさが読めないときのページの幅（ピクセル。ダイアログで設定したキャプチャ範囲）
+	HeightPx int         // 画像の大きさが読めないときのページの高さ（ピクセル）
+	// SkipBlank が true なら、空白（またはほぼ空白）のページを PDF に含めません。
+	SkipBlank bool
+	Blank     compare.BlankOptions // 空白ページの判定しきい値
+	// RightToLeft が true なら、ビューアーに右から左に読む文書として表示させます（/ViewerPreferences の /Direction /R2L）。
+	RightToLeft bool
+	PageLayout  string // ページの並べ方（PDFLayoutDefault / PDFLayoutSingle / PDFLayoutSpread）
+	// CoverAlone が true なら、見開きで1ページ目（表紙）だけを単独で表示します。
+	CoverAlone bool
+	// Chapters はしおりとページ番号の表示です。ページ番号は画像のファイル名の番号（screenshot_00012.jpg の 12。
+	// 形式の違うファイル名なら何枚目の画像か）で、その画像が PDF に無ければ次の画像のページに付けます。
+	// PDFSizeNUp では用紙と元のページが対応しないため、ページ番号の表示は付けません。
+	Chapters chapter.List
+	PageSize string  // ページの大きさの決め方（PDFSizeImage / PDFSizePaper / PDFSizeNUp）
+	Paper    string  // PDFSizePaper と PDFSizeNUp の用紙（PaperA4 / PaperLetter / PaperB5。空なら A4）
+	MarginMm float64 // PDFSizePaper と PDFSizeNUp の用紙の余白（mm）
+	DPI      float64 // PDFSizeImage で画像の大きさをページの大きさにするときの解像度（0 なら 96）
+	NUp      int     // PDFSizeNUp で用紙1枚に並べるページ数（2 か 4）
+}
+
+// PDF のページの並べ方（/PageLayout）
+const (
+	PDFLayoutDefault = ""       // ビューアーの設定に任せる
+	PDFLayoutSingle  = "single" // 1ページずつ
+	PDFLayoutSpread  = "spread" // 見開き（2ページずつ）
+)
+
+// CheckPDFLayout は l が PDF のページの並べ方であるかを確かめます。
+func CheckPDFLayout(l string) error {
+	switch l {
+	case PDFLayoutDefault, PDFLayoutSingle, PDFLayoutSpread:
+		return nil
+	}
+	return fmt.Errorf("不明な PDF のページの並べ方です: %q", l)
+}
+
+// displayLayout は opt のページの並べ方を gofpdf の SetDisplayMode に渡す名前にします。
+// TwoPageRight は奇数ページを右に置くため、左から右に読む文書では1ページ目が単独になり、
+// 右から左に読む文書では1・2ページ目が見開きになります（TwoPageLeft はその逆）。
+func displayLayout(opt PDFOptions) string {
+	switch opt.PageLayout {
+	case PDFLayoutSingle:
+		return "SinglePage"
+	case PDFLayoutSpread:
+		if opt.CoverAlone != opt.RightToLeft {
+			return "TwoPageRight"
 		}
-		jpgs = append(jpgs, filepath.Join(dir, e.Name()))
+		return "TwoPageLeft"
 	}
-	if len(jpgs) == 0 {
+	return "default"
+}
+
+// JPGsToPDF は指定フォルダ内の画像をページ順で1つの PDF に結合し、outPath に保存します。
+// widthPx, heightPx は画像の大きさが読めないときのページサイズ（ピクセル）で、title は PDF のメタデータのタイトルです。
+// 以前からの呼び出し元のための入口で、中身は既定の設定の BuildPDF です（JPG のほか PNG・TIFF のページも含めます）。
+func JPGsToPDF(dir, outPath, title string, widthPx, heightPx int) error {
+	return BuildPDF(dir, outPath, PDFOptions{Metadata: PDFMetadata{Title: title}, WidthPx: widthPx, HeightPx: heightPx})
+}
+
+// BuildPDF は指定フォルダ内の画像（JPG・PNG・TIFF）をページ順で1つの PDF に結合し、outPath に保存します。
+// JPG と PNG はそのまま埋め込み、TIFF は PDF に埋め込めないため可逆の PNG に変換して埋め込みます。
+// ページの大きさは opt.PageSize に従ってページごとに決めます。PDFSizeImage では画像ごとの大きさ（分割したページや
+// 余白を切り取ったページでも画像に合う）を opt.DPI で mm にしたもので、画像の大きさが読めないときだけ opt の範囲にします。
+func BuildPDF(dir, outPath string, opt PDFOptions) error {
+	paths, err := ListImages(dir)
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
 		return nil
 	}
-	sort.Strings(jpgs)
+	if err := CheckPDFPageSize(opt.PageSize, opt.Paper, opt.NUp); err != nil {
+		return err
+	}
+	if err := CheckPDFInfo(opt.Metadata.Custom); err != nil {
+		return err
+	}
+	pages := newPageLayout(opt)
 
-	// ダイアログで設定した範囲を PDF のページサイズ（mm）に変換
-	wMm := pixelsToMm(widthPx)
-	hMm := pixelsToMm(heightPx)
-	if wMm <= 0 || hMm <= 0 {
-		wMm, hMm = 210, 297 // フォールバック: A4
+	// 画像の大きさが読めないときは、ダイアログで設定した範囲（無ければ A4）の大きさとみなす
+	fallbackW, fallbackH := opt.WidthPx, opt.HeightPx
+	if fallbackW <= 0 || fallbackH <= 0 {
+		fallbackW, fallbackH = int(210*pages.dpi/mmPerInch), int(297*pages.dpi/mmPerInch)
 	}
 
 	pdf := gofpdf.NewCustom(&gofpdf.InitType{
@@ -52,12 +154,22 @@ func JPGsToPDF(dir, outPath, title string, widthPx, heightPx int) error {
 		UnitStr:        "mm",
 		SizeStr:        "",
 		FontDirStr:     "",
-		Size:           gofpdf.SizeType{Wd: wMm, Ht: hMm},
+		Size:           gofpdf.SizeType{Wd: pixelsToMm(fallbackW, pages.dpi), Ht: pixelsToMm(fallbackH, pages.dpi)},
 	})
-	if title != "" {
-		pdf.SetTitle(title, true) // true = UTF-8（日本語対応）
+	modified := time.Now()
+	created := opt.Metadata.Created
+	if created.IsZero() {
+		created = modified
+	}
+	opt.Metadata.apply(pdf, created, modified)
+	if err := CheckPDFLayout(opt.PageLayout); err != nil {
+		return err
 	}
-	for _, path := range jpgs {
+	pdf.SetDisplayMode("default", displayLayout(opt))
+	marks, labels := opt.Chapters.Marks, opt.Chapters.Labels
+	var pageLabels []pageLabel
+	level := -1 // 直前のしおりの階層
+	for i, path := range paths {
 		info, err := os.Stat(path)
 		if err != nil {
 			continue
@@ -65,10 +177,172 @@ func JPGsToPDF(dir, outPath, title string, widthPx, heightPx int) error {
 		if info.Size() == 0 {
 			continue
 		}
-		opt := gofpdf.ImageOptions{ImageType: "JPEG"}
-		pdf.AddPage()
-		w, h := pdf.GetPageSize()
-		pdf.ImageOptions(path, 0, 0, w, h, false, opt, 0, "")
+		if opt.SkipBlank {
+			if blank, err := isBlankFile(path, opt.Blank); err == nil && blank {
+				continue
+			}
+		}
+		name, imgOpt, err := registerImage(pdf, path)
+		if err != nil {
+			return err
+		}
+		pw, ph, err := imageSize(path)
+		if err != nil {
+			pw, ph = fallbackW, fallbackH
+		}
+		p := pages.place(pw, ph)
+		if p.newPage {
+			pdf.AddPageFormat("P", p.size)
+		}
+		pdf.ImageOptions(name, p.x, p.y, p.w, p.h, false, imgOpt, 0, "")
+
+		// このページまでに始まる章のしおりとページ番号の表示
+		num := i + 1
+		if index, _, ok := ParsePageFileName(filepath.Base(path)); ok {
+			num = index
+		}
+		for ; len(marks) > 0 && marks[0].Page <= num; marks = marks[1:] {
+			// gofpdf のしおりは1つ前より2階層以上深くできない
+			level = min(max(marks[0].Level, 0), level+1)
+			pdf.Bookmark(pdfText(marks[0].Title), level, p.y)
+		}
+		for ; len(labels) > 0 && labels[0].Page <= num && opt.PageSize != PDFSizeNUp; labels = labels[1:] {
+			pl := pageLabel{page: pdf.PageNo() - 1, Label: labels[0]}
+			if n := len(pageLabels); n > 0 && pageLabels[n-1].page == pl.page {
+				pageLabels[n-1] = pl // 同じページに付く表示は後のものを使う
+			} else {
+				pageLabels = append(pageLabels, pl)
+			}
+		}
+	}
+	return writePDF(pdf, outPath, catalogEntries(opt, pageLabels), opt.Metadata.embedXMP())
+}
+
+// pdfText は文字列を PDF のテキスト文字列（BOM 付きの UTF-16BE）にします。gofpdf に渡すとエスケープされて書き出されます。
+func pdfText(s string) string {
+	b := []byte{0xFE, 0xFF}
+	for _, u := range utf16.Encode([]rune(s)) {
+		b = append(b, byte(u>>8), byte(u))
+	}
+	return string(b)
+}
+
+// pdfString は s を PDF のリテラル文字列（括弧付き）にします。
+func pdfString(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, "(", `\(`, ")", `\)`, "\r", `\r`)
+	return "(" + r.Replace(pdfText(s)) + ")"
+}
+
+// pageLabel は PDF のページ（0 から）に付けるページ番号の表示です。
+type pageLabel struct {
+	page int
+	chapter.Label
+}
+
+// catalogEntries は gofpdf が書き出せないカタログの項目（読む向きとページ番号の表示）を返します。
+func catalogEntries(opt PDFOptions, labels []pageLabel) []string {
+	var entries []string
+	if opt.RightToLeft {
+		entries = append(entries, "/ViewerPreferences << /Direction /R2L >>")
+	}
+	if len(labels) > 0 {
+		var nums strings.Builder
+		if labels[0].page != 0 {
+			// ページ番号の表示は1ページ目から指定する必要がある
+			nums.WriteString("0 << /S /D >> ")
+		}
+		for _, lb := range labels {
+			fmt.Fprintf(&nums, "%d <<", lb.page)
+			if lb.Style != chapter.StyleNone {
+				fmt.Fprintf(&nums, " /S /%s", lb.Style)
+			}
+			if lb.Prefix != "" {
+				fmt.Fprintf(&nums, " /P %s", pdfString(lb.Prefix))
+			}
+			if lb.Start > 1 {
+				fmt.Fprintf(&nums, " /St %d", lb.Start)
+			}
+			nums.WriteString(" >> ")
+		}
+		entries = append(entries, "/PageLabels << /Nums [ "+nums.String()+"] >>")
+	}
+	return entries
+}
+
+// writePDF は pdf を書き出し、gofpdf が書き出せないカタログの項目を加えて path に保存します。
+func writePDF(pdf *gofpdf.Fpdf, path string, entries []string, xmp bool) error {
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return err
+	}
+	data := buf.Bytes()
+	if xmp {
+		num, err := metadataObject(data)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, fmt.Sprintf("/Metadata %d 0 R", num))
+	}
+	if len(entries) > 0 {
+		var err error
+		if data, err = patchCatalog(data, entries); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// registerImage は画像ファイルを PDF に埋め込める形で登録し、ImageOptions に渡す名前と設定を返します。
+func registerImage(pdf *gofpdf.Fpdf, path string) (string, gofpdf.ImageOptions, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jpg", ".jpeg":
+		return path, gofpdf.ImageOptions{ImageType: "JPEG"}, nil
+	case ".png":
+		return path, gofpdf.ImageOptions{ImageType: "PNG"}, nil
+	}
+	// TIFF などは読み込んで PNG に変換する
+	f, err := os.Open(path)
+	if err != nil {
+		return "", gofpdf.ImageOptions{}, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return "", gofpdf.ImageOptions{}, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", gofpdf.ImageOptions{}, err
+	}
+	opt := gofpdf.ImageOptions{ImageType: "PNG"}
+	pdf.RegisterImageOptionsReader(path, opt, &buf)
+	return path, opt, pdf.Error()
+}
+
+// imageSize は画像ファイルの幅と高さ（ピクセル）を返します。
+func imageSize(path string) (int, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return 0, 0, err
+	}
+	return cfg.Width, cfg.Height, nil
+}
+
+// isBlankFile は画像ファイルが空白ページか返します。
+func isBlankFile(path string, opt compare.BlankOptions) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return false, err
 	}
-	return pdf.OutputFileAndClose(outPath)
+	return compare.IsBlank(img, opt), nil
 }
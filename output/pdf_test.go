@@ -0,0 +1,187 @@
+package output
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"AutoScreenShot/chapter"
+)
+
+func TestListImages(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+		want  []string
+	}{
+		{
+			"ページ順",
+			[]string{"screenshot_00012_10.jpg", "screenshot_00002.png", "screenshot_00012_2.jpg", "cover.jpg"},
+			[]string{"screenshot_00002.png", "screenshot_00012_2.jpg", "screenshot_00012_10.jpg", "cover.jpg"},
+		},
+		{
+			"画像以外と連結画像は拾わない",
+			[]string{"screenshot_00001.jpg", "run.log", "session.json", StitchedImageName, "a.pdf"},
+			[]string{"screenshot_00001.jpg"},
+		},
+		{
+			".tiff のページは拾い、フォルダの中は見ない",
+			[]string{"screenshot_00001.tiff", "screenshot_00002.tif", filepath.Join(TIFFExportDirName, "book.tiff")},
+			[]string{"screenshot_00001.tiff", "screenshot_00002.tif"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, f := range tt.files {
+				if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, f)), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join(dir, f), nil, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			paths, err := ListImages(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, p := range paths {
+				got = append(got, filepath.Base(p))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ListImages = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePageFileName(t *testing.T) {
+	tests := []struct {
+		name       string
+		index, sub int
+		ok         bool
+	}{
+		{"screenshot_00012.jpg", 12, 0, true},
+		{"screenshot_00012_2.png", 12, 2, true},
+		{"screenshot_x.jpg", 0, 0, false},
+		{"page_00001.jpg", 0, 0, false},
+	}
+	for _, tt := range tests {
+		index, sub, ok := ParsePageFileName(tt.name)
+		if index != tt.index || sub != tt.sub || ok != tt.ok {
+			t.Errorf("ParsePageFileName(%q) = %d, %d, %v", tt.name, index, sub, ok)
+		}
+	}
+}
+
+// outlines は data のしおりを順に、階層と移動先のページ（1 から）にして返します。
+func outlines(t *testing.T, data []byte) (levels, pages []int) {
+	t.Helper()
+	dict := func(num int) string { return objectText(t, data, num) }
+	ref := func(d, key string) int {
+		m := regexp.MustCompile(`/` + key + `\s*(\d+) 0 R`).FindStringSubmatch(d)
+		if m == nil {
+			return -1
+		}
+		n, _ := strconv.Atoi(m[1])
+		return n
+	}
+	root := ref(rootDict(t, data), "Outlines")
+	if root < 0 {
+		return nil, nil
+	}
+	// gofpdf はしおりを番号の続くオブジェクトに書き、その後に /Outlines を書く
+	level := map[int]int{root: -1}
+	for num := ref(dict(root), "First"); num < root; num++ {
+		d := dict(num)
+		level[num] = level[ref(d, "Parent")] + 1
+		levels = append(levels, level[num])
+		pages = append(pages, (ref(d, "Dest \\[")-1)/2) // ページ n のオブジェクトは 1+2n
+	}
+	return levels, pages
+}
+
+func TestBuildPDFChapters(t *testing.T) {
+	chapters := chapter.List{
+		Marks: []chapter.Mark{
+			{Page: 1, Title: "表紙"},
+			{Page: 2, Title: "第1章", Level: 2}, // 1つ前より2階層以上深くはできない
+			{Page: 2, Title: "1.1", Level: 1},
+			{Page: 4, Title: "第2章"},
+			{Page: 9, Title: "無いページ"},
+		},
+		Labels: []chapter.Label{
+			{Page: 2, Style: chapter.StyleRomanLower, Start: 1},
+			{Page: 3, Style: chapter.StyleDecimal, Start: 1},
+			{Page: 3, Style: chapter.StyleDecimal, Start: 5, Prefix: "付録-"}, // 同じページは後のものを使う
+			{Page: 4, Style: chapter.StyleNone, Prefix: "裏表紙"},
+		},
+	}
+	tests := []struct {
+		name   string
+		opt    PDFOptions
+		levels []int
+		pages  []int
+		labels string
+	}{
+		{
+			"1ページずつ",
+			PDFOptions{Chapters: chapters},
+			[]int{0, 1, 1, 0}, []int{1, 2, 2, 4},
+			fmt.Sprintf("/PageLabels << /Nums [ 0 << /S /D >> 1 << /S /r >> 2 << /S /D /P %s /St 5 >> 3 << /P %s >> ] >>",
+				pdfString("付録-"), pdfString("裏表紙")),
+		},
+		{
+			"表示が1ページ目から",
+			PDFOptions{Chapters: chapter.List{Labels: []chapter.Label{{Page: 1, Style: chapter.StyleRomanUpper, Start: 3}}}},
+			nil, nil,
+			"/PageLabels << /Nums [ 0 << /S /R /St 3 >> ] >>",
+		},
+		{
+			"用紙に2ページずつ並べるとページ番号の表示は付けない",
+			PDFOptions{Chapters: chapters, PageSize: PDFSizeNUp, Paper: PaperA4, NUp: 2},
+			[]int{0, 1, 1, 0}, []int{1, 1, 1, 2},
+			"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, data := buildPDF(t, 4, tt.opt)
+			levels, pages := outlines(t, data)
+			if !reflect.DeepEqual(levels, tt.levels) || !reflect.DeepEqual(pages, tt.pages) {
+				t.Errorf("bookmarks levels=%v pages=%v, want %v %v", levels, pages, tt.levels, tt.pages)
+			}
+			root := rootDict(t, data)
+			if tt.labels == "" {
+				if strings.Contains(root, "/PageLabels") {
+					t.Errorf("catalog %q contains /PageLabels", root)
+				}
+			} else if !strings.Contains(root, tt.labels) {
+				t.Errorf("catalog %q does not contain %q", root, tt.labels)
+			}
+		})
+	}
+}
+
+func TestJPGsToPDF(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "本.pdf")
+	if err := JPGsToPDF(pdfDir(t, 3), out, "本", 100, 50); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(data), "/Type /Page\n"); n != 3 {
+		t.Errorf("%d pages, want 3", n)
+	}
+	if !strings.Contains(string(data), "/Title "+pdfString("本")) {
+		t.Error("the PDF has no title")
+	}
+}
@@ -0,0 +1,112 @@
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// gofpdf にはカタログに項目を足す手段が無いため、読む向き（/ViewerPreferences）、ページ番号の表示（/PageLabels）と
+// XMP メタデータの参照（/Metadata。ストリームは gofpdf が書き出すが、カタログから参照しない）は、gofpdf が書き出した
+// PDF のカタログに書き足します。gofpdf はカタログを最後のオブジェクトとして相互参照表の直前に書くので、
+// カタログを伸ばしても他のオブジェクトの位置は変わらず、startxref を直すだけで済みます。
+// gofpdf の書き出す形（相互参照表が1つだけ）でない PDF は書き換えずにエラーにします。
+
+// pdfXref は gofpdf が書き出した PDF の相互参照表とトレーラーの内容です。
+type pdfXref struct {
+	offset     int // 相互参照表の位置（startxref）
+	size       int // /Size
+	root, info int // /Root と /Info のオブジェクト番号
+	startxref  int // startxref の値を書いた位置
+}
+
+var (
+	startxrefRe = regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n?$`)
+	// xrefRe は gofpdf の相互参照表（0 番から始まる小区分1つ）とトレーラーです。
+	xrefRe = regexp.MustCompile(`^xref\n0 (\d+)\n(?:\d{10} \d{5} [fn] \n)+trailer\n<<\n/Size (\d+)\n/Root (\d+) 0 R\n/Info (\d+) 0 R\n>>\nstartxref\n`)
+)
+
+// readXref は data の相互参照表とトレーラーを読みます。
+func readXref(data []byte) (pdfXref, error) {
+	var x pdfXref
+	m := startxrefRe.FindSubmatch(data[max(0, len(data)-64):]) // startxref は末尾にある
+	if m == nil {
+		return x, fmt.Errorf("PDF の startxref が見つかりません")
+	}
+	x.offset, _ = strconv.Atoi(string(m[1]))
+	if x.offset >= len(data) {
+		return x, fmt.Errorf("PDF の startxref が範囲外です")
+	}
+	t := xrefRe.FindSubmatch(data[x.offset:])
+	if t == nil {
+		// 相互参照ストリームやオブジェクトストリームを使う PDF、増分更新した PDF など
+		return x, fmt.Errorf("gofpdf の形の相互参照表ではないため PDF を書き換えられません")
+	}
+	count, _ := strconv.Atoi(string(t[1]))
+	x.size, _ = strconv.Atoi(string(t[2]))
+	x.root, _ = strconv.Atoi(string(t[3]))
+	x.info, _ = strconv.Atoi(string(t[4]))
+	table := bytes.Index(t[0], []byte("trailer\n")) - len(fmt.Sprintf("xref\n0 %d\n", count))
+	if count != x.size || table != 20*count {
+		return x, fmt.Errorf("PDF の相互参照表の項目の数が /Size と合いません")
+	}
+	x.startxref = x.offset + len(t[0])
+	return x, nil
+}
+
+// object は相互参照表からオブジェクト num の位置を返します。その位置にオブジェクトが無ければエラーです。
+func (x pdfXref) object(data []byte, num int) (int, error) {
+	if num <= 0 || num >= x.size {
+		return 0, fmt.Errorf("PDF のオブジェクト %d がありません", num)
+	}
+	entry := x.offset + len(fmt.Sprintf("xref\n0 %d\n", x.size)) + 20*num
+	off, err := strconv.Atoi(string(data[entry : entry+10]))
+	if err != nil || off >= x.offset || !bytes.HasPrefix(data[off:], []byte(fmt.Sprintf("%d 0 obj\n", num))) {
+		return 0, fmt.Errorf("PDF のオブジェクト %d を読めません", num)
+	}
+	return off, nil
+}
+
+// metadataObject は gofpdf が書き出した XMP メタデータのストリーム（文書情報辞書の直前のオブジェクト）の番号を返します。
+func metadataObject(data []byte) (int, error) {
+	x, err := readXref(data)
+	if err != nil {
+		return 0, err
+	}
+	num := x.info - 1
+	off, err := x.object(data, num)
+	if err != nil {
+		return 0, err
+	}
+	if !bytes.HasPrefix(data[off:], []byte(fmt.Sprintf("%d 0 obj\n<< /Type /Metadata ", num))) {
+		return 0, fmt.Errorf("PDF に XMP メタデータのストリームがありません")
+	}
+	return num, nil
+}
+
+// patchCatalog は data のカタログの辞書に entries（"/Key value" の並び）を加えた PDF を返します。
+func patchCatalog(data []byte, entries []string) ([]byte, error) {
+	x, err := readXref(data)
+	if err != nil {
+		return nil, err
+	}
+	start, err := x.object(data, x.root)
+	if err != nil {
+		return nil, err
+	}
+	// カタログは相互参照表の直前の最後のオブジェクトで、辞書の終わりの >> の前に書き足す
+	const tail = "\n>>\nendobj\n"
+	end := x.offset - len(tail)
+	if x.root != x.size-1 || end < start || string(data[end:x.offset]) != tail {
+		return nil, fmt.Errorf("PDF のカタログが相互参照表の直前にありません")
+	}
+	add := "\n" + strings.Join(entries, "\n")
+	out := make([]byte, 0, len(data)+len(add)+8)
+	out = append(out, data[:end]...)
+	out = append(out, add...)
+	out = append(out, data[end:x.startxref]...)
+	out = append(out, fmt.Sprintf("%d\n%%%%EOF\n", x.offset+len(add))...)
+	return out, nil
+}
@@ -0,0 +1,245 @@
+package output
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// pdfDir は PNG のページを n 枚置いたフォルダを返します。
+func pdfDir(t *testing.T, n int) string {
+	t.Helper()
+	dir := t.TempDir()
+	for i := 1; i <= n; i++ {
+		writePNG(t, filepath.Join(dir, fmt.Sprintf("screenshot_%05d.png", i)), colors(i+1))
+	}
+	return dir
+}
+
+// buildPDF は pdfDir のページから opt で PDF を作り、そのパスと内容を返します。
+func buildPDF(t *testing.T, n int, opt PDFOptions) (string, []byte) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "book.pdf")
+	if err := BuildPDF(pdfDir(t, n), path, opt); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path, data
+}
+
+// objectText は data（gofpdf の形の PDF）のオブジェクト num を endobj の前まで返します。
+func objectText(t *testing.T, data []byte, num int) string {
+	t.Helper()
+	x, err := readXref(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	off, err := x.object(data, num)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := data[off:]
+	if end := bytes.Index(obj, []byte("endobj")); end >= 0 {
+		obj = obj[:end]
+	}
+	return string(obj)
+}
+
+// rootDict は data のカタログを返します。
+func rootDict(t *testing.T, data []byte) string {
+	t.Helper()
+	x, err := readXref(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return objectText(t, data, x.root)
+}
+
+// streamPDF はオブジェクトストリームにカタログとページを入れ、相互参照ストリームで参照する PDF 1.5 を返します。
+// compress なら相互参照ストリームを Flate で圧縮します。
+func streamPDF(t *testing.T, compress bool) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.5\n")
+	objStm := "2 0 3 40 << /Type /Catalog /Pages 3 0 R >>          << /Type /Pages /Kids [] /Count 0 >>"
+	off1 := b.Len()
+	fmt.Fprintf(&b, "1 0 obj\n<< /Type /ObjStm /N 2 /First 9 /Length %d >>\nstream\n%s\nendstream\nendobj\n", len(objStm), objStm)
+	off4 := b.Len()
+	// 種類（1 バイト）・位置またはストリームの番号（2 バイト）・世代またはストリーム内の番号（1 バイト）
+	rows := []byte{
+		0, 0, 0, 0xff,
+		1, byte(off1 >> 8), byte(off1), 0,
+		2, 0, 1, 0,
+		2, 0, 1, 1,
+		1, byte(off4 >> 8), byte(off4), 0,
+	}
+	filter := ""
+	if compress {
+		var z bytes.Buffer
+		zw := zlib.NewWriter(&z)
+		zw.Write(rows)
+		zw.Close()
+		rows, filter = z.Bytes(), " /Filter /FlateDecode"
+	}
+	fmt.Fprintf(&b, "4 0 obj\n<< /Type /XRef /Size 5 /W [1 2 1] /Root 2 0 R%s /Length %d >>\nstream\n", filter, len(rows))
+	b.Write(rows)
+	fmt.Fprintf(&b, "\nendstream\nendobj\nstartxref\n%d\n%%%%EOF\n", off4)
+	return b.Bytes()
+}
+
+func TestReadXref(t *testing.T) {
+	_, data := buildPDF(t, 2, PDFOptions{})
+	x, err := readXref(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// gofpdf は文書情報辞書、カタログの順に最後に書く
+	if x.root != x.size-1 || x.info != x.root-1 {
+		t.Errorf("readXref = %+v", x)
+	}
+	if !strings.HasPrefix(objectText(t, data, x.root), fmt.Sprintf("%d 0 obj\n<<\n/Type /Catalog", x.root)) {
+		t.Errorf("catalog = %q", objectText(t, data, x.root))
+	}
+	for num := 1; num < x.size; num++ {
+		if _, err := x.object(data, num); err != nil {
+			t.Errorf("object %d: %v", num, err)
+		}
+	}
+	if _, err := x.object(data, x.size); err == nil {
+		t.Error("object of a missing number should fail")
+	}
+}
+
+func TestPatchCatalog(t *testing.T) {
+	_, orig := buildPDF(t, 2, PDFOptions{})
+	x, err := readXref(orig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := patchCatalog(orig, []string{"/Test1 true"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 2回目も gofpdf の形のままなので書き足せる
+	if data, err = patchCatalog(data, []string{"/Test2 true", "/Test3 true"}); err != nil {
+		t.Fatal(err)
+	}
+	catalog, err := x.object(orig, x.root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data[:catalog], orig[:catalog]) {
+		t.Error("patchCatalog changed objects before the catalog")
+	}
+	y, err := readXref(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if y.size != x.size || y.root != x.root || y.info != x.info || y.offset <= x.offset {
+		t.Errorf("after patch %+v, before %+v", y, x)
+	}
+	for num := 1; num < y.size; num++ {
+		if _, err := y.object(data, num); err != nil {
+			t.Errorf("object %d after patch: %v", num, err)
+		}
+	}
+	if root := rootDict(t, data); !strings.Contains(root, "/Test1 true\n/Test2 true\n/Test3 true\n>>\n") {
+		t.Errorf("catalog = %q", root)
+	}
+
+	// gofpdf の形でない PDF は書き換えない
+	update := bytes.NewBuffer(append([]byte(nil), orig...))
+	fmt.Fprintf(update, "%d 0 obj\n<< /Type /Catalog /Pages 1 0 R >>\nendobj\n", x.root)
+	updateXref := update.Len()
+	fmt.Fprintf(update, "xref\n%d 1\n%010d 00000 n \ntrailer\n<<\n/Size %d\n/Root %d 0 R\n/Info %d 0 R\n/Prev %d\n>>\nstartxref\n%d\n%%%%EOF\n",
+		x.root, len(orig), x.size, x.root, x.info, x.offset, updateXref)
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"オブジェクトストリームと相互参照ストリーム", streamPDF(t, false)},
+		{"圧縮した相互参照ストリーム", streamPDF(t, true)},
+		{"増分更新した PDF", update.Bytes()},
+		{"startxref が無い", orig[:x.offset]},
+		{"startxref が範囲外", append(orig[:x.startxref:x.startxref], "99999999\n%%EOF\n"...)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := append([]byte(nil), tt.data...)
+			if got, err := patchCatalog(tt.data, []string{"/Test true"}); err == nil || got != nil {
+				t.Errorf("patchCatalog = %d bytes, %v, want an error", len(got), err)
+			}
+			if _, err := metadataObject(tt.data); err == nil {
+				t.Error("metadataObject should fail")
+			}
+			if !bytes.Equal(tt.data, before) {
+				t.Error("the input was modified")
+			}
+		})
+	}
+}
+
+func TestDisplayLayout(t *testing.T) {
+	tests := []struct {
+		layout     string
+		rtl, cover bool
+		want       string
+	}{
+		{PDFLayoutDefault, true, true, "default"},
+		{PDFLayoutSingle, true, false, "SinglePage"},
+		{PDFLayoutSpread, false, false, "TwoPageLeft"},
+		{PDFLayoutSpread, false, true, "TwoPageRight"},
+		{PDFLayoutSpread, true, false, "TwoPageRight"},
+		{PDFLayoutSpread, true, true, "TwoPageLeft"},
+	}
+	for _, tt := range tests {
+		opt := PDFOptions{PageLayout: tt.layout, RightToLeft: tt.rtl, CoverAlone: tt.cover}
+		if got := displayLayout(opt); got != tt.want {
+			t.Errorf("displayLayout(%q, rtl=%v, cover=%v) = %q, want %q", tt.layout, tt.rtl, tt.cover, got, tt.want)
+		}
+	}
+	if err := CheckPDFLayout("double"); err == nil {
+		t.Error(`CheckPDFLayout("double") should fail`)
+	}
+}
+
+func TestBuildPDFDirection(t *testing.T) {
+	tests := []struct {
+		name string
+		opt  PDFOptions
+		want []string
+		not  []string
+	}{
+		{"既定", PDFOptions{}, nil, []string{"/ViewerPreferences", "/PageLayout"}},
+		{"右から左の見開き", PDFOptions{RightToLeft: true, PageLayout: PDFLayoutSpread},
+			[]string{"/ViewerPreferences << /Direction /R2L >>", "/PageLayout /TwoPageRight"}, nil},
+		{"左から右の見開き", PDFOptions{PageLayout: PDFLayoutSpread},
+			[]string{"/PageLayout /TwoPageLeft"}, []string{"/ViewerPreferences"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, data := buildPDF(t, 3, tt.opt)
+			root := rootDict(t, data)
+			for _, s := range tt.want {
+				if !strings.Contains(root, s) {
+					t.Errorf("catalog %q does not contain %q", root, s)
+				}
+			}
+			for _, s := range tt.not {
+				if strings.Contains(root, s) {
+					t.Errorf("catalog %q contains %q", root, s)
+				}
+			}
+		})
+	}
+	if err := BuildPDF(pdfDir(t, 1), filepath.Join(t.TempDir(), "book.pdf"), PDFOptions{PageLayout: "double"}); err == nil {
+		t.Error("BuildPDF with an unknown layout should fail")
+	}
+}
@@ -0,0 +1,170 @@
+package output
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// PDFProducer は PDF の /Producer（PDF に変換したアプリケーション）です。
+const PDFProducer = "AutoScreenShot"
+
+// PDFMetadata は PDF の文書のプロパティ（文書情報辞書と XMP メタデータ）です。
+type PDFMetadata struct {
+	Title    string
+	Author   string
+	Subject  string
+	Keywords string    // キーワード（カンマ区切り）
+	Creator  string    // 元の内容を作ったアプリケーション
+	Created  time.Time // 作成日時（ゼロなら PDF を書き出した時刻）
+	// Custom は独自の項目（名前 → 値）です。gofpdf は文書情報辞書に独自の項目を書き出せないため、
+	// XMP メタデータの pdfx: の項目にします。Custom があれば XMP が false でも XMP メタデータを埋め込みます。
+	Custom map[string]string
+	// XMP が true なら、同じ内容を XMP メタデータとしても埋め込みます（カタログの /Metadata）。
+	XMP bool
+}
+
+// embedXMP は XMP メタデータを埋め込むか返します。
+func (m PDFMetadata) embedXMP() bool {
+	return m.XMP || len(m.Custom) > 0
+}
+
+// pdfInfoKeys は文書情報辞書の標準の項目の名前です。独自の項目に同じ名前は使えません。
+var pdfInfoKeys = []string{"Title", "Author", "Subject", "Keywords", "Creator", "Producer", "CreationDate", "ModDate", "Trapped"}
+
+// CheckPDFInfo は独自の項目の名前が XMP メタデータの項目名に使えるか確かめます。
+func CheckPDFInfo(custom map[string]string) error {
+	for key := range custom {
+		if strings.TrimSpace(key) == "" {
+			return fmt.Errorf("PDF の独自の項目の名前が空です")
+		}
+		if !isXMLName(key) {
+			return fmt.Errorf("PDF の独自の項目の名前 %q に空白や記号は使えません（文字・数字・_ - . のみ、先頭は文字か _）", key)
+		}
+		for _, std := range pdfInfoKeys {
+			if key == std {
+				return fmt.Errorf("PDF の独自の項目に標準の項目の名前 %q は使えません", key)
+			}
+		}
+	}
+	return nil
+}
+
+// apply は gofpdf にプロパティを設定します。独自の項目は XMP メタデータに入れます。
+// gofpdf の文書情報辞書の日付には時差が付かないため、時差は XMP メタデータの日付にだけ付きます。
+func (m PDFMetadata) apply(pdf *gofpdf.Fpdf, created, modified time.Time) {
+	pdf.SetProducer(PDFProducer, true)
+	for _, f := range []struct {
+		set   func(string, bool)
+		value string
+	}{
+		{pdf.SetTitle, m.Title},
+		{pdf.SetAuthor, m.Author},
+		{pdf.SetSubject, m.Subject},
+		{pdf.SetKeywords, m.Keywords},
+		{pdf.SetCreator, m.Creator},
+	} {
+		if f.value != "" {
+			f.set(f.value, true) // true = UTF-8（日本語対応）
+		}
+	}
+	pdf.SetCreationDate(created)
+	pdf.SetModificationDate(modified)
+	if m.embedXMP() {
+		pdf.SetXmpMetadata(m.xmpPacket(created, modified))
+	}
+}
+
+// xmpPacket は XMP メタデータのパケットを返します。独自の項目は値のあるものを pdfx: の項目にします。
+func (m PDFMetadata) xmpPacket(created, modified time.Time) []byte {
+	var b bytes.Buffer
+	text := func(s string) string {
+		var e bytes.Buffer
+		xml.EscapeText(&e, []byte(s))
+		return e.String()
+	}
+	b.WriteString("<?xpacket begin=\"\ufeff\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n")
+	b.WriteString(`<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about=""
+    xmlns:dc="http://purl.org/dc/elements/1.1/"
+    xmlns:xmp="http://ns.adobe.com/xap/1.0/"
+    xmlns:pdf="http://ns.adobe.com/pdf/1.3/"
+    xmlns:xmpMM="http://ns.adobe.com/xap/1.0/mm/"
+    xmlns:pdfx="http://ns.adobe.com/pdfx/1.3/">
+   <dc:format>application/pdf</dc:format>
+`)
+	if m.Title != "" {
+		fmt.Fprintf(&b, "   <dc:title><rdf:Alt><rdf:li xml:lang=\"x-default\">%s</rdf:li></rdf:Alt></dc:title>\n", text(m.Title))
+	}
+	if m.Author != "" {
+		fmt.Fprintf(&b, "   <dc:creator><rdf:Seq><rdf:li>%s</rdf:li></rdf:Seq></dc:creator>\n", text(m.Author))
+	}
+	if m.Subject != "" {
+		fmt.Fprintf(&b, "   <dc:description><rdf:Alt><rdf:li xml:lang=\"x-default\">%s</rdf:li></rdf:Alt></dc:description>\n", text(m.Subject))
+	}
+	if words := splitKeywords(m.Keywords); len(words) > 0 {
+		b.WriteString("   <dc:subject><rdf:Bag>")
+		for _, w := range words {
+			fmt.Fprintf(&b, "<rdf:li>%s</rdf:li>", text(w))
+		}
+		b.WriteString("</rdf:Bag></dc:subject>\n")
+		fmt.Fprintf(&b, "   <pdf:Keywords>%s</pdf:Keywords>\n", text(m.Keywords))
+	}
+	fmt.Fprintf(&b, "   <pdf:Producer>%s</pdf:Producer>\n", text(PDFProducer))
+	if m.Creator != "" {
+		fmt.Fprintf(&b, "   <xmp:CreatorTool>%s</xmp:CreatorTool>\n", text(m.Creator))
+	}
+	fmt.Fprintf(&b, "   <xmp:CreateDate>%s</xmp:CreateDate>\n", xmpDate(created))
+	fmt.Fprintf(&b, "   <xmp:ModifyDate>%s</xmp:ModifyDate>\n", xmpDate(modified))
+	fmt.Fprintf(&b, "   <xmp:MetadataDate>%s</xmp:MetadataDate>\n", xmpDate(modified))
+	fmt.Fprintf(&b, "   <xmpMM:DocumentID>%s</xmpMM:DocumentID>\n", strings.TrimPrefix(newBookID(), "urn:"))
+	for _, key := range sortedKeys(m.Custom) {
+		if isXMLName(key) && m.Custom[key] != "" {
+			fmt.Fprintf(&b, "   <pdfx:%s>%s</pdfx:%s>\n", key, text(m.Custom[key]), key)
+		}
+	}
+	b.WriteString("  </rdf:Description>\n </rdf:RDF>\n</x:xmpmeta>\n<?xpacket end=\"w\"?>")
+	return b.Bytes()
+}
+
+// xmpDate は日時を XMP の日付（ISO 8601）にします。
+func xmpDate(t time.Time) string {
+	return t.Format("2006-01-02T15:04:05Z07:00")
+}
+
+// isXMLName は s が名前空間の接頭辞を付けて XML の要素名にできるか返します。
+func isXMLName(s string) bool {
+	for i, r := range s {
+		if !(unicode.IsLetter(r) || r == '_' || i > 0 && (unicode.IsDigit(r) || r == '-' || r == '.')) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// splitKeywords はカンマ区切り（読点・セミコロンも可）のキーワードを分けます。
+func splitKeywords(s string) []string {
+	var words []string
+	for _, w := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == '、' || r == ';' }) {
+		if w = strings.TrimSpace(w); w != "" {
+			words = append(words, w)
+		}
+	}
+	return words
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,226 @@
+package output
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckPDFInfo(t *testing.T) {
+	tests := []struct {
+		custom map[string]string
+		ok     bool
+	}{
+		{nil, true},
+		{map[string]string{"Series": "x", "巻": "3"}, true},
+		{map[string]string{"Title": "x"}, false},
+		{map[string]string{"ModDate": "x"}, false},
+		{map[string]string{" ": "x"}, false},
+		{map[string]string{"Book Title": "x"}, false},
+		{map[string]string{"2nd": "x"}, false},
+	}
+	for _, tt := range tests {
+		if err := CheckPDFInfo(tt.custom); (err == nil) != tt.ok {
+			t.Errorf("CheckPDFInfo(%v) = %v, want ok=%v", tt.custom, err, tt.ok)
+		}
+	}
+}
+
+func TestXMPDate(t *testing.T) {
+	at := func(offset int) time.Time {
+		return time.Date(2024, 3, 9, 7, 5, 1, 0, time.FixedZone("", offset))
+	}
+	tests := []struct {
+		t    time.Time
+		want string
+	}{
+		{at(0), "2024-03-09T07:05:01Z"},
+		{at(9 * 3600), "2024-03-09T07:05:01+09:00"},
+		{at(-(3*3600 + 30*60)), "2024-03-09T07:05:01-03:30"},
+	}
+	for _, tt := range tests {
+		if got := xmpDate(tt.t); got != tt.want {
+			t.Errorf("xmpDate(%v) = %q, want %q", tt.t, got, tt.want)
+		}
+	}
+}
+
+func TestIsXMLName(t *testing.T) {
+	tests := []struct {
+		s    string
+		want bool
+	}{
+		{"Series", true},
+		{"_vol-2.1", true},
+		{"巻", true},
+		{"", false},
+		{"2nd", false},
+		{"Book Title", false},
+		{"a:b", false},
+	}
+	for _, tt := range tests {
+		if got := isXMLName(tt.s); got != tt.want {
+			t.Errorf("isXMLName(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestSplitKeywords(t *testing.T) {
+	tests := []struct {
+		s    string
+		want []string
+	}{
+		{"", nil},
+		{"漫画, 第1巻 ,,", []string{"漫画", "第1巻"}},
+		{"a、b;c", []string{"a", "b", "c"}},
+	}
+	for _, tt := range tests {
+		if got := splitKeywords(tt.s); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitKeywords(%q) = %q, want %q", tt.s, got, tt.want)
+		}
+	}
+}
+
+var testMetadata = PDFMetadata{
+	Title:    "本 <上>",
+	Author:   "著者 & 編者",
+	Keywords: "漫画, 第1巻",
+	Creator:  "Viewer",
+	Custom:   map[string]string{"Series": "(シリーズ)", "Book Title": "x", "Empty": ""},
+	XMP:      true,
+}
+
+// xmpElements は XMP のパケットを読み、要素の名前空間つきの名前ごとに文字列の中身を返します。
+func xmpElements(t *testing.T, packet []byte) map[string][]string {
+	t.Helper()
+	elems := map[string][]string{}
+	d := xml.NewDecoder(bytes.NewReader(packet))
+	var stack []string
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("XMP is not well-formed: %v", err)
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			stack = append(stack, tok.Name.Space+" "+tok.Name.Local)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if s := strings.TrimSpace(string(tok)); s != "" && len(stack) > 0 {
+				elems[stack[len(stack)-1]] = append(elems[stack[len(stack)-1]], s)
+			}
+		}
+	}
+	return elems
+}
+
+func TestXMPPacket(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.FixedZone("", 9*3600))
+	elems := xmpElements(t, testMetadata.xmpPacket(created, created))
+	const (
+		rdf  = "http://www.w3.org/1999/02/22-rdf-syntax-ns# "
+		xmp  = "http://ns.adobe.com/xap/1.0/ "
+		pdf  = "http://ns.adobe.com/pdf/1.3/ "
+		pdfx = "http://ns.adobe.com/pdfx/1.3/ "
+	)
+	want := map[string][]string{
+		rdf + "li":          {"本 <上>", "著者 & 編者", "漫画", "第1巻"},
+		pdf + "Keywords":    {"漫画, 第1巻"},
+		pdf + "Producer":    {PDFProducer},
+		xmp + "CreatorTool": {"Viewer"},
+		xmp + "CreateDate":  {"2024-01-02T03:04:05+09:00"},
+		pdfx + "Series":     {"(シリーズ)"},
+		"http://purl.org/dc/elements/1.1/ format": {"application/pdf"},
+	}
+	for key, w := range want {
+		if got := elems[key]; !reflect.DeepEqual(got, w) {
+			t.Errorf("%s = %q, want %q", key, got, w)
+		}
+	}
+	// XML の名前にできない項目と値の空の項目は XMP に入れない
+	for key := range elems {
+		if strings.HasPrefix(key, pdfx) && key != pdfx+"Series" {
+			t.Errorf("unexpected custom entry %s", key)
+		}
+	}
+	if ids := elems["http://ns.adobe.com/xap/1.0/mm/ DocumentID"]; len(ids) != 1 || !strings.HasPrefix(ids[0], "uuid:") {
+		t.Errorf("DocumentID = %q", ids)
+	}
+}
+
+func TestBuildPDFMetadata(t *testing.T) {
+	series := PDFMetadata{Custom: map[string]string{"Series": "(シリーズ)"}}
+	tests := []struct {
+		name    string
+		meta    PDFMetadata
+		info    []string // 文書情報辞書に含まれるもの
+		notInfo []string
+		xmp     bool
+	}{
+		{"既定", PDFMetadata{}, []string{"/Producer ", "/CreationDate (D:2"}, []string{"/Title", "/Series"}, false},
+		{"標準の項目", PDFMetadata{Title: "本", Subject: "説明", Keywords: "漫画"}, []string{"/Title ", "/Subject ", "/Keywords "}, nil, false},
+		{"独自の項目だけ", series, []string{"/Producer "}, []string{"/Series"}, true},
+		{"XMP", PDFMetadata{Title: "本", XMP: true}, []string{"/Title "}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, data := buildPDF(t, 1, PDFOptions{Metadata: tt.meta})
+			x, err := readXref(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			info := objectText(t, data, x.info)
+			for _, s := range tt.info {
+				if !strings.Contains(info, s) {
+					t.Errorf("info %q does not contain %q", info, s)
+				}
+			}
+			for _, s := range tt.notInfo {
+				if strings.Contains(info, s) {
+					t.Errorf("info %q contains %q", info, s)
+				}
+			}
+			m := regexp.MustCompile(`/Metadata (\d+) 0 R`).FindStringSubmatch(rootDict(t, data))
+			if (m != nil) != tt.xmp {
+				t.Fatalf("catalog /Metadata = %v, want %v", m, tt.xmp)
+			}
+			if m == nil {
+				return
+			}
+			num, _ := strconv.Atoi(m[1])
+			obj := []byte(objectText(t, data, num))
+			start := bytes.Index(obj, []byte("stream\n")) + len("stream\n")
+			end := bytes.Index(obj, []byte("\nendstream"))
+			length := regexp.MustCompile(`/Length (\d+)`).FindSubmatch(obj)
+			if start < len("stream\n") || end < start || length == nil || string(length[1]) != strconv.Itoa(end-start) {
+				t.Fatalf("XMP stream is malformed: %q", obj[:min(len(obj), 120)])
+			}
+			elems := xmpElements(t, obj[start:end])
+			if got, want := elems["http://ns.adobe.com/pdfx/1.3/ Series"], tt.meta.Custom["Series"]; strings.Join(got, "") != want {
+				t.Errorf("XMP Series = %q, want %q", got, want)
+			}
+		})
+	}
+
+	dir := pdfDir(t, 1)
+	meta := PDFMetadata{Custom: map[string]string{"Book Title": "x"}}
+	out := filepath.Join(t.TempDir(), "book.pdf")
+	if err := BuildPDF(dir, out, PDFOptions{Metadata: meta}); err == nil {
+		t.Error("BuildPDF accepted a custom entry name with a space")
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Errorf("BuildPDF wrote %s despite the error", out)
+	}
+}
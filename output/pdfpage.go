@@ -0,0 +1,152 @@
+package output
+
+import (
+	"fmt"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// PDF のページの大きさの決め方
+const (
+	PDFSizeImage = ""      // 画像の大きさ（DPI から求める）のページ
+	PDFSizePaper = "paper" // 用紙の大きさのページに余白を取って収める（向きはページごとに画像に合わせる）
+	PDFSizeNUp   = "nup"   // 用紙1枚に複数のページを並べる（配布資料の印刷用）
+)
+
+// 用紙の大きさ
+const (
+	PaperA4     = "A4"
+	PaperLetter = "Letter"
+	PaperB5     = "B5" // JIS B5
+)
+
+// paperSizes は用紙の縦向きの大きさ（mm）です。
+var paperSizes = map[string]gofpdf.SizeType{
+	PaperA4:     {Wd: 210, Ht: 297},
+	PaperLetter: {Wd: 215.9, Ht: 279.4},
+	PaperB5:     {Wd: 182, Ht: 257},
+}
+
+// DefaultPDFMarginMm は用紙に収めるときの既定の余白（mm）です。
+const DefaultPDFMarginMm = 10
+
+// CheckPDFPageSize はページの大きさの決め方、用紙、1枚に並べるページ数が正しいか確かめます。
+func CheckPDFPageSize(mode, paper string, nUp int) error {
+	switch mode {
+	case PDFSizeImage:
+		return nil
+	case PDFSizePaper, PDFSizeNUp:
+	default:
+		return fmt.Errorf("不明な PDF のページの大きさの決め方です: %q", mode)
+	}
+	if _, ok := paperSizes[paper]; !ok && paper != "" {
+		return fmt.Errorf("不明な用紙の大きさです: %q", paper)
+	}
+	if mode == PDFSizeNUp && nUp != 2 && nUp != 4 {
+		return fmt.Errorf("用紙1枚に並べるページ数は 2 か 4 です: %d", nUp)
+	}
+	return nil
+}
+
+// pixelsToMm は dpi でピクセルを mm に変換します。
+func pixelsToMm(pixels int, dpi float64) float64 {
+	return float64(pixels) * mmPerInch / dpi
+}
+
+// pagePlace は画像を置く PDF のページと、ページの中の位置（mm）です。
+type pagePlace struct {
+	newPage    bool            // 新しいページ（用紙）を始める
+	size       gofpdf.SizeType // newPage のときのページの大きさ
+	x, y, w, h float64
+}
+
+// pageLayout は PDFOptions のページの大きさの決め方で、画像を順にページに割り付けます。
+type pageLayout struct {
+	opt   PDFOptions
+	dpi   float64
+	paper gofpdf.SizeType
+	// n-up の用紙の状態
+	slot       int // 次に使う区画（0 から）
+	cols, rows int
+	sheet      gofpdf.SizeType
+}
+
+func newPageLayout(opt PDFOptions) *pageLayout {
+	l := &pageLayout{opt: opt, dpi: opt.DPI, paper: paperSizes[opt.Paper]}
+	if l.dpi <= 0 {
+		l.dpi = pixelsPerInch
+	}
+	if l.paper.Wd == 0 {
+		l.paper = paperSizes[PaperA4]
+	}
+	return l
+}
+
+// place は幅 pw・高さ ph ピクセルの画像の置き場所を返します。
+func (l *pageLayout) place(pw, ph int) pagePlace {
+	switch l.opt.PageSize {
+	case PDFSizePaper:
+		size := orient(l.paper, pw > ph)
+		m := l.margin(size)
+		p := pagePlace{newPage: true, size: size}
+		p.x, p.y, p.w, p.h = fit(pw, ph, m, m, size.Wd-2*m, size.Ht-2*m)
+		return p
+	case PDFSizeNUp:
+		return l.placeNUp(pw, ph)
+	}
+	size := gofpdf.SizeType{Wd: pixelsToMm(pw, l.dpi), Ht: pixelsToMm(ph, l.dpi)}
+	return pagePlace{newPage: true, size: size, w: size.Wd, h: size.Ht}
+}
+
+// placeNUp は用紙を cols×rows の区画に分け、画像を区画に順に収めます。用紙の向きは用紙の最初の画像に合わせ、
+// 2-up は縦長の画像なら横向きの用紙に左右、横長なら縦向きの用紙に上下に、4-up は画像と同じ向きの用紙に 2×2 で並べます。
+// 右から左に読む文書では、各行を右の区画から埋めます。
+func (l *pageLayout) placeNUp(pw, ph int) pagePlace {
+	var p pagePlace
+	if l.slot == 0 {
+		landscape := pw > ph
+		switch l.opt.NUp {
+		case 2:
+			l.sheet = orient(l.paper, !landscape)
+			l.cols, l.rows = 2, 1
+			if landscape {
+				l.cols, l.rows = 1, 2
+			}
+		default:
+			l.sheet = orient(l.paper, landscape)
+			l.cols, l.rows = 2, 2
+		}
+		p.newPage, p.size = true, l.sheet
+	}
+	m := l.margin(l.sheet)
+	gap := m / 2 // 区画の間隔
+	cw := (l.sheet.Wd - 2*m - gap*float64(l.cols-1)) / float64(l.cols)
+	ch := (l.sheet.Ht - 2*m - gap*float64(l.rows-1)) / float64(l.rows)
+	col, row := l.slot%l.cols, l.slot/l.cols
+	if l.opt.RightToLeft {
+		col = l.cols - 1 - col
+	}
+	p.x, p.y, p.w, p.h = fit(pw, ph, m+float64(col)*(cw+gap), m+float64(row)*(ch+gap), cw, ch)
+	l.slot = (l.slot + 1) % (l.cols * l.rows)
+	return p
+}
+
+// margin は用紙の余白を返します。用紙の短い辺の 1/4 を超える余白は縮めます。
+func (l *pageLayout) margin(size gofpdf.SizeType) float64 {
+	return min(max(l.opt.MarginMm, 0), min(size.Wd, size.Ht)/4)
+}
+
+// orient は縦向きの用紙の大きさ size を、landscape なら横向きにします。
+func orient(size gofpdf.SizeType, landscape bool) gofpdf.SizeType {
+	if landscape {
+		return gofpdf.SizeType{Wd: size.Ht, Ht: size.Wd}
+	}
+	return size
+}
+
+// fit は幅 pw・高さ ph の画像を縦横比を保って (x, y, w, h) の枠に収め、枠の中央に置いた位置と大きさを返します。
+func fit(pw, ph int, x, y, w, h float64) (float64, float64, float64, float64) {
+	s := min(w/float64(pw), h/float64(ph))
+	iw, ih := float64(pw)*s, float64(ph)*s
+	return x + (w-iw)/2, y + (h-ih)/2, iw, ih
+}
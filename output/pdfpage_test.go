@@ -0,0 +1,163 @@
+package output
+
+import (
+	"math"
+	"testing"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+func TestCheckPDFPageSize(t *testing.T) {
+	tests := []struct {
+		mode, paper string
+		nUp         int
+		ok          bool
+	}{
+		{PDFSizeImage, "", 0, true},
+		{PDFSizeImage, "A0", 3, true}, // 画像の大きさでは用紙と並べる数を使わない
+		{PDFSizePaper, "", 0, true},
+		{PDFSizePaper, PaperB5, 0, true},
+		{PDFSizePaper, "A0", 0, false},
+		{PDFSizeNUp, PaperLetter, 2, true},
+		{PDFSizeNUp, PaperA4, 4, true},
+		{PDFSizeNUp, PaperA4, 3, false},
+		{"fit", "", 0, false},
+	}
+	for _, tt := range tests {
+		if err := CheckPDFPageSize(tt.mode, tt.paper, tt.nUp); (err == nil) != tt.ok {
+			t.Errorf("CheckPDFPageSize(%q, %q, %d) = %v, want ok=%v", tt.mode, tt.paper, tt.nUp, err, tt.ok)
+		}
+	}
+}
+
+// testImage は place に渡す画像の大きさ（ピクセル）です。
+type testImage struct{ w, h int }
+
+var (
+	portrait  = testImage{100, 200}
+	landscape = testImage{200, 100}
+)
+
+// samePlace は a と b が（mm の誤差を除いて）同じ置き場所か返します。
+func samePlace(a, b pagePlace) bool {
+	near := func(x, y float64) bool { return math.Abs(x-y) < 1e-9 }
+	return a.newPage == b.newPage && near(a.size.Wd, b.size.Wd) && near(a.size.Ht, b.size.Ht) &&
+		near(a.x, b.x) && near(a.y, b.y) && near(a.w, b.w) && near(a.h, b.h)
+}
+
+func TestPageLayoutPlace(t *testing.T) {
+	a4 := gofpdf.SizeType{Wd: 210, Ht: 297}
+	a4Land := gofpdf.SizeType{Wd: 297, Ht: 210}
+	tests := []struct {
+		name   string
+		opt    PDFOptions
+		images []testImage
+		want   []pagePlace
+	}{
+		{
+			"画像の大きさ（既定の 96 DPI）",
+			PDFOptions{},
+			[]testImage{{960, 480}, {96, 192}},
+			[]pagePlace{
+				{newPage: true, size: gofpdf.SizeType{Wd: 254, Ht: 127}, w: 254, h: 127},
+				{newPage: true, size: gofpdf.SizeType{Wd: 25.4, Ht: 50.8}, w: 25.4, h: 50.8},
+			},
+		},
+		{
+			"画像の大きさ（300 DPI）",
+			PDFOptions{DPI: 300},
+			[]testImage{{300, 600}},
+			[]pagePlace{{newPage: true, size: gofpdf.SizeType{Wd: 25.4, Ht: 50.8}, w: 25.4, h: 50.8}},
+		},
+		{
+			"用紙（向きは画像ごと）",
+			PDFOptions{PageSize: PDFSizePaper, MarginMm: 10},
+			[]testImage{portrait, landscape},
+			[]pagePlace{
+				{true, a4, 35.75, 10, 138.5, 277},
+				{true, a4Land, 10, 35.75, 277, 138.5},
+			},
+		},
+		{
+			"用紙の余白は短い辺の 1/4 まで",
+			PDFOptions{PageSize: PDFSizePaper, Paper: PaperA4, MarginMm: 100},
+			[]testImage{{210, 297}},
+			[]pagePlace{{true, a4, 52.5, 74.25, 105, 148.5}},
+		},
+		{
+			"用紙の余白が負なら 0",
+			PDFOptions{PageSize: PDFSizePaper, Paper: PaperLetter, MarginMm: -5},
+			[]testImage{{2159, 2794}},
+			[]pagePlace{{true, gofpdf.SizeType{Wd: 215.9, Ht: 279.4}, 0, 0, 215.9, 279.4}},
+		},
+		{
+			"2-up の縦長の画像は横向きの用紙に左右",
+			PDFOptions{PageSize: PDFSizeNUp, NUp: 2, MarginMm: 10},
+			[]testImage{portrait, portrait, portrait},
+			[]pagePlace{
+				{true, a4Land, 30.5, 10, 95, 190},
+				{false, gofpdf.SizeType{}, 171.5, 10, 95, 190},
+				{true, a4Land, 30.5, 10, 95, 190},
+			},
+		},
+		{
+			"2-up の右から左",
+			PDFOptions{PageSize: PDFSizeNUp, NUp: 2, MarginMm: 10, RightToLeft: true},
+			[]testImage{portrait, portrait},
+			[]pagePlace{
+				{true, a4Land, 171.5, 10, 95, 190},
+				{false, gofpdf.SizeType{}, 30.5, 10, 95, 190},
+			},
+		},
+		{
+			"2-up の横長の画像は縦向きの用紙に上下",
+			PDFOptions{PageSize: PDFSizeNUp, NUp: 2, MarginMm: 10, RightToLeft: true},
+			[]testImage{landscape, landscape},
+			[]pagePlace{
+				{true, a4, 10, 30.5, 190, 95},
+				{false, gofpdf.SizeType{}, 10, 171.5, 190, 95},
+			},
+		},
+		{
+			"用紙の向きは最初の画像に合わせる",
+			PDFOptions{PageSize: PDFSizeNUp, NUp: 2, MarginMm: 10},
+			[]testImage{portrait, landscape},
+			[]pagePlace{
+				{true, a4Land, 30.5, 10, 95, 190},
+				{false, gofpdf.SizeType{}, 151, 71, 136, 68},
+			},
+		},
+		{
+			"4-up は 2×2",
+			PDFOptions{PageSize: PDFSizeNUp, NUp: 4, MarginMm: 10},
+			[]testImage{portrait, portrait, portrait, portrait, portrait},
+			[]pagePlace{
+				{true, a4, 22.25, 10, 68, 136},
+				{false, gofpdf.SizeType{}, 119.75, 10, 68, 136},
+				{false, gofpdf.SizeType{}, 22.25, 151, 68, 136},
+				{false, gofpdf.SizeType{}, 119.75, 151, 68, 136},
+				{true, a4, 22.25, 10, 68, 136},
+			},
+		},
+		{
+			"4-up の右から左",
+			PDFOptions{PageSize: PDFSizeNUp, NUp: 4, MarginMm: 10, RightToLeft: true},
+			[]testImage{portrait, portrait, portrait},
+			[]pagePlace{
+				{true, a4, 119.75, 10, 68, 136},
+				{false, gofpdf.SizeType{}, 22.25, 10, 68, 136},
+				{false, gofpdf.SizeType{}, 119.75, 151, 68, 136},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newPageLayout(tt.opt)
+			for i, img := range tt.images {
+				if got := l.place(img.w, img.h); !samePlace(got, tt.want[i]) {
+					t.Errorf("place #%d (%dx%d) = %+v, want %+v", i, img.w, img.h, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package output
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+
+	"AutoScreenShot/stitch"
+)
+
+// StitchedDirName はスクロールキャプチャをつなげた画像を保存するサブフォルダ名です。
+const StitchedDirName = "stitched"
+
+// StitchedImageName はつなげた縦長画像そのもののファイル名です。ページではないので ListImages は拾いません。
+const StitchedImageName = "stitched.png"
+
+// SaveStitched はつなげた縦長画像を dir/stitched に保存し、そのフォルダのパスとページの大きさ（ピクセル）を返します。
+// 縦長画像そのものは stitched.png（JPEG の大きさ制限を受けないよう PNG）に保存し、
+// pageAspect（高さ/幅）が 0 より大きければ、その比率のページに分割して page_00001.jpg … として w の形式で保存します。
+// pageAspect が 0 以下なら縦長画像全体を1ページとして保存します。
+// w が JPEG でも、JPEG に収まらない大きさ（幅か高さが 65535 ピクセルを超える）のページは PNG で保存します。
+func SaveStitched(dir string, tall image.Image, pageAspect float64, w ImageWriter) (outDir string, pageW, pageH int, err error) {
+	outDir = filepath.Join(dir, StitchedDirName)
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return "", 0, 0, err
+	}
+	if err := writeImage(filepath.Join(outDir, StitchedImageName), func(f *os.File) error {
+		return png.Encode(f, tall)
+	}); err != nil {
+		return "", 0, 0, err
+	}
+	pages := stitch.Split(tall, pageAspect)
+	for i, page := range pages {
+		pw := w
+		if _, ok := w.(JPEGWriter); ok && !fitsJPEG(page.Bounds()) {
+			pw = PNGWriter{}
+		}
+		path := filepath.Join(outDir, fmt.Sprintf("page_%05d%s", i+1, pw.Ext()))
+		if err := writeImage(path, func(f *os.File) error {
+			return pw.Encode(f, page)
+		}); err != nil {
+			return "", 0, 0, err
+		}
+	}
+	b := pages[0].Bounds()
+	return outDir, b.Dx(), b.Dy(), nil
+}
+
+// maxJPEGSize は JPEG に保存できる画像の幅と高さの上限（ピクセル）です。
+const maxJPEGSize = 65535
+
+// fitsJPEG は大きさ b の画像を JPEG に保存できるか返します。
+func fitsJPEG(b image.Rectangle) bool {
+	return b.Dx() <= maxJPEGSize && b.Dy() <= maxJPEGSize
+}
+
+// writeImage は path を作成して encode で書き込みます。失敗したらファイルを削除します。
+func writeImage(path string, encode func(f *os.File) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := encode(f); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,46 @@
+package output
+
+import (
+	"image"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSaveStitchedFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		h      int
+		aspect float64
+		files  []string
+	}{
+		{"JPEG に収まる", 300, 0, []string{"page_00001.jpg"}},
+		{"分割する", 300, 10, []string{"page_00001.jpg", "page_00002.jpg"}},
+		{"JPEG の上限を超えると PNG", maxJPEGSize + 1, 0, []string{"page_00001.png"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			tall := image.NewGray(image.Rect(0, 0, 16, tt.h))
+			outDir, w, h, err := SaveStitched(dir, tall, tt.aspect, JPEGWriter{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := 16 * tt.aspect; tt.aspect > 0 && (w != 16 || h != int(want)) {
+				t.Errorf("ページの大きさ %dx%d", w, h)
+			}
+			for _, name := range append([]string{StitchedImageName}, tt.files...) {
+				if _, err := os.Stat(filepath.Join(outDir, name)); err != nil {
+					t.Error(err)
+				}
+			}
+			pages, err := ListImages(outDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(pages) != len(tt.files) {
+				t.Errorf("ListImages = %v, want %v", pages, tt.files)
+			}
+		})
+	}
+}
@@ -0,0 +1,410 @@
+package output
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+
+	"AutoScreenShot/compare"
+)
+
+// マルチページ TIFF の圧縮方式
+const (
+	TIFFNone    = "none"    // 圧縮しない
+	TIFFLZW     = "lzw"     // LZW（可逆）
+	TIFFDeflate = "deflate" // Deflate（可逆）
+	TIFFG4      = "g4"      // CCITT G4。ページを白黒に2値化する（文字だけのページ向け）
+)
+
+// MultiPageTIFFExt は BuildTIFF で作るマルチページ TIFF の拡張子です。
+const MultiPageTIFFExt = ".tiff"
+
+// TIFFExportDirName は出力フォルダの中の、マルチページ TIFF を書き出すフォルダの名前です。
+// ページの画像も .tif / .tiff でありうるので、書き出した TIFF はページと別のフォルダに置きます（ListImages はフォルダの中を見ません）。
+const TIFFExportDirName = "tiff"
+
+// CheckTIFFCompression は c がマルチページ TIFF の圧縮方式（または既定を表す空文字列）であるかを確かめます。
+func CheckTIFFCompression(c string) error {
+	switch c {
+	case "", TIFFNone, TIFFLZW, TIFFDeflate, TIFFG4:
+		return nil
+	}
+	return fmt.Errorf("不明な TIFF の圧縮方式です: %q", c)
+}
+
+// TIFFOptions はマルチページ TIFF の設定です。
+type TIFFOptions struct {
+	Compression string // TIFFNone / TIFFLZW / TIFFDeflate / TIFFG4（空なら TIFFLZW）
+	DPI         int    // 記録する解像度（0 なら PDF のページの大きさと同じ 96 DPI）
+	Title       string // 文書名（DocumentName タグ）
+	// SkipBlank が true なら、空白（またはほぼ空白）のページを含めません。
+	SkipBlank bool
+	Blank     compare.BlankOptions
+	// Threshold は TIFFG4 で黒とみなす明るさの上限（0〜255。0 なら 128）です。
+	Threshold uint8
+}
+
+// BuildTIFF は指定フォルダ内の画像を BuildPDF と同じページ順で1つのマルチページ TIFF にまとめ、outPath に保存します。
+// 各ページには解像度と、ページ番号（PageNumber タグ）と元のファイル名（PageName タグ）を記録します。
+func BuildTIFF(dir, outPath string, opt TIFFOptions) error {
+	paths, err := listPages(dir, opt.SkipBlank, opt.Blank)
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+	if err := CheckTIFFCompression(opt.Compression); err != nil {
+		return err
+	}
+	if opt.Compression == "" {
+		opt.Compression = TIFFLZW
+	}
+	if opt.DPI <= 0 {
+		opt.DPI = pixelsPerInch
+	}
+
+	f, err := os.Create(outPath)
+	if err != nil {
+		return err
+	}
+	tw := &tiffWriter{w: bufio.NewWriter(f)}
+	err = tw.header()
+	for i, p := range paths {
+		if err != nil {
+			break
+		}
+		var img image.Image
+		if img, err = loadImage(p); err != nil {
+			err = fmt.Errorf("%s: %w", filepath.Base(p), err)
+			break
+		}
+		var pg tiffPage
+		if pg, err = encodeTIFFPage(img, opt); err != nil {
+			break
+		}
+		pg.name = filepath.Base(p)
+		pg.number, pg.total = i, len(paths)
+		err = tw.page(pg, opt)
+	}
+	if err == nil {
+		err = tw.w.Flush()
+	}
+	// 各 IFD の「次の IFD」の位置を書き戻す
+	for i := 0; err == nil && i < len(tw.ifds); i++ {
+		next := uint32(0)
+		if i+1 < len(tw.ifds) {
+			next = tw.ifds[i+1].start
+		}
+		var b [4]byte
+		binary.LittleEndian.PutUint32(b[:], next)
+		_, err = f.WriteAt(b[:], int64(tw.ifds[i].next))
+	}
+	if len(tw.ifds) > 0 && err == nil {
+		var b [4]byte
+		binary.LittleEndian.PutUint32(b[:], tw.ifds[0].start)
+		_, err = f.WriteAt(b[:], 4)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(outPath)
+	}
+	return err
+}
+
+func loadImage(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	return img, err
+}
+
+// TIFF のタグとデータ型
+const (
+	tagNewSubfileType  = 254
+	tagImageWidth      = 256
+	tagImageLength     = 257
+	tagBitsPerSample   = 258
+	tagCompression     = 259
+	tagPhotometric     = 262
+	tagDocumentName    = 269
+	tagStripOffsets    = 273
+	tagSamplesPerPixel = 277
+	tagRowsPerStrip    = 278
+	tagStripByteCounts = 279
+	tagXResolution     = 282
+	tagYResolution     = 283
+	tagPlanarConfig    = 284
+	tagPageName        = 285
+	tagResolutionUnit  = 296
+	tagPageNumber      = 297
+	tagSoftware        = 305
+
+	typeASCII    = 2
+	typeShort    = 3
+	typeLong     = 4
+	typeRational = 5
+)
+
+// Compression タグと PhotometricInterpretation タグの値
+var tiffCompressionCodes = map[string]uint16{TIFFNone: 1, TIFFG4: 4, TIFFLZW: 5, TIFFDeflate: 8}
+
+const (
+	photoWhiteIsZero = 0
+	photoBlackIsZero = 1
+	photoRGB         = 2
+)
+
+// tiffPage は符号化済みの1ページです。
+type tiffPage struct {
+	width, height int
+	photometric   uint16
+	bits          []uint16 // サンプルごとのビット数
+	data          []byte   // 圧縮済みの画素データ（1ストリップ）
+	name          string
+	number, total int
+}
+
+// encodeTIFFPage は img を opt の圧縮方式で符号化します。
+// TIFFG4 では2値化し、それ以外ではグレーの画像は 8 ビットのグレー、色のある画像は RGB にします。
+func encodeTIFFPage(img image.Image, opt TIFFOptions) (tiffPage, error) {
+	b := img.Bounds()
+	pg := tiffPage{width: b.Dx(), height: b.Dy()}
+	if opt.Compression == TIFFG4 {
+		th := opt.Threshold
+		if th == 0 {
+			th = 128
+		}
+		rows := make([][]bool, b.Dy())
+		for y := range rows {
+			rows[y] = make([]bool, b.Dx())
+			for x := range rows[y] {
+				rows[y][x] = color.GrayModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.Gray).Y < th
+			}
+		}
+		pg.photometric, pg.bits = photoWhiteIsZero, []uint16{1}
+		pg.data = encodeG4(rows, b.Dx())
+		return pg, nil
+	}
+
+	raw, gray := tiffSamples(img)
+	pg.photometric, pg.bits = photoRGB, []uint16{8, 8, 8}
+	if gray {
+		pg.photometric, pg.bits = photoBlackIsZero, []uint16{8}
+	}
+	switch opt.Compression {
+	case TIFFNone:
+		pg.data = raw
+	case TIFFLZW:
+		pg.data = encodeLZW(raw)
+	case TIFFDeflate:
+		var buf bytes.Buffer
+		zw := zlib.NewWriter(&buf)
+		if _, err := zw.Write(raw); err != nil {
+			return pg, err
+		}
+		if err := zw.Close(); err != nil {
+			return pg, err
+		}
+		pg.data = buf.Bytes()
+	}
+	return pg, nil
+}
+
+// tiffSamples は img の画素を行順に並べたデータを返します。すべての画素が灰色なら 1 画素 1 バイトのグレー、
+// そうでなければ 1 画素 3 バイトの RGB です。
+func tiffSamples(img image.Image) (raw []byte, gray bool) {
+	b := img.Bounds()
+	rgb := make([]byte, 0, b.Dx()*b.Dy()*3)
+	gray = true
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			rgb = append(rgb, c.R, c.G, c.B)
+			if c.R != c.G || c.G != c.B {
+				gray = false
+			}
+		}
+	}
+	if !gray {
+		return rgb, false
+	}
+	g := make([]byte, len(rgb)/3)
+	for i := range g {
+		g[i] = rgb[i*3]
+	}
+	return g, true
+}
+
+// tiffWriter はリトルエンディアンの TIFF を書き出します。書いた位置を数えておき、IFD の連結は最後に書き戻します。
+type tiffWriter struct {
+	w    *bufio.Writer
+	pos  uint32
+	ifds []struct{ start, next uint32 } // 各 IFD の先頭と「次の IFD」の位置
+}
+
+func (t *tiffWriter) write(b []byte) error {
+	n, err := t.w.Write(b)
+	t.pos += uint32(n)
+	if err == nil && t.pos%2 == 1 {
+		// 値の位置はワード境界にそろえる
+		err = t.w.WriteByte(0)
+		t.pos++
+	}
+	return err
+}
+
+func (t *tiffWriter) header() error {
+	// 最初の IFD の位置は最後に書き戻す
+	return t.write([]byte{'I', 'I', 42, 0, 0, 0, 0, 0})
+}
+
+// tiffEntry は IFD の1項目です。data は値をリトルエンディアンで並べたものです。
+type tiffEntry struct {
+	tag, typ uint16
+	count    uint32
+	data     []byte
+}
+
+func shorts(v ...uint16) []byte {
+	b := make([]byte, 2*len(v))
+	for i, x := range v {
+		binary.LittleEndian.PutUint16(b[2*i:], x)
+	}
+	return b
+}
+
+func long(v uint32) []byte {
+	return binary.LittleEndian.AppendUint32(nil, v)
+}
+
+func ascii(s string) tiffEntry {
+	return tiffEntry{typ: typeASCII, count: uint32(len(s) + 1), data: append([]byte(s), 0)}
+}
+
+// page は1ページ分の画素データ、4 バイトに収まらない値、IFD の順に書き出します。
+func (t *tiffWriter) page(pg tiffPage, opt TIFFOptions) error {
+	dataAt := t.pos
+	if err := t.write(pg.data); err != nil {
+		return err
+	}
+	res := append(long(uint32(opt.DPI)), long(1)...)
+	entries := []tiffEntry{
+		{tagNewSubfileType, typeLong, 1, long(2)}, // 複数ページの1ページ
+		{tagImageWidth, typeLong, 1, long(uint32(pg.width))},
+		{tagImageLength, typeLong, 1, long(uint32(pg.height))},
+		{tagBitsPerSample, typeShort, uint32(len(pg.bits)), shorts(pg.bits...)},
+		{tagCompression, typeShort, 1, shorts(tiffCompressionCodes[opt.Compression])},
+		{tagPhotometric, typeShort, 1, shorts(pg.photometric)},
+	}
+	if opt.Title != "" {
+		e := ascii(opt.Title)
+		e.tag = tagDocumentName
+		entries = append(entries, e)
+	}
+	pageName := ascii(pg.name)
+	pageName.tag = tagPageName
+	software := ascii("AutoScreenShot")
+	software.tag = tagSoftware
+	entries = append(entries,
+		tiffEntry{tagStripOffsets, typeLong, 1, long(dataAt)},
+		tiffEntry{tagSamplesPerPixel, typeShort, 1, shorts(uint16(len(pg.bits)))},
+		tiffEntry{tagRowsPerStrip, typeLong, 1, long(uint32(pg.height))},
+		tiffEntry{tagStripByteCounts, typeLong, 1, long(uint32(len(pg.data)))},
+		tiffEntry{tagXResolution, typeRational, 1, res},
+		tiffEntry{tagYResolution, typeRational, 1, res},
+		tiffEntry{tagPlanarConfig, typeShort, 1, shorts(1)},
+		pageName,
+		tiffEntry{tagResolutionUnit, typeShort, 1, shorts(2)}, // インチ
+		tiffEntry{tagPageNumber, typeShort, 2, shorts(uint16(pg.number), uint16(pg.total))},
+		software,
+	)
+
+	// 4 バイトに収まらない値を先に書き、IFD にはその位置を入れる
+	values := make([][]byte, len(entries))
+	for i, e := range entries {
+		if len(e.data) <= 4 {
+			values[i] = append(e.data, make([]byte, 4-len(e.data))...)
+			continue
+		}
+		values[i] = long(t.pos)
+		if err := t.write(e.data); err != nil {
+			return err
+		}
+	}
+	start := t.pos
+	ifd := shorts(uint16(len(entries)))
+	for i, e := range entries {
+		ifd = append(ifd, shorts(e.tag, e.typ)...)
+		ifd = append(ifd, long(e.count)...)
+		ifd = append(ifd, values[i]...)
+	}
+	next := t.pos + uint32(len(ifd))
+	ifd = append(ifd, 0, 0, 0, 0) // 次の IFD の位置（最後に書き戻す）
+	if err := t.write(ifd); err != nil {
+		return err
+	}
+	t.ifds = append(t.ifds, struct{ start, next uint32 }{start, next})
+	return nil
+}
+
+// encodeLZW は data を TIFF の LZW（上位ビットから詰め、符号の幅を1つ早く広げる方式）で圧縮します。
+func encodeLZW(data []byte) []byte {
+	const (
+		clearCode = 256
+		eoiCode   = 257
+		firstCode = 258
+		tableFull = 4094 // ここまで使ったら表を作り直す（符号の幅は 12 ビットまで）
+	)
+	w := &bitWriter{}
+	width := uint32(9)
+	next := uint32(firstCode)
+	table := map[uint32]uint32{} // 直前の符号 << 8 | 次のバイト → 符号
+	emit := func(code uint32) { w.write(bitCode{code, width}) }
+	// grow は表に符号を1つ加えた後の処理です。
+	grow := func() {
+		next++
+		if next == tableFull {
+			emit(clearCode)
+			clear(table)
+			width, next = 9, firstCode
+		} else if next >= 1<<width {
+			width++
+		}
+	}
+
+	emit(clearCode)
+	prefix := -1
+	for _, c := range data {
+		if prefix < 0 {
+			prefix = int(c)
+			continue
+		}
+		key := uint32(prefix)<<8 | uint32(c)
+		if code, ok := table[key]; ok {
+			prefix = int(code)
+			continue
+		}
+		emit(uint32(prefix))
+		table[key] = next
+		grow()
+		prefix = int(c)
+	}
+	if prefix >= 0 {
+		emit(uint32(prefix))
+		grow()
+	}
+	emit(eoiCode)
+	return w.bytes()
+}
@@ -0,0 +1,128 @@
+package output
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/image/tiff"
+)
+
+// tiffPages は TIFF の各ページを読み込みます。x/image/tiff は先頭のページしか読まないため、ヘッダの IFD の位置を差し替えて読ませます。
+func tiffPages(t *testing.T, data []byte) []image.Image {
+	t.Helper()
+	var pages []image.Image
+	for off := binary.LittleEndian.Uint32(data[4:8]); off != 0; {
+		patched := append([]byte(nil), data...)
+		binary.LittleEndian.PutUint32(patched[4:8], off)
+		img, err := tiff.Decode(bytes.NewReader(patched))
+		if err != nil {
+			t.Fatalf("page %d: %v", len(pages)+1, err)
+		}
+		pages = append(pages, img)
+		n := int(binary.LittleEndian.Uint16(data[off:]))
+		off = binary.LittleEndian.Uint32(data[int(off)+2+n*12:])
+	}
+	return pages
+}
+
+func writePNG(t *testing.T, path string, img image.Image) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBuildTIFF(t *testing.T) {
+	dir := t.TempDir()
+	colorPage := colors(50)
+	grayPage := image.NewGray(image.Rect(0, 0, 24, 16))
+	for i := range grayPage.Pix {
+		grayPage.Pix[i] = uint8(i * 5)
+	}
+	text := image.NewGray(image.Rect(0, 0, 40, 20))
+	for i := range text.Pix {
+		text.Pix[i] = 0xff
+		if i%7 < 2 {
+			text.Pix[i] = 0x20
+		}
+	}
+	writePNG(t, filepath.Join(dir, "screenshot_00001.png"), colorPage)
+	writePNG(t, filepath.Join(dir, "screenshot_00002.png"), grayPage)
+	writePNG(t, filepath.Join(dir, "screenshot_00003.png"), text)
+	// 書き出し先のフォルダにある前回のファイルはページにしない
+	if err := os.Mkdir(filepath.Join(dir, TIFFExportDirName), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, TIFFExportDirName, "前回.tiff"), []byte("II*\x00"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	want := []image.Image{colorPage, grayPage, text}
+
+	tests := []struct {
+		compression string
+		lossless    bool
+	}{
+		{"", true},
+		{TIFFNone, true},
+		{TIFFLZW, true},
+		{TIFFDeflate, true},
+		{TIFFG4, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.compression, func(t *testing.T) {
+			out := filepath.Join(dir, TIFFExportDirName, "本.tiff")
+			defer os.Remove(out)
+			if err := BuildTIFF(dir, out, TIFFOptions{Compression: tt.compression}); err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pages := tiffPages(t, data)
+			if len(pages) != len(want) {
+				t.Fatalf("%d pages, want %d", len(pages), len(want))
+			}
+			for i, p := range pages {
+				if p.Bounds() != want[i].Bounds() {
+					t.Errorf("page %d bounds = %v, want %v", i+1, p.Bounds(), want[i].Bounds())
+					continue
+				}
+				if tt.lossless && !sameImage(p, want[i]) {
+					t.Errorf("page %d pixels differ", i+1)
+				}
+			}
+			if tt.compression == TIFFG4 {
+				// 文字のページは2値化しても同じ
+				b := text.Bounds()
+				for y := b.Min.Y; y < b.Max.Y; y++ {
+					for x := b.Min.X; x < b.Max.X; x++ {
+						black := color.GrayModel.Convert(pages[2].At(x, y)).(color.Gray).Y < 128
+						if black != (text.GrayAt(x, y).Y < 128) {
+							t.Fatalf("G4 pixel (%d,%d) differs", x, y)
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestBuildTIFFUnknownCompression(t *testing.T) {
+	dir := t.TempDir()
+	writePNG(t, filepath.Join(dir, "screenshot_00001.png"), colors(2))
+	if err := BuildTIFF(dir, filepath.Join(dir, "a.tiff"), TIFFOptions{Compression: "jpeg"}); err == nil {
+		t.Error("BuildTIFF accepted an unknown compression")
+	}
+}
@@ -0,0 +1,204 @@
+package output
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"sort"
+
+	"golang.org/x/image/tiff"
+)
+
+// 画像の保存形式
+const (
+	FormatJPEG = ""     // JPEG（非可逆。既定）
+	FormatPNG  = "png"  // PNG（可逆）
+	FormatPNG8 = "png8" // 256 色以下に減色した PNG（文字や図のページ向け。256 色以下の画像なら可逆）
+	FormatTIFF = "tiff" // TIFF（Deflate 圧縮。可逆）
+)
+
+// ImageWriter は画像をある形式で書き出します。
+type ImageWriter interface {
+	// Ext はファイルの拡張子（".jpg" など）を返します。
+	Ext() string
+	// Encode は img を w に書き出します。
+	Encode(w io.Writer, img image.Image) error
+}
+
+// NewWriter は保存形式 format の ImageWriter を返します。quality は JPEG の品質（0 なら 85）です。
+func NewWriter(format string, quality int) (ImageWriter, error) {
+	switch format {
+	case FormatJPEG, "jpg", "jpeg":
+		return JPEGWriter{Quality: quality}, nil
+	case FormatPNG:
+		return PNGWriter{}, nil
+	case FormatPNG8:
+		return PNGWriter{Palette: true}, nil
+	case FormatTIFF, "tif":
+		return TIFFWriter{}, nil
+	}
+	return nil, fmt.Errorf("不明な保存形式です: %q", format)
+}
+
+// imageExts は ListImages がページとして拾う画像の拡張子です。
+var imageExts = map[string]bool{".jpg": true, ".jpeg": true, ".png": true, ".tif": true, ".tiff": true}
+
+// JPEGWriter は JPEG で書き出します。
+type JPEGWriter struct {
+	Quality int // 0 なら 85
+}
+
+func (JPEGWriter) Ext() string { return ".jpg" }
+
+func (j JPEGWriter) Encode(w io.Writer, img image.Image) error {
+	q := j.Quality
+	if q <= 0 {
+		q = defaultJpegQuality
+	}
+	return jpeg.Encode(w, img, &jpeg.Options{Quality: q})
+}
+
+// PNGWriter は PNG で書き出します。Palette が true なら 256 色以下に減色したパレット画像にします。
+// Fast が true なら圧縮率より速さを優先します（後で保存し直す途中の画像向け）。
+type PNGWriter struct {
+	Palette bool
+	Fast    bool
+}
+
+func (PNGWriter) Ext() string { return ".png" }
+
+func (p PNGWriter) Encode(w io.Writer, img image.Image) error {
+	if p.Palette {
+		img = Quantize(img)
+	}
+	enc := png.Encoder{CompressionLevel: png.BestCompression}
+	if p.Fast {
+		enc.CompressionLevel = png.BestSpeed
+	}
+	return enc.Encode(w, img)
+}
+
+// TIFFWriter は Deflate 圧縮の TIFF で書き出します。
+type TIFFWriter struct{}
+
+func (TIFFWriter) Ext() string { return ".tif" }
+
+func (TIFFWriter) Encode(w io.Writer, img image.Image) error {
+	return tiff.Encode(w, img, &tiff.Options{Compression: tiff.Deflate, Predictor: true})
+}
+
+// Quantize は img を 256 色以下のパレット画像にします。
+// 使われている色が 256 色以下ならそのままの色で（可逆）、多ければ各チャンネル上位 5 ビットでまとめた色のうち
+// 多く使われている 256 色（まとめた画素の平均の色）をパレットにして、最も近い色に置き換えます。
+// 文字のページがにじまないよう、ディザリングはしません。
+func Quantize(img image.Image) *image.Paletted {
+	if p, ok := img.(*image.Paletted); ok {
+		return p
+	}
+	b := img.Bounds()
+	buckets := map[color.RGBA]*colorSum{}
+	exact := true
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+			key := c
+			if !exact {
+				key = reduce(c)
+			}
+			sum := buckets[key]
+			if sum == nil {
+				sum = &colorSum{}
+				buckets[key] = sum
+			}
+			sum.add(c, 1)
+			if exact && len(buckets) > 256 {
+				exact = false
+				buckets = reduceBuckets(buckets)
+			}
+		}
+	}
+	sums := make([]*colorSum, 0, len(buckets))
+	for _, sum := range buckets {
+		sums = append(sums, sum)
+	}
+	sort.Slice(sums, func(i, j int) bool {
+		if sums[i].n != sums[j].n {
+			return sums[i].n > sums[j].n
+		}
+		return rgbaLess(sums[i].mean(), sums[j].mean())
+	})
+	if len(sums) > 256 {
+		sums = sums[:256]
+	}
+	pal := make(color.Palette, len(sums))
+	for i, sum := range sums {
+		pal[i] = sum.mean()
+	}
+
+	dst := image.NewPaletted(b, pal)
+	cache := map[color.RGBA]uint8{}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+			i, ok := cache[c]
+			if !ok {
+				i = uint8(pal.Index(c))
+				cache[c] = i
+			}
+			dst.SetColorIndex(x, y, i)
+		}
+	}
+	return dst
+}
+
+// colorSum は同じ色としてまとめた画素の数と色の合計です。
+type colorSum struct {
+	n          int
+	r, g, b, a int
+}
+
+func (s *colorSum) add(c color.RGBA, n int) {
+	s.n += n
+	s.r += int(c.R) * n
+	s.g += int(c.G) * n
+	s.b += int(c.B) * n
+	s.a += int(c.A) * n
+}
+
+func (s *colorSum) mean() color.RGBA {
+	return color.RGBA{R: uint8(s.r / s.n), G: uint8(s.g / s.n), B: uint8(s.b / s.n), A: uint8(s.a / s.n)}
+}
+
+// reduce は色の RGB を各チャンネル上位 5 ビットにまとめた色を返します（まとめる先の目印に使います）。
+func reduce(c color.RGBA) color.RGBA {
+	return color.RGBA{R: c.R &^ 7, G: c.G &^ 7, B: c.B &^ 7, A: c.A}
+}
+
+// reduceBuckets は色ごとの集計を、reduce した色ごとにまとめ直します。
+func reduceBuckets(buckets map[color.RGBA]*colorSum) map[color.RGBA]*colorSum {
+	out := make(map[color.RGBA]*colorSum, len(buckets))
+	for c, sum := range buckets {
+		key := reduce(c)
+		if out[key] == nil {
+			out[key] = &colorSum{}
+		}
+		out[key].add(sum.mean(), sum.n)
+	}
+	return out
+}
+
+func rgbaLess(a, b color.RGBA) bool {
+	if a.R != b.R {
+		return a.R < b.R
+	}
+	if a.G != b.G {
+		return a.G < b.G
+	}
+	if a.B != b.B {
+		return a.B < b.B
+	}
+	return a.A < b.A
+}
@@ -0,0 +1,102 @@
+package output
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// colors は n 色を使った 32x32 の画像を返します。
+func colors(n int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 32, 32))
+	for i := 0; i < 32*32; i++ {
+		c := i % n
+		img.Set(i%32, i/32, color.RGBA{uint8(c * 37), uint8(c * 91), uint8(c >> 2), 255})
+	}
+	return img
+}
+
+func TestNewWriter(t *testing.T) {
+	img := colors(20)
+	tests := []struct {
+		format   string
+		ext      string
+		lossless bool
+		wantErr  bool
+	}{
+		{FormatJPEG, ".jpg", false, false},
+		{"jpeg", ".jpg", false, false},
+		{FormatPNG, ".png", true, false},
+		{FormatPNG8, ".png", true, false},
+		{FormatTIFF, ".tif", true, false},
+		{"bmp", "", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			w, err := NewWriter(tt.format, 0)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewWriter(%q) error = %v", tt.format, err)
+			}
+			if err != nil {
+				return
+			}
+			if w.Ext() != tt.ext {
+				t.Errorf("Ext = %q, want %q", w.Ext(), tt.ext)
+			}
+			var buf bytes.Buffer
+			if err := w.Encode(&buf, img); err != nil {
+				t.Fatal(err)
+			}
+			got, _, err := image.Decode(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Bounds() != img.Bounds() {
+				t.Fatalf("bounds = %v, want %v", got.Bounds(), img.Bounds())
+			}
+			if tt.lossless && !sameImage(got, img) {
+				t.Error("lossless format changed the pixels")
+			}
+		})
+	}
+}
+
+func TestQuantize(t *testing.T) {
+	tests := []struct {
+		name  string
+		n     int
+		exact bool
+	}{
+		{"2色", 2, true},
+		{"256色ちょうど", 256, true},
+		{"257色以上は減色", 1000, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := colors(tt.n)
+			p := Quantize(img)
+			if len(p.Palette) > 256 {
+				t.Fatalf("palette has %d colors", len(p.Palette))
+			}
+			if got := sameImage(p, img); got != tt.exact {
+				t.Errorf("same pixels = %v, want %v", got, tt.exact)
+			}
+		})
+	}
+}
+
+func sameImage(a, b image.Image) bool {
+	if a.Bounds() != b.Bounds() {
+		return false
+	}
+	r := a.Bounds()
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if color.RGBAModel.Convert(a.At(x, y)) != color.RGBAModel.Convert(b.At(x, y)) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,124 @@
+package regress
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// diffCell は変更箇所をまとめるマス目の大きさ（ピクセル）です。
+const diffCell = 16
+
+// DiffResult は2枚の画像の差分です。
+type DiffResult struct {
+	Similarity float64           // 一致した画素の割合（0.0〜1.0）
+	Changed    []image.Rectangle // 変更があった範囲（b の座標）
+	Image      *image.RGBA       // b を薄く表示し、変更箇所を赤で強調した画像
+}
+
+// Diff は a と b を画素ごとに比較します。RGB のいずれかの差が threshold（0〜255）を超えた画素を変更とみなします。
+// 大きさが違う場合は左上をそろえて比べ、片方にしか無い部分は変更として扱います。
+func Diff(a, b image.Image, threshold int) DiffResult {
+	ab, bb := a.Bounds(), b.Bounds()
+	w, h := max(ab.Dx(), bb.Dx()), max(ab.Dy(), bb.Dy())
+	out := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(out, out.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+
+	cw, ch := (w+diffCell-1)/diffCell, (h+diffCell-1)/diffCell
+	cells := make([]bool, cw*ch)
+	same := 0
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			pa, inA := pixel(a, ab, x, y)
+			pb, inB := pixel(b, bb, x, y)
+			changed := inA != inB || (inA && differs(pa, pb, threshold))
+			base := pb
+			if !inB {
+				base = pa
+			}
+			if changed {
+				out.SetRGBA(x, y, color.RGBA{R: 255, A: 255})
+				cells[(y/diffCell)*cw+x/diffCell] = true
+				continue
+			}
+			same++
+			// 変更の無い部分は薄いグレーにして、赤が目立つようにする
+			l := uint8((uint32(base.R)*299 + uint32(base.G)*587 + uint32(base.B)*114) / 1000)
+			l = 160 + l/3
+			out.SetRGBA(x, y, color.RGBA{R: l, G: l, B: l, A: 255})
+		}
+	}
+	res := DiffResult{Image: out, Similarity: 1}
+	if w*h > 0 {
+		res.Similarity = float64(same) / float64(w*h)
+	}
+	res.Changed = regions(cells, cw, ch)
+	for i, r := range res.Changed {
+		r = image.Rect(r.Min.X*diffCell, r.Min.Y*diffCell, r.Max.X*diffCell, r.Max.Y*diffCell).Intersect(out.Bounds())
+		res.Changed[i] = r
+		outline(out, r, color.RGBA{R: 220, A: 255}, 2)
+	}
+	return res
+}
+
+func pixel(img image.Image, b image.Rectangle, x, y int) (color.RGBA, bool) {
+	if x >= b.Dx() || y >= b.Dy() {
+		return color.RGBA{}, false
+	}
+	return color.RGBAModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.RGBA), true
+}
+
+func differs(a, b color.RGBA, threshold int) bool {
+	d := func(x, y uint8) int {
+		if x > y {
+			return int(x - y)
+		}
+		return int(y - x)
+	}
+	return d(a.R, b.R) > threshold || d(a.G, b.G) > threshold || d(a.B, b.B) > threshold
+}
+
+// regions は変更のあったマス目を隣り合うものどうしでまとめ、それぞれの外接矩形（マス目単位）を返します。
+func regions(cells []bool, cw, ch int) []image.Rectangle {
+	seen := make([]bool, len(cells))
+	var rects []image.Rectangle
+	for i, c := range cells {
+		if !c || seen[i] {
+			continue
+		}
+		r := image.Rect(i%cw, i/cw, i%cw+1, i/cw+1)
+		stack := []int{i}
+		seen[i] = true
+		for len(stack) > 0 {
+			j := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			x, y := j%cw, j/cw
+			r = r.Union(image.Rect(x, y, x+1, y+1))
+			for _, n := range [][2]int{{x - 1, y}, {x + 1, y}, {x, y - 1}, {x, y + 1}} {
+				if n[0] < 0 || n[1] < 0 || n[0] >= cw || n[1] >= ch {
+					continue
+				}
+				k := n[1]*cw + n[0]
+				if cells[k] && !seen[k] {
+					seen[k] = true
+					stack = append(stack, k)
+				}
+			}
+		}
+		rects = append(rects, r)
+	}
+	return rects
+}
+
+// outline は r の枠線を太さ width で描きます。
+func outline(img *image.RGBA, r image.Rectangle, c color.RGBA, width int) {
+	u := image.NewUniform(c)
+	for _, e := range []image.Rectangle{
+		image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+width),
+		image.Rect(r.Min.X, r.Max.Y-width, r.Max.X, r.Max.Y),
+		image.Rect(r.Min.X, r.Min.Y, r.Min.X+width, r.Max.Y),
+		image.Rect(r.Max.X-width, r.Min.Y, r.Max.X, r.Max.Y),
+	} {
+		draw.Draw(img, e.Intersect(r), u, image.Point{}, draw.Src)
+	}
+}
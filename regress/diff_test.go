@@ -0,0 +1,79 @@
+package regress
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"reflect"
+	"testing"
+)
+
+// plain は大きさ w×h の白い画像に、rects を色 c で塗った画像を返します。
+func plain(w, h int, c color.Color, rects ...image.Rectangle) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	for _, r := range rects {
+		draw.Draw(img, r, image.NewUniform(c), image.Point{}, draw.Src)
+	}
+	return img
+}
+
+func TestDiff(t *testing.T) {
+	black := color.Black
+	light := color.RGBA{245, 245, 245, 255} // 白との差 10
+	tests := []struct {
+		name       string
+		a, b       image.Image
+		similarity float64
+		changed    []image.Rectangle
+	}{
+		{"同じ画像", plain(64, 64, black), plain(64, 64, black), 1, nil},
+		{"しきい値以下の差", plain(64, 64, black), plain(64, 64, light, image.Rect(0, 0, 64, 64)), 1, nil},
+		{
+			"隣り合うマス目はまとめる",
+			plain(64, 64, black),
+			plain(64, 64, black, image.Rect(0, 0, 20, 4), image.Rect(40, 40, 50, 50)),
+			1 - float64(80+100)/4096,
+			[]image.Rectangle{image.Rect(0, 0, 32, 16), image.Rect(32, 32, 64, 64)},
+		},
+		{
+			"斜めに接するマス目は別の箇所",
+			plain(64, 64, black),
+			plain(64, 64, black, image.Rect(0, 0, 1, 1), image.Rect(16, 16, 17, 17)),
+			1 - 2.0/4096,
+			[]image.Rectangle{image.Rect(0, 0, 16, 16), image.Rect(16, 16, 32, 32)},
+		},
+		{
+			"片方にしか無い部分は変更",
+			plain(64, 64, black),
+			plain(64, 80, black),
+			0.8,
+			[]image.Rectangle{image.Rect(0, 64, 64, 80)},
+		},
+		{
+			"左上をそろえる",
+			plain(64, 64, black).SubImage(image.Rect(16, 16, 48, 48)),
+			plain(32, 32, black),
+			1, nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := Diff(tt.a, tt.b, 24)
+			if d.Similarity != tt.similarity {
+				t.Errorf("Similarity = %v, want %v", d.Similarity, tt.similarity)
+			}
+			if !reflect.DeepEqual(d.Changed, tt.changed) {
+				t.Errorf("Changed = %v, want %v", d.Changed, tt.changed)
+			}
+			if d.Image.Bounds() != image.Rect(0, 0, max(tt.a.Bounds().Dx(), tt.b.Bounds().Dx()), max(tt.a.Bounds().Dy(), tt.b.Bounds().Dy())) {
+				t.Errorf("Image bounds = %v", d.Image.Bounds())
+			}
+			for _, r := range d.Changed {
+				if c := d.Image.RGBAAt(r.Min.X, r.Min.Y); c.G != 0 || c.R < 200 {
+					t.Errorf("changed region %v is not outlined in red: %v", r, c)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package regress
+
+import (
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	"os"
+
+	"AutoScreenShot/compare"
+)
+
+// 対応付けの方法
+const (
+	AlignIndex = "index" // ページ番号の順に対応付ける
+	AlignHash  = "hash"  // 知覚ハッシュで対応付ける（ページの追加・削除があってもずれない）
+)
+
+// Page は比較するセッションの1ページです。
+type Page struct {
+	Path string
+	Hash uint64 // compare.DCTHash の値
+}
+
+// LoadPages は paths（ページ順）の画像を読み込み、知覚ハッシュを計算します。
+// ファイルの内容のハッシュ（compare.Comparer の完全一致）では1画素の違いで別のページになり、
+// 変更のあったページを対応付けられないため、JPEG のゆれや小さな変更に強い pHash を使います。
+func LoadPages(paths []string) ([]Page, error) {
+	pages := make([]Page, 0, len(paths))
+	for _, p := range paths {
+		img, err := loadImage(p)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p, err)
+		}
+		pages = append(pages, Page{Path: p, Hash: compare.DCTHash(img)})
+	}
+	return pages, nil
+}
+
+// Pair は対応付けた2ページです。片方にしか無いページは、もう片方が -1 になります。
+type Pair struct {
+	A, B int // a, b の添字
+}
+
+// Align は2つのセッションのページを対応付けます。
+// AlignHash では知覚ハッシュのハミング距離が maxDistance 以下のページ同士を、順序を保ったまま
+// できるだけ多く対応付け（最長共通部分列）、対応しないページは追加・削除として扱います。
+func Align(a, b []Page, mode string, maxDistance int) ([]Pair, error) {
+	switch mode {
+	case "", AlignIndex:
+		return alignIndex(len(a), len(b)), nil
+	case AlignHash:
+		return alignHash(a, b, maxDistance), nil
+	}
+	return nil, fmt.Errorf("不明な対応付けの方法です: %q", mode)
+}
+
+func alignIndex(na, nb int) []Pair {
+	n := max(na, nb)
+	pairs := make([]Pair, n)
+	for i := range pairs {
+		pairs[i] = Pair{A: i, B: i}
+		if i >= na {
+			pairs[i].A = -1
+		}
+		if i >= nb {
+			pairs[i].B = -1
+		}
+	}
+	return pairs
+}
+
+func alignHash(a, b []Page, maxDistance int) []Pair {
+	match := func(i, j int) bool {
+		return compare.Hamming(a[i].Hash, b[j].Hash) <= maxDistance
+	}
+	// lcs[i][j] は a[i:], b[j:] の最長共通部分列の長さ
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if match(i, j) {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	var pairs []Pair
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case match(i, j) && lcs[i][j] == lcs[i+1][j+1]+1:
+			pairs = append(pairs, Pair{A: i, B: j})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			pairs = append(pairs, Pair{A: i, B: -1})
+			i++
+		default:
+			pairs = append(pairs, Pair{A: -1, B: j})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		pairs = append(pairs, Pair{A: i, B: -1})
+	}
+	for ; j < len(b); j++ {
+		pairs = append(pairs, Pair{A: -1, B: j})
+	}
+	return pairs
+}
+
+func loadImage(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	return img, err
+}
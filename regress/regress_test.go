@@ -0,0 +1,54 @@
+package regress
+
+import (
+	"reflect"
+	"testing"
+)
+
+// hashes は知覚ハッシュが hs のページを返します。
+func hashes(hs ...uint64) []Page {
+	pages := make([]Page, len(hs))
+	for i, h := range hs {
+		pages[i].Hash = h
+	}
+	return pages
+}
+
+func TestAlign(t *testing.T) {
+	// h(k) どうしの距離は 32、0 との距離は 16 になる
+	h := func(k int) uint64 { return 0xffff << (16 * k) }
+	tests := []struct {
+		name string
+		a, b []Page
+		mode string
+		want []Pair
+	}{
+		{"ページ番号順", hashes(h(0), h(1), h(2)), hashes(h(3), h(1)), AlignIndex,
+			[]Pair{{0, 0}, {1, 1}, {2, -1}}},
+		{"既定はページ番号順", hashes(h(0)), hashes(h(0), h(1)), "",
+			[]Pair{{0, 0}, {-1, 1}}},
+		{"同じページ", hashes(h(0), h(1)), hashes(h(0), h(1)^1), AlignHash,
+			[]Pair{{0, 0}, {1, 1}}},
+		{"削除と追加", hashes(h(0), h(1), h(2), h(3)), hashes(h(0), h(2)^1, 0, h(3)), AlignHash,
+			[]Pair{{0, 0}, {1, -1}, {2, 1}, {-1, 2}, {3, 3}}},
+		{"末尾の追加", hashes(h(0)), hashes(h(0), h(1), h(2)), AlignHash,
+			[]Pair{{0, 0}, {-1, 1}, {-1, 2}}},
+		{"先頭の削除", hashes(h(0), h(1)), hashes(h(1)), AlignHash,
+			[]Pair{{0, -1}, {1, 0}}},
+		{"片方が空", nil, hashes(h(0)), AlignHash, []Pair{{-1, 0}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Align(tt.a, tt.b, tt.mode, 12)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Align = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if _, err := Align(nil, nil, "lcs", 12); err == nil {
+		t.Error("Align with an unknown mode should fail")
+	}
+}
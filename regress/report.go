@@ -0,0 +1,211 @@
+package regress
+
+import (
+	"fmt"
+	"html/template"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+
+	"AutoScreenShot/compare"
+	"AutoScreenShot/output"
+)
+
+// ReportFileName は比較結果の HTML レポートのファイル名です。
+const ReportFileName = "report.html"
+
+// Options は2つのセッションを比較するときの設定です。
+type Options struct {
+	Align       string  // AlignIndex または AlignHash
+	MaxDistance int     // AlignHash で同じページとみなす最大ハミング距離（0 なら 12）
+	Threshold   int     // 画素を変更とみなす色の差（0 なら 24。JPEG のゆれを吸収する）
+	MinSame     float64 // この類似度以上なら「変更なし」とする（0 なら 0.999）
+}
+
+// 比較結果の状態
+const (
+	StatusSame    = "変更なし"
+	StatusChanged = "変更あり"
+	StatusAdded   = "追加"
+	StatusRemoved = "削除"
+)
+
+// Result は対応付けた1組のページの比較結果です。
+type Result struct {
+	Index          int     // 1 から始まる通し番号
+	A, B           string  // 各セッションのファイルパス（無ければ空）
+	Similarity     float64 // 一致した画素の割合
+	HashSimilarity float64 // 知覚ハッシュの類似度
+	Regions        int     // 変更箇所の数
+	DiffPath       string  // 差分画像のパス（変更がある場合のみ）
+	Status         string
+}
+
+// Compare は2つの出力フォルダのページを対応付けて比較し、outDir に差分画像と HTML レポートを書き出します。
+func Compare(dirA, dirB, outDir string, opt Options) ([]Result, error) {
+	if opt.MaxDistance <= 0 {
+		opt.MaxDistance = 12
+	}
+	if opt.Threshold <= 0 {
+		opt.Threshold = 24
+	}
+	if opt.MinSame <= 0 {
+		opt.MinSame = 0.999
+	}
+	pathsA, err := output.ListImages(dirA)
+	if err != nil {
+		return nil, err
+	}
+	pathsB, err := output.ListImages(dirB)
+	if err != nil {
+		return nil, err
+	}
+	a, err := LoadPages(pathsA)
+	if err != nil {
+		return nil, err
+	}
+	b, err := LoadPages(pathsB)
+	if err != nil {
+		return nil, err
+	}
+	pairs, err := Align(a, b, opt.Align, opt.MaxDistance)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return nil, err
+	}
+
+	results := make([]Result, 0, len(pairs))
+	for i, p := range pairs {
+		r := Result{Index: i + 1}
+		switch {
+		case p.A < 0:
+			r.B, r.Status = b[p.B].Path, StatusAdded
+		case p.B < 0:
+			r.A, r.Status = a[p.A].Path, StatusRemoved
+		default:
+			r.A, r.B = a[p.A].Path, b[p.B].Path
+			r.HashSimilarity = compare.Similarity(a[p.A].Hash, b[p.B].Hash)
+			if err := diffPair(&r, outDir, opt); err != nil {
+				return nil, err
+			}
+		}
+		results = append(results, r)
+	}
+
+	f, err := os.Create(filepath.Join(outDir, ReportFileName))
+	if err != nil {
+		return nil, err
+	}
+	if err := WriteHTML(f, results, outDir); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return results, f.Close()
+}
+
+func diffPair(r *Result, outDir string, opt Options) error {
+	imgA, err := loadImage(r.A)
+	if err != nil {
+		return err
+	}
+	imgB, err := loadImage(r.B)
+	if err != nil {
+		return err
+	}
+	d := Diff(imgA, imgB, opt.Threshold)
+	r.Similarity = d.Similarity
+	r.Regions = len(d.Changed)
+	if d.Similarity >= opt.MinSame {
+		r.Status = StatusSame
+		return nil
+	}
+	r.Status = StatusChanged
+	r.DiffPath = filepath.Join(outDir, fmt.Sprintf("diff_%05d.png", r.Index))
+	f, err := os.Create(r.DiffPath)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, d.Image); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+var reportTmpl = template.Must(template.New("report").Funcs(template.FuncMap{
+	"pct": func(f float64) string { return fmt.Sprintf("%.2f%%", f*100) },
+}).Parse(`<!DOCTYPE html>
+<html lang="ja">
+<head>
+<meta charset="utf-8">
+<title>セッション比較レポート</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; vertical-align: top; }
+tr.changed { background: #fee; }
+tr.added, tr.removed { background: #ffe; }
+img { max-width: 320px; }
+</style>
+</head>
+<body>
+<h1>セッション比較レポート</h1>
+<p>{{.Total}} 組中 変更あり {{.Changed}} / 追加 {{.Added}} / 削除 {{.Removed}}</p>
+<table>
+<tr><th>#</th><th>状態</th><th>A</th><th>B</th><th>画素の一致率</th><th>ハッシュ類似度</th><th>変更箇所</th><th>差分</th></tr>
+{{range .Rows}}<tr class="{{.Class}}">
+<td>{{.Index}}</td><td>{{.Status}}</td>
+<td>{{if .A}}<a href="{{.A}}">{{.AName}}</a>{{end}}</td>
+<td>{{if .B}}<a href="{{.B}}">{{.BName}}</a>{{end}}</td>
+<td>{{if and .A .B}}{{pct .Similarity}}{{end}}</td>
+<td>{{if and .A .B}}{{pct .HashSimilarity}}{{end}}</td>
+<td>{{if .Regions}}{{.Regions}}{{end}}</td>
+<td>{{if .Diff}}<a href="{{.Diff}}"><img src="{{.Diff}}" alt="diff"></a>{{end}}</td>
+</tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// WriteHTML は比較結果を HTML の表として書き出します。画像へのリンクは outDir からの相対パスにします。
+func WriteHTML(w io.Writer, results []Result, outDir string) error {
+	type row struct {
+		Result
+		Class, AName, BName, Diff string
+	}
+	rel := func(p string) string {
+		if p == "" {
+			return ""
+		}
+		if r, err := filepath.Rel(outDir, p); err == nil {
+			p = r
+		} else if abs, err := filepath.Abs(p); err == nil {
+			p = abs
+		}
+		return filepath.ToSlash(p)
+	}
+	data := struct {
+		Total, Changed, Added, Removed int
+		Rows                           []row
+	}{Total: len(results)}
+	for _, r := range results {
+		rw := row{Result: r, AName: filepath.Base(r.A), BName: filepath.Base(r.B), Diff: rel(r.DiffPath)}
+		rw.A, rw.B = rel(r.A), rel(r.B)
+		switch r.Status {
+		case StatusChanged:
+			rw.Class = "changed"
+			data.Changed++
+		case StatusAdded:
+			rw.Class = "added"
+			data.Added++
+		case StatusRemoved:
+			rw.Class = "removed"
+			data.Removed++
+		}
+		data.Rows = append(data.Rows, rw)
+	}
+	return reportTmpl.Execute(w, data)
+}
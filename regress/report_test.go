@@ -0,0 +1,130 @@
+package regress
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// page は行 lines 本の「文字」（黒い横棒）を並べたページの画像を返します。
+func page(lines int) *image.RGBA {
+	var rects []image.Rectangle
+	for l := 0; l < lines; l++ {
+		y := 10 + l*12
+		rects = append(rects, image.Rect(10, y, 50+(l*37)%100, y+6))
+	}
+	return plain(160, 120, color.Black, rects...)
+}
+
+// writePages は dir に imgs を screenshot_00001.png から順に書き出します。
+func writePages(t *testing.T, dir string, imgs ...image.Image) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for i, img := range imgs {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("screenshot_%05d.png", i+1)), buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	root := t.TempDir()
+	dirA, dirB, out := filepath.Join(root, "a"), filepath.Join(root, "b"), filepath.Join(root, "out")
+	edited := page(4)
+	for y := 60; y < 70; y++ {
+		for x := 120; x < 140; x++ {
+			edited.Set(x, y, color.Black)
+		}
+	}
+	// B では 2 ページ目が消え、4 ページ目の一部が変わり、最後に 1 ページ増えた
+	writePages(t, dirA, page(1), page(2), page(3), page(4))
+	writePages(t, dirB, page(1), page(3), edited, page(7))
+
+	results, err := Compare(dirA, dirB, out, Options{Align: AlignHash})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		status string
+		a, b   int // ページ番号（無ければ 0）
+	}{
+		{StatusSame, 1, 1},
+		{StatusRemoved, 2, 0},
+		{StatusSame, 3, 2},
+		{StatusChanged, 4, 3},
+		{StatusAdded, 0, 4},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("results = %+v", results)
+	}
+	name := func(dir string, n int) string {
+		if n == 0 {
+			return ""
+		}
+		return filepath.Join(dir, fmt.Sprintf("screenshot_%05d.png", n))
+	}
+	for i, w := range want {
+		r := results[i]
+		if r.Index != i+1 || r.Status != w.status || r.A != name(dirA, w.a) || r.B != name(dirB, w.b) {
+			t.Errorf("result #%d = %+v, want %+v", i+1, r, w)
+		}
+		if (r.DiffPath != "") != (w.status == StatusChanged) {
+			t.Errorf("result #%d DiffPath = %q", i+1, r.DiffPath)
+		}
+	}
+	if r := results[3]; r.Regions != 1 || r.Similarity >= 1 {
+		t.Errorf("changed page = %+v", r)
+	}
+	if _, err := os.Stat(results[3].DiffPath); err != nil {
+		t.Error(err)
+	}
+	if _, err := os.Stat(filepath.Join(out, ReportFileName)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWriteHTML(t *testing.T) {
+	root := filepath.FromSlash("/work")
+	out := filepath.Join(root, "out")
+	results := []Result{
+		{Index: 1, A: filepath.Join(root, "a", "p1.jpg"), B: filepath.Join(root, "b", "p1.jpg"), Similarity: 1, HashSimilarity: 1, Status: StatusSame},
+		{Index: 2, A: filepath.Join(root, "a", "p2.jpg"), B: filepath.Join(root, "b", "p2.jpg"), Similarity: 0.5, HashSimilarity: 0.75,
+			Regions: 3, DiffPath: filepath.Join(out, "diff_00002.png"), Status: StatusChanged},
+		{Index: 3, A: filepath.Join(root, "a", "<p3>.jpg"), Status: StatusRemoved},
+		{Index: 4, B: filepath.Join(root, "b", "p4.jpg"), Status: StatusAdded},
+	}
+	var buf bytes.Buffer
+	if err := WriteHTML(&buf, results, out); err != nil {
+		t.Fatal(err)
+	}
+	html := buf.String()
+	for _, s := range []string{
+		"4 組中 変更あり 1 / 追加 1 / 削除 1",
+		`<tr class="changed">`,
+		`<tr class="removed">`,
+		`<tr class="added">`,
+		`<a href="../a/p1.jpg">p1.jpg</a>`,
+		`<img src="diff_00002.png" alt="diff">`,
+		"<td>50.00%</td>\n<td>75.00%</td>\n<td>3</td>",
+		"&lt;p3&gt;.jpg",
+	} {
+		if !strings.Contains(html, s) {
+			t.Errorf("report does not contain %q", s)
+		}
+	}
+	if strings.Contains(html, "<p3>") {
+		t.Error("file names must be escaped")
+	}
+}
@@ -0,0 +1,62 @@
+package session
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// EventKind はイベントの種類です。
+type EventKind int
+
+const (
+	EventSaved        EventKind = iota // ページを保存した
+	EventSkipped                       // スキップ条件によりページを保存しなかった
+	EventBlankSkipped                  // 空白ページのため保存しなかった
+	EventKeySent                       // ページ送りのキーを送信した
+	EventTurnRetry                     // ページがめくれていないためキーを送り直した
+	EventRemoved                       // 末尾の同一フレームを削除した
+	EventError                         // キャプチャ・キー送信・保存などに失敗した
+	EventStopped                       // 終了条件が成立した
+	EventPaused                        // 対象ウィンドウをキャプチャできないため一時停止した
+	EventResumed                       // 一時停止から再開した
+	EventChapter                       // 保存するページを章の始まりにした
+)
+
+// Event は Runner が処理の各段階で通知するイベントです。
+type Event struct {
+	Kind EventKind
+	Page int    // 保存したページの番号（1 から。保存していなければ 0）
+	Path string // 保存・削除したファイル
+	Key  string // 送信したキー
+	Note string // 待機時間などの補足
+	Err  error
+}
+
+// String は実行ログに書き出す1行の説明です。
+func (e Event) String() string {
+	switch e.Kind {
+	case EventSaved:
+		return fmt.Sprintf("%s を保存（%s）", filepath.Base(e.Path), e.Note)
+	case EventSkipped:
+		return fmt.Sprintf("スキップ（%s）", e.Note)
+	case EventBlankSkipped:
+		return fmt.Sprintf("空白ページのためスキップ（%s）", e.Note)
+	case EventKeySent:
+		return fmt.Sprintf("キー %s を送信", e.Key)
+	case EventTurnRetry:
+		return fmt.Sprintf("ページが変わらないためキー %s を再送（%s）", e.Key, e.Note)
+	case EventRemoved:
+		return fmt.Sprintf("%s を削除（%s）", filepath.Base(e.Path), e.Note)
+	case EventError:
+		return fmt.Sprintf("%s: %v", e.Note, e.Err)
+	case EventStopped:
+		return fmt.Sprintf("終了条件が成立（%s）", e.Note)
+	case EventPaused:
+		return fmt.Sprintf("一時停止: %v", e.Err)
+	case EventResumed:
+		return fmt.Sprintf("再開（%s）", e.Note)
+	case EventChapter:
+		return fmt.Sprintf("%d ページ目から %s", e.Page, e.Note)
+	}
+	return fmt.Sprintf("イベント %d", int(e.Kind))
+}
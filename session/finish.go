@@ -0,0 +1,253 @@
+package session
+
+import (
+	"fmt"
+	"image"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"AutoScreenShot/chapter"
+	"AutoScreenShot/compare"
+	"AutoScreenShot/dedup"
+	"AutoScreenShot/layout"
+	"AutoScreenShot/output"
+	"AutoScreenShot/trim"
+)
+
+// Output は Finish の結果です。
+type Output struct {
+	PDFPath    string
+	TIFFPath   string // マルチページ TIFF を書き出した場合のパス
+	CBZPath    string // CBZ を書き出した場合のパス
+	EPUBPath   string // EPUB を書き出した場合のパス
+	Duplicates int    // セッション全体の重複ページとして削除・移動した枚数
+	DedupErr   error  // 重複ページの処理に失敗した場合のエラー（PDF の作成は続ける）
+	Trimmed    int    // 余白を切り取ったページ数
+}
+
+// Exports は PDF のほかに書き出したファイルのパスを返します。
+func (o Output) Exports() []string {
+	var paths []string
+	for _, p := range []string{o.TIFFPath, o.CBZPath, o.EPUBPath} {
+		if p != "" {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+// Finish はキャプチャ後の処理（重複ページの処理、session.json の記録、連結画像の保存、余白の切り取り、PDF・マルチページ TIFF・CBZ・EPUB の作成）を行います。
+// 経過は runLog に書き出します。
+func Finish(dir string, s Settings, cfg Config, res Result, runLog *log.Logger) (Output, error) {
+	var out Output
+	started := res.Started
+	if started.IsZero() {
+		started = time.Now()
+	}
+	placeholders := Placeholders{Date: started, Window: s.FocusWindowTitle}
+	// セッション全体で重複したページ（前のページに戻った、同じ挿絵が何度も出た など）を取り除く
+	if s.DedupAction != dedup.ActionOff {
+		out.Duplicates, out.DedupErr = removeDuplicates(dir, cfg.Comparer, image.Pt(s.Region.Width, s.Region.Height), s.DedupAction)
+		if out.DedupErr != nil {
+			runLog.Printf("重複ページの処理に失敗しました: %v", out.DedupErr)
+		}
+		runLog.Printf("重複ページ: %d 枚（%s）", out.Duplicates, dedup.ReportFileName)
+	}
+
+	runLog.Printf("終了: %d 枚保存、%d 枚削除、%d 枚スキップ、キー再送 %d 回（成功 %d / 失敗 %d）（%s）",
+		res.Count, res.Removed, res.Skipped, res.Turn.Retries, res.Turn.Recovered, res.Turn.Failed, res.StopReason)
+
+	record := Record{Settings: s, Result: &RecordResult{Result: res, Duplicates: out.Duplicates}}
+	if err := SaveRecord(dir, record); err != nil {
+		runLog.Printf("セッション情報の保存に失敗しました: %v", err)
+	}
+
+	// 実行中に付けた章の区切りを chapters.txt に残し、後から編集したものも含めて PDF のしおりにする
+	if err := chapter.Append(dir, res.Chapters); err != nil {
+		runLog.Printf("%s の保存に失敗しました: %v", chapter.FileName, err)
+	}
+	chapters, err := chapter.Load(dir)
+	if err != nil {
+		runLog.Printf("章の区切りを読み込めません（しおりを付けずに PDF を作ります）: %v", err)
+	}
+
+	iw, err := s.ImageWriter()
+	if err != nil {
+		return out, err
+	}
+	trimOpt := s.Trim()
+	// スクロール連結モードでは、つなげた画像（を分割したページ）を PDF にする
+	pdfSrcDir, pageW, pageH := dir, s.Region.Width, s.Region.Height
+	if stitcher := res.Stitcher; stitcher != nil {
+		if tall := stitcher.Image(); tall != nil {
+			// 余白を切り取るなら、切り取ってから保存形式にするまで可逆の PNG にしておく
+			pw := iw
+			if trimOpt.Mode != trim.ModeOff {
+				pw = output.PNGWriter{Fast: true}
+			}
+			d, w, h, err := output.SaveStitched(dir, tall, s.StitchPageAspect, pw)
+			if err != nil {
+				return out, fmt.Errorf("連結画像の保存に失敗しました: %w", err)
+			}
+			pdfSrcDir, pageW, pageH = d, w, h
+			trimOpt.Layout = layout.Options{} // 連結画像のページは分けない
+			runLog.Printf("連結画像: %d x %d（重なりが見つからなかったフレーム %d 枚）", tall.Bounds().Dx(), tall.Bounds().Dy(), stitcher.Misses)
+		}
+	}
+	// 周囲の余白を切り取ってから、ページを分けて保存形式にする（可逆の PNG で保存してあるので、JPEG でも劣化は1回だけ）。
+	// PDF のページの大きさは切り取った画像の大きさになる
+	if opt := trimOpt; opt.Mode != trim.ModeOff {
+		pages, err := output.ListImages(pdfSrcDir)
+		if err != nil {
+			return out, err
+		}
+		tr, err := trim.Apply(pages, opt, iw)
+		out.Trimmed = tr.Trimmed
+		if err != nil {
+			return out, fmt.Errorf("余白の切り取りに失敗しました: %w", err)
+		}
+		if opt.Mode == trim.ModeUniform && !tr.Box.Empty() {
+			runLog.Printf("余白の切り取り: %d 枚（範囲 %v）", tr.Trimmed, tr.Box)
+		} else {
+			runLog.Printf("余白の切り取り: %d 枚", tr.Trimmed)
+		}
+	}
+	// タイトルとプロパティの {date} などを置き換える。ファイル名と TIFF・CBZ・EPUB のタイトルにも置き換えたタイトルを使う
+	pages, err := output.CountPages(pdfSrcDir, s.PDFSkipBlank, cfg.Blank)
+	if err != nil {
+		return out, err
+	}
+	placeholders.Count = pages
+	meta := s.PDFMetadata(placeholders)
+	s.PDFTitle = meta.Title
+	out.PDFPath = filepath.Join(dir, PDFFileName(s.PDFTitle))
+
+	// 画像の大きさのページは、設定の DPI（指定があればキャプチャした画面の DPI）で実際の大きさにする
+	dpi := float64(s.PDFDPI)
+	if s.PDFScreenDPI && res.DisplayDPI > 0 {
+		dpi = res.DisplayDPI
+	}
+	if s.PDFPageSize == output.PDFSizeImage && dpi > 0 {
+		runLog.Printf("PDF のページの解像度: %.0f DPI", dpi)
+	}
+	pdfOpt := output.PDFOptions{
+		Metadata:    meta,
+		WidthPx:     pageW,
+		HeightPx:    pageH,
+		SkipBlank:   s.PDFSkipBlank,
+		Blank:       cfg.Blank,
+		RightToLeft: s.ReadingOrder == layout.OrderRTL,
+		PageLayout:  s.PDFPageLayout,
+		CoverAlone:  s.PDFCoverAlone,
+		Chapters:    chapters,
+		PageSize:    s.PDFPageSize,
+		Paper:       s.PDFPaper,
+		MarginMm:    s.PDFMarginMm,
+		DPI:         dpi,
+		NUp:         s.PDFNUp,
+	}
+	if err := output.BuildPDF(pdfSrcDir, out.PDFPath, pdfOpt); err != nil {
+		return out, fmt.Errorf("PDF生成に失敗しました: %w", err)
+	}
+	// PDF と同じページ順でマルチページ TIFF にもまとめる。ページと混ざらないよう tiff フォルダに書き出す
+	if s.ExportTIFF {
+		tiffDir := filepath.Join(dir, output.TIFFExportDirName)
+		if err := os.MkdirAll(tiffDir, 0755); err != nil {
+			return out, fmt.Errorf("TIFF の作成に失敗しました: %w", err)
+		}
+		tiffPath := filepath.Join(tiffDir, TIFFFileName(s.PDFTitle))
+		tiffOpt := output.TIFFOptions{
+			Compression: s.TIFFCompression,
+			DPI:         s.TIFFDPI,
+			Title:       s.PDFTitle,
+			SkipBlank:   s.PDFSkipBlank,
+			Blank:       cfg.Blank,
+		}
+		if err := output.BuildTIFF(pdfSrcDir, tiffPath, tiffOpt); err != nil {
+			return out, fmt.Errorf("TIFF の作成に失敗しました: %w", err)
+		}
+		out.TIFFPath = tiffPath
+		runLog.Printf("マルチページ TIFF: %s", filepath.Base(tiffPath))
+	}
+	// タブレットや電子書籍リーダー向けに CBZ と EPUB にもまとめる
+	book := s.Book(cfg.Blank)
+	if s.ExportCBZ {
+		cbzPath := filepath.Join(dir, CBZFileName(s.PDFTitle))
+		if err := output.BuildCBZ(pdfSrcDir, cbzPath, book); err != nil {
+			return out, fmt.Errorf("CBZ の作成に失敗しました: %w", err)
+		}
+		out.CBZPath = cbzPath
+		runLog.Printf("CBZ: %s", filepath.Base(cbzPath))
+	}
+	if s.ExportEPUB {
+		epubPath := filepath.Join(dir, EPUBFileName(s.PDFTitle))
+		if err := output.BuildEPUB(pdfSrcDir, epubPath, book); err != nil {
+			return out, fmt.Errorf("EPUB の作成に失敗しました: %w", err)
+		}
+		out.EPUBPath = epubPath
+		runLog.Printf("EPUB: %s", filepath.Base(epubPath))
+	}
+	return out, nil
+}
+
+// removeDuplicates は dir のページから重複を探して action に従って処理し、その枚数を返します。frame はキャプチャ範囲の大きさです。
+func removeDuplicates(dir string, cmp compare.Comparer, frame image.Point, action string) (int, error) {
+	pages, err := output.ListImages(dir)
+	if err != nil {
+		return 0, err
+	}
+	dups, err := dedup.Find(pages, cmp, frame)
+	if err != nil {
+		return 0, err
+	}
+	return len(dups), dedup.Apply(dir, dups, action)
+}
+
+// PDFFileName は PDF タイトルから出力ファイル名を作ります。タイトルが空なら screenshots.pdf です。
+func PDFFileName(title string) string {
+	name := sanitizeFileName(title)
+	if name == "" {
+		return "screenshots.pdf"
+	}
+	if !strings.HasSuffix(strings.ToLower(name), ".pdf") {
+		name += ".pdf"
+	}
+	return name
+}
+
+// TIFFFileName は PDF タイトルからマルチページ TIFF の出力ファイル名を作ります。タイトルが空なら screenshots.tiff です。
+func TIFFFileName(title string) string {
+	return replacePDFExt(title, output.MultiPageTIFFExt)
+}
+
+// CBZFileName は PDF タイトルから CBZ の出力ファイル名を作ります。タイトルが空なら screenshots.cbz です。
+func CBZFileName(title string) string {
+	return replacePDFExt(title, ".cbz")
+}
+
+// EPUBFileName は PDF タイトルから EPUB の出力ファイル名を作ります。タイトルが空なら screenshots.epub です。
+func EPUBFileName(title string) string {
+	return replacePDFExt(title, ".epub")
+}
+
+// replacePDFExt は PDFFileName の拡張子を ext に替えたファイル名を返します。
+func replacePDFExt(title, ext string) string {
+	name := PDFFileName(title)
+	return name[:len(name)-len(".pdf")] + ext
+}
+
+// sanitizeFileName はタイトルをWindowsのファイル名として使えるように無効文字を除去します。
+func sanitizeFileName(title string) string {
+	const invalid = `\/:*?"<>|`
+	s := strings.TrimSpace(title)
+	var b strings.Builder
+	for _, r := range s {
+		if !strings.ContainsRune(invalid, r) && r >= 0x20 {
+			b.WriteRune(r)
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
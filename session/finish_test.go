@@ -0,0 +1,66 @@
+package session
+
+import (
+	"fmt"
+	"image/png"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"AutoScreenShot/dedup"
+	"AutoScreenShot/output"
+)
+
+func TestFinishTIFFExport(t *testing.T) {
+	dir := t.TempDir()
+	for i := 1; i <= 3; i++ {
+		f, err := os.Create(filepath.Join(dir, fmt.Sprintf("screenshot_%05d.png", i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := png.Encode(f, testPage(i)); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+	}
+	s := DefaultSettings()
+	s.PDFTitle = "本-{count}"
+	s.ExportTIFF = true
+	s.DedupAction = dedup.ActionRemove
+	b := testPage(1).Bounds()
+	s.Region = Region{Width: b.Dx(), Height: b.Dy()}
+	cfg, err := s.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 2回目も、1回目に書き出した TIFF をページとして数えたり重複として消したりしない
+	for run := 1; run <= 2; run++ {
+		out, err := Finish(dir, s, cfg, Result{Count: 3}, log.New(io.Discard, "", 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := filepath.Join(dir, output.TIFFExportDirName, "本-3.tiff"); out.TIFFPath != want {
+			t.Errorf("run %d: TIFFPath = %q, want %q", run, out.TIFFPath, want)
+		}
+		if want := filepath.Join(dir, "本-3.pdf"); out.PDFPath != want {
+			t.Errorf("run %d: PDFPath = %q, want %q", run, out.PDFPath, want)
+		}
+		if out.Duplicates != 0 || out.DedupErr != nil {
+			t.Errorf("run %d: duplicates = %d, %v", run, out.Duplicates, out.DedupErr)
+		}
+	}
+	pages, err := output.ListImages(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, p := range pages {
+		names = append(names, filepath.Base(p))
+	}
+	if want := []string{"screenshot_00001.png", "screenshot_00002.png", "screenshot_00003.png"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("pages = %q, want %q", names, want)
+	}
+}
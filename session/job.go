@@ -0,0 +1,80 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Job は1回のキャプチャ（Runner の実行から Finish まで）に使うものをまとめたものです。
+// 画面とキーボードを使う場合は ScreenJob で組み立てます。
+type Job struct {
+	Dir      string // 出力先フォルダ
+	Settings Settings
+	Config   Config // Settings.Config で組み立てた設定（呼び出し側で待機時間などを変えてもよい）
+	Capturer Capturer
+	Input    InputSender
+	// Watch は章の区切りのキーを監視します（keyboard.Watch）。nil なら監視しません。
+	Watch func(ctx context.Context, keyOperation string, onPress func()) error
+	// DisplayDPI はキャプチャした画面の DPI です（Result.DisplayDPI に記録します。0 なら不明）。
+	DisplayDPI float64
+	// Stderr はエラーや一時停止などを書き出す先です。nil なら os.Stderr です。
+	Stderr io.Writer
+}
+
+// Run は出力先フォルダを作成し、設定を session.json に記録してから Runner を実行し、Finish でキャプチャ後の処理を行います。
+// キャプチャの中断や重複ページの処理の失敗は Stderr に書き出して続け、ページがあれば PDF を作ります。
+// フォルダを作れない場合と Finish が失敗した場合だけエラーを返します。
+func (j Job) Run(ctx context.Context) (Result, Output, error) {
+	stderr := j.Stderr
+	if stderr == nil {
+		stderr = os.Stderr
+	}
+	if err := os.MkdirAll(j.Dir, 0755); err != nil {
+		return Result{}, Output{}, fmt.Errorf("フォルダ作成に失敗しました: %w", err)
+	}
+	// 比較ルールを含む設定をセッションと一緒に保存しておく（後から同じ条件で作り直せるように）
+	if err := SaveRecord(j.Dir, Record{Settings: j.Settings}); err != nil {
+		fmt.Fprintf(stderr, "セッション情報の保存に失敗しました: %v\n", err)
+	}
+	runLog, closeLog, err := OpenRunLog(j.Dir)
+	if err != nil {
+		fmt.Fprintf(stderr, "実行ログを作成できません: %v\n", err)
+	}
+	defer closeLog()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	runner := Runner{
+		Config:   j.Config,
+		Capturer: j.Capturer,
+		Input:    j.Input,
+		Sink:     j.Settings.Sink(j.Dir),
+		Workers:  DefaultWorkers,
+		OnEvent: func(e Event) {
+			if e.Kind == EventError || e.Kind == EventPaused || e.Kind == EventResumed {
+				fmt.Fprintln(stderr, e)
+			}
+			if e.Kind != EventKeySent {
+				runLog.Print(e)
+			}
+		},
+	}
+	if j.Watch != nil {
+		runner.Marks = WatchMarks(ctx, j.Settings.ChapterKey, j.Watch, func(err error) {
+			fmt.Fprintf(stderr, "章の区切りのキーを監視できません: %v\n", err)
+		})
+	}
+	res, err := runner.Run(ctx)
+	if err != nil {
+		fmt.Fprintf(stderr, "キャプチャを中断しました: %v\n", err)
+	}
+	res.DisplayDPI = j.DisplayDPI
+
+	out, err := Finish(j.Dir, j.Settings, j.Config, res, runLog)
+	if out.DedupErr != nil {
+		fmt.Fprintf(stderr, "重複ページの処理に失敗しました: %v\n", out.DedupErr)
+	}
+	return res, out, err
+}
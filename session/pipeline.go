@@ -0,0 +1,120 @@
+package session
+
+import (
+	"image"
+	"sync"
+)
+
+// DefaultWorkers は Runner.Workers に使う、保存を並行して行うゴルーチン数の目安です。
+const DefaultWorkers = 2
+
+// saveJob は1ページ分の保存の依頼です。
+type saveJob struct {
+	index int
+	img   image.Image
+	blank bool
+	note  string
+}
+
+// saveResult は保存の結果です。
+type saveResult struct {
+	saveJob
+	path string
+	size int64
+	err  error
+}
+
+// saver は Sink への保存を複数のゴルーチンで行います。
+// 結果を受け取っていない保存が limit に達すると、submit は結果が届くまで待ちます（キャプチャが保存を追い越しすぎないように）。
+// 結果は保存が終わった順ではなく、受け付けた順（ページ番号順）に返します。
+type saver struct {
+	sink    Sink
+	limit   int
+	jobs    chan saveJob
+	results chan saveResult
+	wg      sync.WaitGroup
+	pending int                // 受け付けて結果をまだ受け取っていない数
+	next    int                // 次に返すページ番号
+	done    map[int]saveResult // 受け取ったが、前のページの結果を待っている結果
+}
+
+func newSaver(sink Sink, workers int) *saver {
+	s := &saver{
+		sink:    sink,
+		limit:   2 * workers,
+		jobs:    make(chan saveJob, workers),
+		results: make(chan saveResult, 2*workers),
+		next:    1,
+		done:    map[int]saveResult{},
+	}
+	for i := 0; i < workers; i++ {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			for j := range s.jobs {
+				res := saveResult{saveJob: j}
+				res.path, res.size, res.err = s.sink.Save(j.index, j.img)
+				res.img = nil // 保存が済んだ画像は早く手放す
+				s.results <- res
+			}
+		}()
+	}
+	return s
+}
+
+// submit は保存を受け付け、その間に届いた結果を返します。
+func (s *saver) submit(j saveJob) []saveResult {
+	for s.pending >= s.limit {
+		s.put(<-s.results)
+	}
+	s.jobs <- j
+	s.pending++
+	return s.receive()
+}
+
+// receive は待たずに受け取れる結果を返します。
+func (s *saver) receive() []saveResult {
+	for {
+		select {
+		case res := <-s.results:
+			s.put(res)
+		default:
+			return s.ordered()
+		}
+	}
+}
+
+// wait は受け付けたすべての保存が終わるまで待ち、残りの結果を返します。
+func (s *saver) wait() []saveResult {
+	for s.pending > 0 {
+		s.put(<-s.results)
+	}
+	return s.ordered()
+}
+
+// close は残りの保存を待ってからゴルーチンを終了し、残りの結果を返します。
+func (s *saver) close() []saveResult {
+	done := s.wait()
+	close(s.jobs)
+	s.wg.Wait()
+	return done
+}
+
+func (s *saver) put(res saveResult) {
+	s.pending--
+	s.done[res.index] = res
+}
+
+// ordered は前のページがすべて揃った結果を、ページ番号順に取り出します。
+func (s *saver) ordered() []saveResult {
+	var out []saveResult
+	for {
+		res, ok := s.done[s.next]
+		if !ok {
+			return out
+		}
+		delete(s.done, s.next)
+		out = append(out, res)
+		s.next++
+	}
+}
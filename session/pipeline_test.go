@@ -0,0 +1,171 @@
+package session
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+
+	"AutoScreenShot/layout"
+	"AutoScreenShot/output"
+	"AutoScreenShot/stop"
+)
+
+// fakeScreen はキーを送るたびに次のページを表示するビューアの偽物です。最後のページではキーを送っても変わりません。
+// stuck[n] が 1 以上のページ（0 から）では、その回数だけキーを送ってもページが変わりません。
+// onCapture が nil でなければ、キャプチャのたびにキャプチャした回数を渡して呼びます。
+type fakeScreen struct {
+	pages     []image.Image
+	page      int
+	stuck     map[int]int
+	sent      []string
+	captures  int
+	onCapture func(n int)
+}
+
+func (s *fakeScreen) Capture() (image.Image, error) {
+	s.captures++
+	if s.onCapture != nil {
+		s.onCapture(s.captures)
+	}
+	return s.pages[s.page], nil
+}
+
+func (s *fakeScreen) Send(key string) error {
+	s.sent = append(s.sent, key)
+	if s.stuck[s.page] > 0 {
+		s.stuck[s.page]--
+		return nil
+	}
+	if s.page < len(s.pages)-1 {
+		s.page++
+	}
+	return nil
+}
+
+// testPage はページ番号 n ごとに模様の違う、ノイズの多い（エンコードに時間のかかる）画像を返します。
+func testPage(n int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 64, 48))
+	for y := 0; y < 48; y++ {
+		for x := 0; x < 64; x++ {
+			v := uint8((x*7 + y*13 + n*31) * (n + 3))
+			img.Set(x, y, color.RGBA{v, v ^ uint8(n*17), uint8(x * y), 255})
+		}
+	}
+	return img
+}
+
+func testPages(n int) []image.Image {
+	pages := make([]image.Image, n)
+	for i := range pages {
+		pages[i] = testPage(i)
+	}
+	return pages
+}
+
+func newTestRunner(screen *fakeScreen, sink Sink, workers int) *Runner {
+	return &Runner{
+		Config: Config{
+			KeyOperation: "Enter",
+			Stop:         stop.SameFrames(3),
+		},
+		Capturer: screen,
+		Input:    screen,
+		Sink:     sink,
+		Workers:  workers,
+	}
+}
+
+// readDir は dir のファイル名と内容を返します。
+func readDir(t *testing.T, dir string) map[string][]byte {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{}
+	for _, e := range entries {
+		b, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[e.Name()] = b
+	}
+	return files
+}
+
+func TestRunWorkersMatchesSerial(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		layout layout.Options
+		want   []string
+	}{
+		{"JPEG", output.FormatJPEG, layout.Options{}, []string{
+			"screenshot_00001.jpg", "screenshot_00002.jpg", "screenshot_00003.jpg", "screenshot_00004.jpg", "screenshot_00005.jpg",
+		}},
+		{"PNG", output.FormatPNG, layout.Options{}, []string{
+			"screenshot_00001.png", "screenshot_00002.png", "screenshot_00003.png", "screenshot_00004.png", "screenshot_00005.png",
+		}},
+		{"分割", output.FormatJPEG, layout.Options{Mode: layout.SplitCenter, Order: layout.OrderRTL}, []string{
+			"screenshot_00001_1.jpg", "screenshot_00001_2.jpg", "screenshot_00002_1.jpg", "screenshot_00002_2.jpg",
+			"screenshot_00003_1.jpg", "screenshot_00003_2.jpg", "screenshot_00004_1.jpg", "screenshot_00004_2.jpg",
+			"screenshot_00005_1.jpg", "screenshot_00005_2.jpg",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var outputs []map[string][]byte
+			var results []Result
+			for _, workers := range []int{0, 3} {
+				dir := t.TempDir()
+				screen := &fakeScreen{pages: testPages(5)}
+				r := newTestRunner(screen, &DirSink{Dir: dir, Format: tt.format, Layout: tt.layout}, workers)
+				res, err := r.Run(context.Background())
+				if err != nil {
+					t.Fatalf("Workers=%d: %v", workers, err)
+				}
+				outputs = append(outputs, readDir(t, dir))
+				results = append(results, res)
+			}
+			serial, parallel := outputs[0], outputs[1]
+			var names []string
+			for name := range serial {
+				names = append(names, name)
+			}
+			slices.Sort(names)
+			if !reflect.DeepEqual(names, tt.want) {
+				t.Errorf("files = %q, want %q", names, tt.want)
+			}
+			if len(parallel) != len(serial) {
+				t.Errorf("Workers>0 wrote %d files, serial wrote %d", len(parallel), len(serial))
+			}
+			for name, b := range serial {
+				if !bytes.Equal(parallel[name], b) {
+					t.Errorf("%s differs between Workers>0 and serial", name)
+				}
+			}
+			s, p := results[0], results[1]
+			if s.Count != 7 || s.Removed != 2 {
+				t.Errorf("serial Count=%d Removed=%d, want 7 and 2", s.Count, s.Removed)
+			}
+			if p.Count != s.Count || p.Removed != s.Removed || p.StopReason != s.StopReason {
+				t.Errorf("Workers>0 Count=%d Removed=%d StopReason=%q, serial %d %d %q",
+					p.Count, p.Removed, p.StopReason, s.Count, s.Removed, s.StopReason)
+			}
+			if len(p.Saved) != len(s.Saved) {
+				t.Fatalf("Workers>0 Saved = %q, serial %q", p.Saved, s.Saved)
+			}
+			for i := range s.Saved {
+				if filepath.Base(p.Saved[i]) != filepath.Base(s.Saved[i]) {
+					t.Errorf("Saved[%d] = %s, serial %s", i, filepath.Base(p.Saved[i]), filepath.Base(s.Saved[i]))
+				}
+			}
+		})
+	}
+}
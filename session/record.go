@@ -0,0 +1,63 @@
+package session
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// RecordFileName は出力フォルダに保存するセッション情報のファイル名です。
+const RecordFileName = "session.json"
+
+// Record は session.json に保存する内容です。
+type Record struct {
+	Settings Settings
+	Result   *RecordResult `json:",omitempty"` // 実行終了後に記録する
+}
+
+// RecordResult は session.json に記録する1回の実行結果です。
+type RecordResult struct {
+	Result
+	Duplicates int // セッション全体の重複ページとして削除・移動した枚数
+}
+
+// SaveRecord は実行時の設定と結果を出力フォルダに JSON で保存します。
+func SaveRecord(dir string, record Record) error {
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, RecordFileName), data, 0644)
+}
+
+// LoadRecord は session.json を読み込みます。path がフォルダならその中の session.json を読みます。
+func LoadRecord(path string) (Record, error) {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		path = filepath.Join(path, RecordFileName)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Record{}, err
+	}
+	// 記録に無い項目はダイアログの初期値にする
+	record := Record{Settings: DefaultSettings()}
+	if err := json.Unmarshal(data, &record); err != nil {
+		return Record{}, err
+	}
+	return record, nil
+}
+
+// RunLogFileName は出力フォルダに保存する実行ログのファイル名です。
+const RunLogFileName = "run.log"
+
+// OpenRunLog は出力フォルダに実行ログを作成します。
+// 作成できなかった場合も、書き込みを捨てる Logger を返します。
+func OpenRunLog(dir string) (*log.Logger, func(), error) {
+	f, err := os.Create(filepath.Join(dir, RunLogFileName))
+	if err != nil {
+		return log.New(io.Discard, "", 0), func() {}, err
+	}
+	return log.New(f, "", log.LstdFlags|log.Lmicroseconds), func() { f.Close() }, nil
+}
@@ -0,0 +1,72 @@
+//go:build windows || linux
+
+package session
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"time"
+
+	"AutoScreenShot/capture"
+	"AutoScreenShot/focus"
+	"AutoScreenShot/keyboard"
+)
+
+// ScreenJob は実際の画面をキャプチャし、キーボードでページを送る Job を組み立てます。
+// FocusWindowTitle のウィンドウがあれば前面にしてから返します。
+// RegionRelative のときは、キャプチャのたびにそのウィンドウの位置から範囲を求め直します。
+func ScreenJob(s Settings) (Job, error) {
+	cfg, err := s.Config()
+	if err != nil {
+		return Job{}, fmt.Errorf("設定が不正です: %w", err)
+	}
+	// フォーカスするアプリが指定されていれば、そのウィンドウを前面にする
+	if s.FocusWindowTitle != "" {
+		if focus.SetForegroundByTitle(s.FocusWindowTitle) {
+			time.Sleep(300 * time.Millisecond) // ウィンドウが前面になるまで待つ
+		} else if !s.RegionRelative {
+			fmt.Fprintf(os.Stderr, "ウィンドウが見つかりません: %s\n", s.FocusWindowTitle)
+		}
+	}
+	capturer, err := screenCapturer(s)
+	if err != nil {
+		return Job{}, err
+	}
+	// PDF のページを画面で見たときの大きさにできるよう（PDFScreenDPI）、画面の DPI を記録する
+	dpi, _ := capture.DisplayDPI()
+	return Job{
+		Dir:        s.OutputFolder,
+		Settings:   s,
+		Config:     cfg,
+		Capturer:   capturer,
+		Input:      SendFunc(keyboard.Send),
+		Watch:      keyboard.Watch,
+		DisplayDPI: dpi,
+	}, nil
+}
+
+// screenCapturer は s.Region をキャプチャする Capturer を返します。
+func screenCapturer(s Settings) (Capturer, error) {
+	if !s.RegionRelative {
+		return CaptureFunc(func() (image.Image, error) {
+			return capture.Capture(capture.Region{
+				X:      s.Region.X,
+				Y:      s.Region.Y,
+				Width:  s.Region.Width,
+				Height: s.Region.Height,
+			})
+		}), nil
+	}
+	w, ok := focus.FindByTitle(s.FocusWindowTitle)
+	if !ok {
+		return nil, fmt.Errorf("ウィンドウが見つかりません: %s", s.FocusWindowTitle)
+	}
+	return &WindowRegion{
+		Offset: s.Region,
+		Locate: func() (image.Rectangle, focus.WindowState) { return focus.ClientRect(w) },
+		Grab: func(r image.Rectangle) (image.Image, error) {
+			return capture.Capture(capture.Region{X: r.Min.X, Y: r.Min.Y, Width: r.Dx(), Height: r.Dy()})
+		},
+	}, nil
+}
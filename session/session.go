@@ -0,0 +1,471 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"AutoScreenShot/capture"
+	"AutoScreenShot/chapter"
+	"AutoScreenShot/compare"
+	"AutoScreenShot/stitch"
+	"AutoScreenShot/stop"
+)
+
+// Capturer は1フレームをキャプチャします。
+type Capturer interface {
+	Capture() (image.Image, error)
+}
+
+// InputSender はページ送りのキー操作（例: "Enter", "Ctrl+C"）を対象アプリに送ります。
+type InputSender interface {
+	Send(keyOperation string) error
+}
+
+// Sink はページの保存先です。
+type Sink interface {
+	// Save は index 番目（1 から）のページを保存し、保存先のパスとバイト数を返します。
+	Save(index int, img image.Image) (path string, size int64, err error)
+	// Remove は保存済みのページを削除します。
+	Remove(path string) error
+}
+
+// CaptureFunc は関数を Capturer として使うためのアダプタです。
+type CaptureFunc func() (image.Image, error)
+
+func (f CaptureFunc) Capture() (image.Image, error) { return f() }
+
+// SendFunc は関数を InputSender として使うためのアダプタです。
+type SendFunc func(keyOperation string) error
+
+func (f SendFunc) Send(keyOperation string) error { return f(keyOperation) }
+
+// Config は Runner の動作設定です。Settings.Config で組み立てます。
+type Config struct {
+	KeyOperation       string
+	AltKeyOperation    string // ページがめくれなかったときに再送するキー（空なら KeyOperation）
+	TurnRetries        int    // ページがめくれなかったときにキーを再送する最大回数
+	Delay              time.Duration
+	Stable             bool // Delay の固定待機ではなく、画面が落ち着くまで待つ
+	StableOptions      capture.StableOptions
+	Comparer           compare.Comparer
+	Stop               stop.Condition // nil ならキャプチャに失敗するまで続ける
+	RetryCaptureErrors bool           // false なら最初のキャプチャ失敗で中止する
+	Skip               *stop.Expr     // 真になったフレームは保存しない
+	Chapter            *stop.Expr     // 真になったページ（count は保存するページの番号）を章の始まりにする
+	ChapterTitle       string         // 章の名前の書式（chapter.Title）
+	BlankAction        string         // compare.BlankKeep / BlankSkip / BlankMark / BlankRecapture
+	Blank              compare.BlankOptions
+	BlankRetries       int  // BlankRecapture で撮り直す最大回数
+	Stitch             bool // 保存したフレームを縦につなげる
+}
+
+// TurnStats はページめくりの確認で行ったキーの再送の集計です。
+type TurnStats struct {
+	Retries   int // 再送したキーの合計回数
+	Recovered int // 再送でページが変わった回数
+	Failed    int // 再送してもページが変わらず、同一フレームとして扱った回数
+}
+
+// Result は1回の実行結果です。
+type Result struct {
+	Count        int            // 保存した枚数（削除前）
+	Removed      int            // 末尾の同一フレームとして削除した枚数
+	Skipped      int            // スキップ条件により保存しなかった枚数
+	BlankPages   []string       `json:",omitempty"` // 空白ページとして記録して保存したファイル
+	BlankSkipped int            // 空白ページとして保存しなかった枚数
+	Chapters     []chapter.Mark `json:",omitempty"` // 実行中に付けた章の区切り
+	Turn         TurnStats
+	StopReason   string    // 終了した条件
+	Started      time.Time // 開始した日時
+	ElapsedSec   float64   // 実行時間（秒）
+	DisplayDPI   float64   `json:",omitempty"` // キャプチャした画面の DPI（呼び出し側が capture.DisplayDPI で記録する。0 なら不明）
+
+	Saved    []string         `json:"-"` // 保存して残っているページのパス（保存順）
+	Met      []stop.Condition `json:"-"` // 成立した終了条件
+	Stitcher *stitch.Stitcher `json:"-"` // Config.Stitch のとき、つなげた結果
+}
+
+// Runner はキャプチャ → 保存 → 終了判定 → キー送信 を繰り返す1回分の実行です。
+// 画面・キーボード・保存先はインターフェースで受け取るため、偽物を渡せば画面の無い環境でも動きます。
+type Runner struct {
+	Config
+	Capturer Capturer
+	Input    InputSender
+	Sink     Sink
+	// Workers が 1 以上なら、保存（エンコードと書き込み）をその数のゴルーチンで行い、
+	// 保存の終わりを待たずに次のページへ進みます。0 なら保存が終わってから進みます。
+	// Sink は複数のゴルーチンから同時に呼ばれても安全である必要があります。
+	Workers int
+	// Marks から受け取るたびに（ホットキーが押されるたびに）、次に保存するページを章の始まりにします。nil なら使いません。
+	Marks <-chan struct{}
+	// OnEvent が nil でなければ、処理の各段階で呼ばれます。
+	OnEvent func(Event)
+
+	saver *saver
+	paths map[int]string // 保存したページの番号 → パス
+}
+
+// WatchMarks は ctx が終わるまで watch（keyboard.Watch）でキー key を監視し、押されるたびに値を送るチャネルを返します。
+// Runner.Marks に渡して、ホットキーで章の区切りを付けるのに使います。key が空なら nil を返します。
+// 監視を始められなかった場合は onErr を呼びます。
+func WatchMarks(ctx context.Context, key string, watch func(context.Context, string, func()) error, onErr func(error)) <-chan struct{} {
+	if key == "" {
+		return nil
+	}
+	marks := make(chan struct{}, 1)
+	go func() {
+		err := watch(ctx, key, func() {
+			select {
+			case marks <- struct{}{}:
+			default: // 前の押下をまだ受け取っていなければ、まとめて1回にする
+			}
+		})
+		if err != nil {
+			onErr(err)
+		}
+	}()
+	return marks
+}
+
+// Run は終了条件が成立するか、キャプチャに失敗するか、ctx がキャンセルされるまで実行します。
+// 保存に失敗した場合やキャンセルされた場合は、それまでの結果とエラーを返します。
+// Workers で保存を並行する場合も、返る前にすべての保存が終わり、結果は順に保存した場合と同じになります。
+func (r *Runner) Run(ctx context.Context) (res Result, err error) {
+	st := stop.State{Distance: -1}
+	var prevHash []byte
+	var streak []int // 現在の同一フレームの連続のうち保存したページの番号（空白ページを除く）
+	start := time.Now()
+	res.Started = start
+	r.paths = map[int]string{}
+	if r.Stitch {
+		res.Stitcher = &stitch.Stitcher{}
+	}
+	defer func() {
+		res.ElapsedSec = time.Since(start).Seconds()
+		res.StopReason = stop.Reason(res.Met)
+		if res.StopReason == "" {
+			switch {
+			case ctx.Err() != nil:
+				res.StopReason = "キャンセル"
+			case errors.Is(err, ErrWindowClosed):
+				res.StopReason = ErrWindowClosed.Error()
+			default:
+				res.StopReason = "エラーにより中断"
+			}
+		}
+	}()
+	// 書き込み量を見る条件があれば、判定の前に保存が終わるのを待つ（順に保存した場合と同じ判定にする）
+	waitBytes := stop.UsesBytes(r.Stop) || (r.Skip != nil && r.Skip.Uses("bytes"))
+	if r.Workers > 0 {
+		r.saver = newSaver(r.Sink, r.Workers)
+		defer func() {
+			if serr := r.saved(&res, &st, r.saver.close()); err == nil {
+				err = serr
+			}
+			r.saver = nil
+		}()
+	}
+
+	var pending *capture.StableFrame // キー送信後に得たフレーム（次のループで保存する）
+	pendingNote := ""
+	for {
+		if err = ctx.Err(); err != nil {
+			return res, err
+		}
+		var img image.Image
+		var hash []byte
+		note := "最初のページ"
+		if pending != nil {
+			img, hash, note = pending.Image, pending.Hash, pendingNote
+			pending = nil
+		} else {
+			img, err = r.capture(ctx)
+		}
+		st.Elapsed = time.Since(start)
+		if err != nil && (errors.Is(err, ErrWindowClosed) || ctx.Err() != nil) {
+			r.emit(Event{Kind: EventError, Note: "キャプチャを中止しました", Err: err})
+			return res, err
+		}
+		if err != nil {
+			r.emit(Event{Kind: EventError, Note: "キャプチャに失敗しました", Err: err})
+			st.ErrorStreak++
+			if res.Met = stop.Which(r.Stop, st); res.Met != nil || !r.RetryCaptureErrors {
+				r.stopped(res.Met)
+				return res, nil
+			}
+			if err := capture.Sleep(ctx, r.Delay); err != nil {
+				return res, err
+			}
+			continue
+		}
+		st.ErrorStreak = 0
+
+		// 空白ページ（読み込み中の白い画面や区切りページ）の扱い
+		blank := r.BlankAction != compare.BlankKeep && compare.IsBlank(img, r.Blank)
+		for i := 0; blank && r.BlankAction == compare.BlankRecapture && i < r.BlankRetries; i++ {
+			if err := capture.Sleep(ctx, r.Delay); err != nil {
+				return res, err
+			}
+			again, err := r.capture(ctx)
+			if err != nil {
+				break
+			}
+			img, hash = again, nil
+			blank = compare.IsBlank(img, r.Blank)
+			note += "、空白のため撮り直し"
+		}
+
+		if hash == nil {
+			if hash, err = r.Comparer.Sum(img); err != nil {
+				return res, err
+			}
+		}
+		st.Distance = r.Comparer.Distance(prevHash, hash)
+		st.Hash = hash
+		// 空白ページは同一フレームの連続に数えない（区切りページで早く終了しないように）
+		if !blank {
+			if r.Comparer.Same(prevHash, hash) {
+				st.SameStreak++
+			} else {
+				st.SameStreak = 1
+				streak = nil
+			}
+			prevHash = hash
+		}
+
+		// 並行して保存している分の結果を反映する（保存の失敗はここで中断する）
+		if err := r.collect(&res, &st, waitBytes); err != nil {
+			return res, err
+		}
+		if blank && r.BlankAction == compare.BlankSkip {
+			res.BlankSkipped++
+			r.emit(Event{Kind: EventBlankSkipped, Note: note})
+		} else if r.Skip != nil && r.Skip.Met(st) {
+			res.Skipped++
+			r.emit(Event{Kind: EventSkipped, Note: note})
+		} else {
+			st.Count++
+			r.markChapter(&res, &st)
+			job := saveJob{index: st.Count, img: img, blank: blank, note: note}
+			if err := r.save(&res, &st, job); err != nil {
+				return res, err
+			}
+			if !blank {
+				streak = append(streak, st.Count)
+				if res.Stitcher != nil {
+					res.Stitcher.Add(img)
+				}
+			}
+			if err := r.collect(&res, &st, waitBytes); err != nil {
+				return res, err
+			}
+		}
+
+		if res.Met = stop.Which(r.Stop, st); res.Met != nil {
+			r.stopped(res.Met)
+			// 末尾の同一フレームを消す前に、保存をすべて終わらせる
+			if err := r.collect(&res, &st, true); err != nil {
+				return res, err
+			}
+			r.removeTrailing(&res, streak)
+			return res, nil
+		}
+
+		f, err := r.advance(ctx, r.KeyOperation)
+		// ページがめくれていなければ（直前と同一なら）キーを送り直す
+		retries := 0
+		for err == nil && retries < r.TurnRetries && r.Comparer.Same(prevHash, f.Hash) {
+			key := r.AltKeyOperation
+			if key == "" {
+				key = r.KeyOperation
+			}
+			retries++
+			r.emit(Event{Kind: EventTurnRetry, Key: key, Note: fmt.Sprintf("%d 回目", retries)})
+			f, err = r.advance(ctx, key)
+		}
+		if ctx.Err() != nil {
+			return res, ctx.Err()
+		}
+		if err != nil {
+			// 次のループでキャプチャし直し、失敗の扱いは終了条件に任せる
+			r.emit(Event{Kind: EventError, Note: "キャプチャに失敗しました", Err: err})
+			continue
+		}
+		pending = f
+		if !r.Stable {
+			pendingNote = fmt.Sprintf("固定待機 %dms", f.Settle.Milliseconds())
+		} else if f.Stable {
+			pendingNote = fmt.Sprintf("安定まで %dms", f.Settle.Milliseconds())
+		} else {
+			pendingNote = fmt.Sprintf("%dms で安定せず打ち切り", f.Settle.Milliseconds())
+		}
+		if retries > 0 {
+			res.Turn.Retries += retries
+			if r.Comparer.Same(prevHash, f.Hash) {
+				res.Turn.Failed++
+				pendingNote += fmt.Sprintf("、%d 回再送してもページが変わらず", retries)
+			} else {
+				res.Turn.Recovered++
+				pendingNote += fmt.Sprintf("、%d 回目の再送でページが変化", retries)
+			}
+		}
+	}
+}
+
+// markChapter は保存するページ（st.Count 番目）が章の始まりなら、区切りを記録します。
+// ホットキーが押されていたか、章の区切りの条件が成立すれば章の始まりです。
+func (r *Runner) markChapter(res *Result, st *stop.State) {
+	pressed := false
+	for drained := false; !drained; {
+		select {
+		case <-r.Marks:
+			pressed = true
+		default:
+			drained = true
+		}
+	}
+	if !pressed && (r.Chapter == nil || !r.Chapter.Met(*st)) {
+		return
+	}
+	m := chapter.Mark{Page: st.Count, Title: chapter.Title(r.ChapterTitle, len(res.Chapters)+1)}
+	res.Chapters = append(res.Chapters, m)
+	r.emit(Event{Kind: EventChapter, Page: m.Page, Note: m.Title})
+}
+
+// advance はキーを送信し、待機後のフレームを返します。
+func (r *Runner) advance(ctx context.Context, key string) (*capture.StableFrame, error) {
+	if err := r.Input.Send(key); err != nil {
+		r.emit(Event{Kind: EventError, Note: "キー送信に失敗しました", Err: err})
+	} else {
+		r.emit(Event{Kind: EventKeySent, Key: key})
+	}
+	if r.Stable {
+		// 固定時間ではなく、画面が落ち着く（連続で一致する）まで待つ
+		grab := func() (image.Image, error) { return r.capture(ctx) }
+		f, err := capture.WaitStable(ctx, grab, r.Comparer.Sum, r.Comparer.Same, r.StableOptions)
+		return &f, err
+	}
+	if err := capture.Sleep(ctx, r.Delay); err != nil {
+		return nil, err
+	}
+	img, err := r.capture(ctx)
+	if err != nil {
+		return nil, err
+	}
+	h, err := r.Comparer.Sum(img)
+	if err != nil {
+		return nil, err
+	}
+	return &capture.StableFrame{Image: img, Hash: h, Settle: r.Delay, Stable: true}, nil
+}
+
+// save はページを保存します。Workers が 0 なら保存が終わるまで待ち、そうでなければ保存を依頼して、
+// 既に終わった保存の結果だけを反映します。
+func (r *Runner) save(res *Result, st *stop.State, job saveJob) error {
+	if r.saver != nil {
+		return r.saved(res, st, r.saver.submit(job))
+	}
+	done := saveResult{saveJob: job}
+	done.path, done.size, done.err = r.Sink.Save(job.index, job.img)
+	return r.saved(res, st, []saveResult{done})
+}
+
+// collect は並行して行っている保存の結果を反映します。all が true なら、すべての保存が終わるまで待ちます。
+func (r *Runner) collect(res *Result, st *stop.State, all bool) error {
+	if r.saver == nil {
+		return nil
+	}
+	if all {
+		return r.saved(res, st, r.saver.wait())
+	}
+	return r.saved(res, st, r.saver.receive())
+}
+
+// saved は保存の結果（ページ番号順）を集計に反映し、EventSaved を通知します。失敗した保存があれば最初のエラーを返します。
+func (r *Runner) saved(res *Result, st *stop.State, done []saveResult) error {
+	var first error
+	for _, d := range done {
+		if d.err != nil {
+			r.emit(Event{Kind: EventError, Page: d.index, Note: "保存に失敗しました", Err: d.err})
+			if first == nil {
+				first = d.err
+			}
+			continue
+		}
+		st.BytesWritten += d.size
+		res.Count++
+		res.Saved = append(res.Saved, d.path)
+		r.paths[d.index] = d.path
+		note := d.note
+		if d.blank {
+			res.BlankPages = append(res.BlankPages, filepath.Base(d.path))
+			note += "、空白ページ"
+		}
+		r.emit(Event{Kind: EventSaved, Page: d.index, Path: d.path, Note: note})
+	}
+	return first
+}
+
+// pauseInterval は一時停止中に対象ウィンドウを調べ直す間隔です。
+const pauseInterval = 500 * time.Millisecond
+
+// capture は1フレームをキャプチャします。Capturer が ErrPaused を返す間は、失敗として数えずに待ち続けます。
+func (r *Runner) capture(ctx context.Context) (image.Image, error) {
+	img, err := r.Capturer.Capture()
+	if !errors.Is(err, ErrPaused) {
+		return img, err
+	}
+	r.emit(Event{Kind: EventPaused, Err: err})
+	paused := time.Now()
+	for errors.Is(err, ErrPaused) {
+		if err := capture.Sleep(ctx, pauseInterval); err != nil {
+			return nil, err
+		}
+		img, err = r.Capturer.Capture()
+	}
+	if err == nil {
+		r.emit(Event{Kind: EventResumed, Note: fmt.Sprintf("%.1f 秒停止", time.Since(paused).Seconds())})
+	}
+	return img, err
+}
+
+// removeTrailing は連続同一で終了した場合に、同一のフレームのうち最初の1枚だけ残して削除します。
+// streak は連続のうち保存したページの番号で、途中に保存した空白ページは残します。
+func (r *Runner) removeTrailing(res *Result, streak []int) {
+	for _, c := range res.Met {
+		if _, ok := c.(stop.SameFrames); !ok {
+			continue
+		}
+		for i := len(streak) - 1; i >= 1; i-- {
+			p, ok := r.paths[streak[i]]
+			if !ok {
+				continue // 保存に失敗したページ
+			}
+			if err := r.Sink.Remove(p); err != nil {
+				r.emit(Event{Kind: EventError, Path: p, Note: "重複画像の削除に失敗しました " + p, Err: err})
+			} else {
+				r.emit(Event{Kind: EventRemoved, Path: p, Note: "末尾の同一フレーム"})
+			}
+			res.Saved = slices.DeleteFunc(res.Saved, func(s string) bool { return s == p })
+			res.Removed++
+		}
+		return
+	}
+}
+
+func (r *Runner) stopped(met []stop.Condition) {
+	if met != nil {
+		r.emit(Event{Kind: EventStopped, Note: stop.Reason(met)})
+	}
+}
+
+func (r *Runner) emit(e Event) {
+	if r.OnEvent != nil {
+		r.OnEvent(e)
+	}
+}
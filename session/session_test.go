@@ -0,0 +1,178 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"maps"
+	"reflect"
+	"sync"
+	"testing"
+
+	"AutoScreenShot/compare"
+	"AutoScreenShot/stop"
+)
+
+// memSink は保存したページを覚えておくだけの Sink です。
+type memSink struct {
+	mu      sync.Mutex
+	saved   []string
+	removed []string
+}
+
+func (s *memSink) Save(index int, img image.Image) (string, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	path := fmt.Sprintf("page%d", index)
+	s.saved = append(s.saved, path)
+	return path, 100, nil
+}
+
+func (s *memSink) Remove(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.removed = append(s.removed, path)
+	return nil
+}
+
+func blankPage() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 64, 48))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	return img
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name    string
+		pages   []image.Image
+		stuck   map[int]int
+		config  func(*Config)
+		reason  string
+		saved   []string
+		removed []string
+		check   func(t *testing.T, res Result, screen *fakeScreen)
+	}{
+		{
+			name:    "連続同一で終了して末尾を消す",
+			pages:   testPages(3),
+			reason:  "3 枚連続同一",
+			saved:   []string{"page1", "page2", "page3"},
+			removed: []string{"page5", "page4"},
+		},
+		{
+			name:   "最大枚数",
+			pages:  testPages(5),
+			config: func(c *Config) { c.Stop = stop.Any{stop.MaxCount(2), stop.SameFrames(3)} },
+			reason: "最大枚数 2 枚",
+			saved:  []string{"page1", "page2"},
+		},
+		{
+			name:    "めくれなかったページでキーを再送",
+			pages:   testPages(3),
+			stuck:   map[int]int{1: 1},
+			config:  func(c *Config) { c.TurnRetries, c.AltKeyOperation = 2, "Right" },
+			reason:  "3 枚連続同一",
+			saved:   []string{"page1", "page2", "page3"},
+			removed: []string{"page5", "page4"},
+			check: func(t *testing.T, res Result, screen *fakeScreen) {
+				if want := (TurnStats{Retries: 5, Recovered: 1, Failed: 2}); res.Turn != want {
+					t.Errorf("Turn = %+v, want %+v", res.Turn, want)
+				}
+				if screen.sent[2] != "Right" {
+					t.Errorf("sent = %q, want Right as the 3rd key", screen.sent)
+				}
+			},
+		},
+		{
+			name:    "空白ページを保存しない",
+			pages:   []image.Image{testPage(0), blankPage(), testPage(2)},
+			config:  func(c *Config) { c.BlankAction = compare.BlankSkip },
+			reason:  "3 枚連続同一",
+			saved:   []string{"page1", "page2"},
+			removed: []string{"page4", "page3"},
+			check: func(t *testing.T, res Result, screen *fakeScreen) {
+				if res.BlankSkipped != 1 {
+					t.Errorf("BlankSkipped = %d, want 1", res.BlankSkipped)
+				}
+			},
+		},
+		{
+			name:    "同一フレームの連続の途中の空白ページは残す",
+			pages:   []image.Image{testPage(0), testPage(1), testPage(1), blankPage(), testPage(1)},
+			config:  func(c *Config) { c.BlankAction = compare.BlankMark },
+			reason:  "3 枚連続同一",
+			saved:   []string{"page1", "page2", "page4"},
+			removed: []string{"page5", "page3"},
+			check: func(t *testing.T, res Result, screen *fakeScreen) {
+				if !reflect.DeepEqual(res.BlankPages, []string{"page4"}) {
+					t.Errorf("BlankPages = %q, want [page4]", res.BlankPages)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		for _, workers := range []int{0, 2} {
+			t.Run(fmt.Sprintf("%s/Workers=%d", tt.name, workers), func(t *testing.T) {
+				screen := &fakeScreen{pages: tt.pages, stuck: maps.Clone(tt.stuck)}
+				sink := &memSink{}
+				r := newTestRunner(screen, sink, workers)
+				if tt.config != nil {
+					tt.config(&r.Config)
+				}
+				res, err := r.Run(context.Background())
+				if err != nil {
+					t.Fatal(err)
+				}
+				if res.StopReason != tt.reason {
+					t.Errorf("StopReason = %q, want %q", res.StopReason, tt.reason)
+				}
+				if !reflect.DeepEqual(res.Saved, tt.saved) {
+					t.Errorf("Saved = %q, want %q", res.Saved, tt.saved)
+				}
+				if !reflect.DeepEqual(sink.removed, tt.removed) {
+					t.Errorf("removed = %q, want %q", sink.removed, tt.removed)
+				}
+				if res.Removed != len(tt.removed) {
+					t.Errorf("Removed = %d, want %d", res.Removed, len(tt.removed))
+				}
+				if tt.check != nil {
+					tt.check(t, res, screen)
+				}
+			})
+		}
+	}
+}
+
+func TestRunCancel(t *testing.T) {
+	for _, workers := range []int{0, 2} {
+		t.Run(fmt.Sprintf("Workers=%d", workers), func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			screen := &fakeScreen{pages: testPages(10), onCapture: func(n int) {
+				if n == 4 {
+					cancel()
+				}
+			}}
+			sink := &memSink{}
+			r := newTestRunner(screen, sink, workers)
+			r.Stable = true // 画面が落ち着くまで待つ間のキャンセルも止まること
+			res, err := r.Run(ctx)
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("err = %v, want context.Canceled", err)
+			}
+			if res.StopReason != "キャンセル" {
+				t.Errorf("StopReason = %q, want キャンセル", res.StopReason)
+			}
+			if screen.captures > 5 {
+				t.Errorf("captured %d times after cancel", screen.captures)
+			}
+			// 受け付けた保存は終わってから返る
+			if len(res.Saved) != len(sink.saved) {
+				t.Errorf("Saved = %q, sink saved %q", res.Saved, sink.saved)
+			}
+		})
+	}
+}
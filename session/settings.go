@@ -0,0 +1,267 @@
+package session
+
+import (
+	"fmt"
+	"image"
+	"strconv"
+	"strings"
+	"time"
+
+	"AutoScreenShot/capture"
+	"AutoScreenShot/compare"
+	"AutoScreenShot/layout"
+	"AutoScreenShot/output"
+	"AutoScreenShot/stop"
+	"AutoScreenShot/trim"
+)
+
+// Region はキャプチャ範囲（左上座標と幅・高さ）を表します。
+type Region struct {
+	X, Y, Width, Height int
+}
+
+// Rect は Region を image.Rectangle に変換します。
+func (r Region) Rect() image.Rectangle {
+	return image.Rect(r.X, r.Y, r.X+r.Width, r.Y+r.Height)
+}
+
+// Settings は設定ダイアログで指定し、session.json に保存する設定です。
+type Settings struct {
+	Region           Region
+	RegionRelative   bool // Region を FocusWindowTitle のウィンドウのクライアント領域からの相対座標として扱う
+	OutputFolder     string
+	KeyOperation     string
+	AltKeyOperation  string      // ページがめくれなかったときに再送するキー（空なら KeyOperation）
+	TurnRetries      int         // ページがめくれなかったときにキーを再送する最大回数（0 なら確認しない）
+	FocusWindowTitle string      // 開始前にフォーカスするウィンドウのタイトル（空なら行わない）
+	Stop             stop.Config // 終了条件
+	SkipExpr         string      // 保存をスキップする条件の式（空なら使わない）
+	ChapterKey       string      // 実行中に押すと、次に保存するページを章の始まりにするキー（例: "F9"。空なら使わない）
+	ChapterExpr      string      // 真になったページを章の始まりにする式（例: "count % 20 == 1"。count は保存するページの番号）
+	ChapterTitle     string      // 実行中に付ける章の名前の書式（{n} は章の番号。空なら "第{n}章"）
+	CompareMode      string      // 同一判定のモード（compare.Mode。空または "exact" で完全一致）
+	CompareTolerance int         // 知覚ハッシュで同一とみなす最大ハミング距離
+	IgnoreMasks      []Region    // 比較から除外する範囲（Region の左上を原点とする相対座標）
+	DelayMsAfterKey  int
+	StableFrames     int     // 連続で一致したら安定とみなすフレーム数（0 なら DelayMsAfterKey だけ固定で待つ）
+	StableMinMs      int     // 安定待ちの最小待機時間(ms)
+	StableMaxMs      int     // 安定待ちの最大待機時間(ms)
+	StablePollMs     int     // 安定待ちのキャプチャ間隔(ms)
+	BlankAction      string  // 空白ページの扱い（compare.BlankKeep / BlankSkip / BlankMark / BlankRecapture）
+	PDFSkipBlank     bool    // 空白ページを PDF に含めない
+	DedupAction      string  // セッション全体の重複ページの扱い（dedup.ActionOff / ActionRemove / ActionFlag）
+	StitchMode       bool    // スクロールキャプチャを縦につなげて1枚にする
+	StitchPageAspect float64 // つなげた画像を分割するページの比率（高さ/幅。0 なら分割しない）
+	SplitMode        string  // 1回のキャプチャを複数ページに分ける方法（layout.SplitNone / SplitCenter / SplitGutter / SplitGrid）
+	SplitCols        int     // SplitGrid の列数
+	SplitRows        int     // SplitGrid の行数
+	ReadingOrder     string  // 読み順（layout.OrderLTR / OrderRTL）。分けたページの番号、PDF・CBZ・EPUB の読む向きに使う
+	TrimMode         string  // 終了後に周囲の余白を切り取る方法（trim.ModeOff / ModePage / ModeUniform）
+	TrimTolerance    int     // 余白（背景色）とみなす色の差（0〜255。0 なら trim.DefaultTolerance）
+	ImageFormat      string  // ページの保存形式（output.FormatJPEG / FormatPNG / FormatPNG8 / FormatTIFF）
+	ExportTIFF       bool    // PDF と同じページをマルチページ TIFF にも書き出す
+	TIFFCompression  string  // マルチページ TIFF の圧縮方式（output.TIFFNone / TIFFLZW / TIFFDeflate / TIFFG4。空なら LZW）
+	TIFFDPI          int     // マルチページ TIFF に記録する解像度（0 なら 96）
+	ExportCBZ        bool    // PDF と同じページをコミックアーカイブ（CBZ）にも書き出す
+	ExportEPUB       bool    // PDF と同じページを固定レイアウトの EPUB 3 にも書き出す
+	PDFPageLayout    string  // PDF のページの並べ方（output.PDFLayoutDefault / PDFLayoutSingle / PDFLayoutSpread）
+	PDFCoverAlone    bool    // PDF の見開きで1ページ目（表紙）を単独で表示する
+	PDFTitle         string  // PDFのタイトル（デフォルトは screenshot-YYYY-MM-DD_HH-MM-SS）
+	// PDF の文書のプロパティ。タイトルとともに {date}（開始日）、{count}（ページ数）、{window}（フォーカスするアプリ）を置き換える
+	PDFAuthor   string            // 作成者
+	PDFSubject  string            // サブタイトル（件名）
+	PDFKeywords string            // キーワード（カンマ区切り）
+	PDFCreator  string            // 作成したアプリケーション
+	PDFInfo     map[string]string `json:",omitempty"` // XMP メタデータに加える独自の項目（名前 → 値）
+	PDFXMP      bool              // 同じプロパティを XMP メタデータとしても埋め込む
+	// PDF のページの大きさ
+	PDFPageSize  string  // 決め方（output.PDFSizeImage / PDFSizePaper / PDFSizeNUp）
+	PDFPaper     string  // 用紙（output.PaperA4 / PaperLetter / PaperB5）
+	PDFMarginMm  float64 // 用紙の余白（mm）
+	PDFDPI       int     // 画像の大きさのページにするときの解像度（0 なら 96）
+	PDFScreenDPI bool    // true なら PDFDPI の代わりにキャプチャした画面の DPI を使う（分からなければ PDFDPI）
+	PDFNUp       int     // 用紙1枚に並べるページ数（2 か 4）
+}
+
+// DefaultSettings は設定ダイアログの初期値です。
+func DefaultSettings() Settings {
+	return Settings{
+		KeyOperation: "Enter",
+		Stop: stop.Config{
+			MaxCount:    500,
+			SameFrames:  3,
+			ErrorStreak: 1,
+			Combine:     stop.CombineAny,
+		},
+		CompareMode:     string(compare.ModeExact),
+		DelayMsAfterKey: 500,
+		StableMinMs:     100,
+		StableMaxMs:     5000,
+		StablePollMs:    50,
+		PDFTitle:        "screenshot-" + time.Now().Format("2006-01-02_15-04-05"),
+		PDFCreator:      "AutoScreenShot",
+		PDFPaper:        output.PaperA4,
+		PDFMarginMm:     output.DefaultPDFMarginMm,
+		PDFNUp:          2,
+	}
+}
+
+// Layout は設定から保存時のページの分け方を返します。
+func (s Settings) Layout() layout.Options {
+	return layout.Options{Mode: s.SplitMode, Cols: s.SplitCols, Rows: s.SplitRows, Order: s.ReadingOrder}
+}
+
+// Sink は設定の保存形式とページの分け方で dir にページを保存する DirSink を返します。
+// 余白を切り取る設定なら、Finish で切り取ってから分けて保存形式にするため、分けずに可逆の PNG で保存します
+// （スクロール連結モードでは、連結画像のページを切り取ります）。
+func (s Settings) Sink(dir string) *DirSink {
+	return &DirSink{Dir: dir, Format: s.ImageFormat, Layout: s.Layout(), Lossless: s.TrimMode != trim.ModeOff && !s.StitchMode}
+}
+
+// Trim は設定から余白の切り取り方を返します。
+func (s Settings) Trim() trim.Options {
+	return trim.Options{Mode: s.TrimMode, Tolerance: s.TrimTolerance, Padding: trimPadding, Layout: s.Layout()}
+}
+
+// Book は設定から CBZ と EPUB の設定を返します。読む向きはページ分割の読み順と同じです。
+func (s Settings) Book(blank compare.BlankOptions) output.BookOptions {
+	return output.BookOptions{
+		Title:       s.PDFTitle,
+		RightToLeft: s.ReadingOrder == layout.OrderRTL,
+		SkipBlank:   s.PDFSkipBlank,
+		Blank:       blank,
+	}
+}
+
+// Placeholders は PDF のタイトルとプロパティの {date}、{count}、{window} に入れる値です。
+type Placeholders struct {
+	Date   time.Time // キャプチャを始めた日時
+	Count  int       // PDF のページ数
+	Window string    // フォーカスするアプリのウィンドウタイトル
+}
+
+// Expand は s の {date}（YYYY-MM-DD）、{count}、{window} を置き換えます。
+func (p Placeholders) Expand(s string) string {
+	return p.expand(s, strconv.Itoa(p.Count))
+}
+
+// expand は Expand と同じですが、{count} を count に置き換えます。
+func (p Placeholders) expand(s, count string) string {
+	return strings.NewReplacer(
+		"{date}", p.Date.Format("2006-01-02"),
+		"{count}", count,
+		"{window}", p.Window,
+	).Replace(s)
+}
+
+// PDFMetadata は設定から PDF の文書のプロパティを、p で {date} などを置き換えて返します。
+func (s Settings) PDFMetadata(p Placeholders) output.PDFMetadata {
+	// {window} が空のときなどに残る前後の空白は除く
+	expand := func(v string) string { return strings.TrimSpace(p.Expand(v)) }
+	m := output.PDFMetadata{
+		Title:    expand(s.PDFTitle),
+		Author:   expand(s.PDFAuthor),
+		Subject:  expand(s.PDFSubject),
+		Keywords: expand(s.PDFKeywords),
+		Creator:  expand(s.PDFCreator),
+		Created:  p.Date,
+		XMP:      s.PDFXMP,
+	}
+	if len(s.PDFInfo) > 0 {
+		m.Custom = make(map[string]string, len(s.PDFInfo))
+		for k, v := range s.PDFInfo {
+			m.Custom[k] = expand(v)
+		}
+	}
+	return m
+}
+
+// trimPadding は余白を切り取るときに内容の外側に残す幅（ピクセル）です。
+const trimPadding = 4
+
+// jpegQuality は保存に使う JPEG 品質です。
+const jpegQuality = 85
+
+// ImageWriter は設定の保存形式でページを書き出す ImageWriter を返します。
+func (s Settings) ImageWriter() (output.ImageWriter, error) {
+	return output.NewWriter(s.ImageFormat, jpegQuality)
+}
+
+// Comparer は設定から同一判定に使う Comparer を組み立てます。
+func (s Settings) Comparer() (compare.Comparer, error) {
+	mode, err := compare.ParseMode(s.CompareMode)
+	if err != nil {
+		return compare.Comparer{}, err
+	}
+	cmp := compare.Comparer{Mode: mode, Tolerance: s.CompareTolerance}
+	for _, m := range s.IgnoreMasks {
+		cmp.Masks = append(cmp.Masks, m.Rect())
+	}
+	return cmp, nil
+}
+
+// Config は設定から Runner の設定を組み立てます。式や終了画面の誤りはここでエラーになるため、
+// 実行を始める前に呼んでください。
+func (s Settings) Config() (Config, error) {
+	cmp, err := s.Comparer()
+	if err != nil {
+		return Config{}, err
+	}
+	cond, err := s.Stop.Build(cmp)
+	if err != nil {
+		return Config{}, fmt.Errorf("終了条件が不正です: %w", err)
+	}
+	if _, err := s.ImageWriter(); err != nil {
+		return Config{}, err
+	}
+	if err := output.CheckTIFFCompression(s.TIFFCompression); err != nil {
+		return Config{}, err
+	}
+	if err := output.CheckPDFLayout(s.PDFPageLayout); err != nil {
+		return Config{}, err
+	}
+	if err := output.CheckPDFInfo(s.PDFInfo); err != nil {
+		return Config{}, err
+	}
+	if err := output.CheckPDFPageSize(s.PDFPageSize, s.PDFPaper, s.PDFNUp); err != nil {
+		return Config{}, err
+	}
+	var skip *stop.Expr
+	if s.SkipExpr != "" {
+		if skip, err = stop.ParseExpr(s.SkipExpr); err != nil {
+			return Config{}, fmt.Errorf("スキップ条件が不正です: %w", err)
+		}
+	}
+	var chapterExpr *stop.Expr
+	if s.ChapterExpr != "" {
+		if chapterExpr, err = stop.ParseExpr(s.ChapterExpr); err != nil {
+			return Config{}, fmt.Errorf("章の区切りの条件が不正です: %w", err)
+		}
+	}
+	delay := time.Duration(s.DelayMsAfterKey) * time.Millisecond
+	if delay <= 0 {
+		delay = 500 * time.Millisecond
+	}
+	return Config{
+		KeyOperation:    s.KeyOperation,
+		AltKeyOperation: s.AltKeyOperation,
+		TurnRetries:     s.TurnRetries,
+		Delay:           delay,
+		Stable:          s.StableFrames > 0,
+		StableOptions: capture.StableOptions{
+			Frames:   s.StableFrames,
+			MinWait:  time.Duration(s.StableMinMs) * time.Millisecond,
+			MaxWait:  time.Duration(s.StableMaxMs) * time.Millisecond,
+			Interval: time.Duration(s.StablePollMs) * time.Millisecond,
+		},
+		Comparer:           cmp,
+		Stop:               cond,
+		RetryCaptureErrors: s.Stop.ErrorStreak > 0,
+		Skip:               skip,
+		Chapter:            chapterExpr,
+		ChapterTitle:       s.ChapterTitle,
+		BlankAction:        s.BlankAction,
+		BlankRetries:       3,
+		Stitch:             s.StitchMode,
+	}, nil
+}
@@ -0,0 +1,102 @@
+package session
+
+import (
+	"image"
+	"os"
+	"sync"
+
+	"AutoScreenShot/layout"
+	"AutoScreenShot/output"
+)
+
+// DirSink はページをフォルダに screenshot_00001.jpg 形式で保存します。形式は Format（既定は JPEG）です。
+// Layout で1回のキャプチャを複数ページに分ける場合は screenshot_00001_1.jpg, screenshot_00001_2.jpg … と読み順に保存します。
+// 複数のゴルーチンから同時に使えます。
+type DirSink struct {
+	Dir     string
+	Format  string // 保存形式（output.FormatJPEG / FormatPNG / FormatPNG8 / FormatTIFF）
+	Quality int    // JPEG 品質（0 なら 85）
+	Layout  layout.Options
+	// Lossless が true なら、Format と Layout に関係なく分けずに可逆の PNG で保存します。
+	// 終了後に余白を切り取ってから分けて保存形式にする（trim.Apply）ときに、JPEG の劣化を1回にするために使います。
+	Lossless bool
+
+	mu    sync.Mutex
+	pages map[string][]string // Save が返したパス → そのキャプチャから保存したすべてのページ
+}
+
+// Save は img を保存し、そのパス（分けた場合は最初のページ）と書き込んだ合計サイズを返します。
+func (s *DirSink) Save(index int, img image.Image) (string, int64, error) {
+	w, err := s.writer()
+	if err != nil {
+		return "", 0, err
+	}
+	parts := []image.Image{img}
+	if !s.Lossless {
+		parts = layout.Split(img, s.Layout)
+	}
+	var paths []string
+	var size int64
+	for i, p := range parts {
+		sub := i + 1
+		if len(parts) == 1 {
+			sub = 0
+		}
+		path, err := output.SavePage(s.Dir, index, sub, p, w)
+		if err != nil {
+			for _, done := range paths {
+				os.Remove(done)
+			}
+			return "", 0, err
+		}
+		paths = append(paths, path)
+		if info, err := os.Stat(path); err == nil {
+			size += info.Size()
+		}
+	}
+	s.remember(paths)
+	return paths[0], size, nil
+}
+
+// Remove は保存したページを削除します。分けて保存したキャプチャは、すべてのページを削除します。
+func (s *DirSink) Remove(path string) error {
+	s.mu.Lock()
+	paths, ok := s.pages[path]
+	delete(s.pages, path)
+	s.mu.Unlock()
+	if !ok {
+		return os.Remove(path)
+	}
+	var first error
+	for _, p := range paths {
+		if err := os.Remove(p); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// writer はページの保存に使う ImageWriter を返します。
+func (s *DirSink) writer() (output.ImageWriter, error) {
+	if s.Lossless {
+		return output.PNGWriter{Fast: true}, nil
+	}
+	return output.NewWriter(s.Format, s.quality())
+}
+
+func (s *DirSink) quality() int {
+	if s.Quality <= 0 {
+		return jpegQuality
+	}
+	return s.Quality
+}
+
+// remember は1回のキャプチャから保存したページを、最初のページのパスで引けるように記録します。
+func (s *DirSink) remember(paths []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pages == nil {
+		s.pages = map[string][]string{}
+	}
+	s.pages[paths[0]] = paths
+}
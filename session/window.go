@@ -0,0 +1,62 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"image"
+
+	"AutoScreenShot/focus"
+)
+
+var (
+	// ErrPaused は対象ウィンドウが一時的にキャプチャできない（最小化された、範囲がはみ出した）ことを表します。
+	// Runner はこのエラーの間は失敗として数えず、キャプチャできるようになるまで待ちます。
+	ErrPaused = errors.New("対象ウィンドウをキャプチャできないため一時停止")
+	// ErrWindowClosed は対象ウィンドウが閉じられたことを表します。Runner は実行を中止します。
+	ErrWindowClosed = errors.New("対象ウィンドウが閉じられました")
+)
+
+// WindowRegion は対象ウィンドウのクライアント領域からの相対位置でキャプチャする Capturer です。
+// キャプチャのたびにウィンドウの位置を調べ直すため、ウィンドウを動かしても同じ部分を撮れます。
+type WindowRegion struct {
+	Offset Region // クライアント領域の左上を原点とするキャプチャ範囲
+	// Locate は対象ウィンドウのクライアント領域（スクリーン座標）と状態を返します。
+	Locate func() (image.Rectangle, focus.WindowState)
+	// Grab はスクリーン座標の範囲をキャプチャします。
+	Grab func(image.Rectangle) (image.Image, error)
+}
+
+// Capture はウィンドウの現在位置から範囲を求めてキャプチャします。
+// 最小化中や範囲がクライアント領域からはみ出した場合は ErrPaused、閉じられた場合は ErrWindowClosed を返します。
+func (w *WindowRegion) Capture() (image.Image, error) {
+	r, err := w.Rect()
+	if err != nil {
+		return nil, err
+	}
+	return w.Grab(r)
+}
+
+// Rect はウィンドウの現在位置から、キャプチャするスクリーン座標の範囲を返します。
+func (w *WindowRegion) Rect() (image.Rectangle, error) {
+	client, state := w.Locate()
+	switch state {
+	case focus.StateClosed:
+		return image.Rectangle{}, ErrWindowClosed
+	case focus.StateMinimized:
+		return image.Rectangle{}, fmt.Errorf("%w（最小化されています）", ErrPaused)
+	}
+	r := w.Offset.Rect().Add(client.Min)
+	if !r.In(client) {
+		return image.Rectangle{}, fmt.Errorf("%w（キャプチャ範囲がウィンドウからはみ出しています）", ErrPaused)
+	}
+	return r, nil
+}
+
+// RelativeRegion はスクリーン座標の範囲 abs を、クライアント領域 client の左上を原点とする範囲に変換します。
+// abs がクライアント領域に収まっていなければ false を返します。
+func RelativeRegion(abs Region, client image.Rectangle) (Region, bool) {
+	if !abs.Rect().In(client) {
+		return Region{}, false
+	}
+	return Region{X: abs.X - client.Min.X, Y: abs.Y - client.Min.Y, Width: abs.Width, Height: abs.Height}, true
+}
@@ -0,0 +1,63 @@
+package session
+
+import (
+	"errors"
+	"image"
+	"testing"
+
+	"AutoScreenShot/focus"
+)
+
+func TestWindowRegionRect(t *testing.T) {
+	offset := Region{X: 10, Y: 20, Width: 100, Height: 50}
+	tests := []struct {
+		name    string
+		client  image.Rectangle
+		state   focus.WindowState
+		want    image.Rectangle
+		wantErr error
+	}{
+		{"ウィンドウの位置に合わせる", image.Rect(300, 200, 800, 600), focus.StateNormal, image.Rect(310, 220, 410, 270), nil},
+		{"ウィンドウを動かした", image.Rect(0, 0, 500, 400), focus.StateNormal, image.Rect(10, 20, 110, 70), nil},
+		{"ウィンドウが小さくなった", image.Rect(0, 0, 100, 400), focus.StateNormal, image.Rectangle{}, ErrPaused},
+		{"最小化", image.Rect(0, 0, 500, 400), focus.StateMinimized, image.Rectangle{}, ErrPaused},
+		{"閉じられた", image.Rectangle{}, focus.StateClosed, image.Rectangle{}, ErrWindowClosed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &WindowRegion{
+				Offset: offset,
+				Locate: func() (image.Rectangle, focus.WindowState) { return tt.client, tt.state },
+			}
+			got, err := w.Rect()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Rect = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRelativeRegion(t *testing.T) {
+	client := image.Rect(100, 50, 600, 450)
+	tests := []struct {
+		name string
+		abs  Region
+		want Region
+		ok   bool
+	}{
+		{"中にある", Region{X: 150, Y: 80, Width: 200, Height: 100}, Region{X: 50, Y: 30, Width: 200, Height: 100}, true},
+		{"ちょうど重なる", Region{X: 100, Y: 50, Width: 500, Height: 400}, Region{Width: 500, Height: 400}, true},
+		{"はみ出す", Region{X: 550, Y: 80, Width: 100, Height: 100}, Region{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := RelativeRegion(tt.abs, client)
+			if ok != tt.ok || got != tt.want {
+				t.Errorf("RelativeRegion = %+v, %v, want %+v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
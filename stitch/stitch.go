@@ -0,0 +1,175 @@
+package stitch
+
+import (
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/draw"
+
+	"AutoScreenShot/imgutil"
+)
+
+// 重なり検出のパラメータ
+const (
+	minMatchRatio  = 0.9  // 重なり部分の行がこの割合以上一致すれば重なりとみなす
+	minInformative = 8    // 判定に使う「模様のある行」の最小数
+	matchSlack     = 0.05 // 最も良い一致率からこの差までは、重なりの大きい方を優先する
+)
+
+// RowHashes は画像の各行のハッシュと、その行が単色（背景だけ）かどうかを返します。
+func RowHashes(img image.Image) (hashes []uint64, uniform []bool) {
+	b := img.Bounds()
+	hashes = make([]uint64, b.Dy())
+	uniform = make([]bool, b.Dy())
+	buf := make([]byte, 0, b.Dx()*3)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		buf = buf[:0]
+		first := true
+		var r0, g0, b0 uint32
+		same := true
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, _ := img.At(x, y).RGBA()
+			// 下位ビットは捨てて、わずかな色ゆれを吸収する
+			r, g, bl = r>>10, g>>10, bl>>10
+			if first {
+				r0, g0, b0 = r, g, bl
+				first = false
+			} else if r != r0 || g != g0 || bl != b0 {
+				same = false
+			}
+			buf = append(buf, byte(r), byte(g), byte(bl))
+		}
+		h := fnv.New64a()
+		h.Write(buf)
+		hashes[y-b.Min.Y] = h.Sum64()
+		uniform[y-b.Min.Y] = same
+	}
+	return hashes, uniform
+}
+
+// FindShift は prev の下端と next の上端の重なりを探し、next が prev から何行スクロールした位置か返します。
+// 戻り値 shift は next の先頭行が prev の shift 行目に対応することを表し、
+// 新しく見えた部分は next の下から shift 行です。画面がまったく変わっていなければ shift=0 です。
+// 重なりが見つからなければ ok=false を返します。
+func FindShift(prev, next image.Image) (shift int, ok bool) {
+	ph, pu := RowHashes(prev)
+	nh, nu := RowHashes(next)
+	return findShift(ph, pu, nh, nu)
+}
+
+func findShift(ph []uint64, pu []bool, nh []uint64, nu []bool) (int, bool) {
+	h := len(ph)
+	if len(nh) < h {
+		h = len(nh)
+	}
+	ratios := make([]float64, h) // スクロール量ごとの一致率（判定できなければ -1）
+	bestRatio := 0.0
+	for d := 0; d < h; d++ {
+		informative, match := 0, 0
+		for i := 0; i+d < len(ph) && i < len(nh); i++ {
+			// 背景だけの行はどこにでも一致してしまうので判定に使わない
+			if nu[i] && pu[i+d] {
+				continue
+			}
+			informative++
+			if ph[i+d] == nh[i] {
+				match++
+			}
+		}
+		ratios[d] = -1
+		if informative >= minInformative {
+			ratios[d] = float64(match) / float64(informative)
+			bestRatio = max(bestRatio, ratios[d])
+		}
+	}
+	if bestRatio < minMatchRatio {
+		return 0, false
+	}
+	// 空行の並びなど繰り返す模様は、重なりの小さい位置でも偶然すべて一致することがある。
+	// 最も良い一致率との差が matchSlack 以内なら、重なりが大きい（スクロール量の小さい）位置を選ぶ
+	for d, r := range ratios {
+		if r >= minMatchRatio && r >= bestRatio-matchSlack {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
+// Stitcher はスクロールしながらキャプチャしたフレームを縦につなげます。
+type Stitcher struct {
+	strips []image.Image // つなげる部分（先頭は最初のフレーム全体、以降は新しく見えた部分）
+	last   image.Image
+	height int
+	width  int
+	Misses int // 重なりが見つからず、フレームをそのまま下に追加した回数
+}
+
+// Add はフレームを追加し、新たに伸びた行数を返します。
+// 重なりが見つからないフレームは、そのまま下につなげます。
+func (s *Stitcher) Add(img image.Image) int {
+	b := img.Bounds()
+	if s.last == nil {
+		s.strips = append(s.strips, imgutil.Crop(img, b))
+		s.last = img
+		s.width, s.height = b.Dx(), b.Dy()
+		return b.Dy()
+	}
+	shift, ok := FindShift(s.last, img)
+	if !ok {
+		s.Misses++
+		shift = b.Dy()
+	}
+	s.last = img
+	if shift == 0 {
+		return 0
+	}
+	strip := image.Rect(b.Min.X, b.Max.Y-shift, b.Max.X, b.Max.Y)
+	s.strips = append(s.strips, imgutil.Crop(img, strip))
+	if b.Dx() > s.width {
+		s.width = b.Dx()
+	}
+	s.height += shift
+	return shift
+}
+
+// Size はつなげた画像の幅と高さを返します。
+func (s *Stitcher) Size() (width, height int) {
+	return s.width, s.height
+}
+
+// Image はつなげた1枚の縦長画像を返します。フレームが無ければ nil を返します。
+func (s *Stitcher) Image() image.Image {
+	if len(s.strips) == 0 {
+		return nil
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, s.width, s.height))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	y := 0
+	for _, st := range s.strips {
+		b := st.Bounds()
+		draw.Draw(dst, image.Rect(0, y, b.Dx(), y+b.Dy()), st, b.Min, draw.Src)
+		y += b.Dy()
+	}
+	return dst
+}
+
+// Split は縦長画像を幅 × (幅 × aspect) のページに分割します。aspect は高さ/幅（A4 縦なら 1.414）です。
+// 最後のページの余りは白で埋めて同じ大きさにそろえます。aspect が 0 以下なら分割しません。
+func Split(tall image.Image, aspect float64) []image.Image {
+	b := tall.Bounds()
+	if aspect <= 0 {
+		return []image.Image{tall}
+	}
+	pageH := int(float64(b.Dx())*aspect + 0.5)
+	if pageH <= 0 {
+		return []image.Image{tall}
+	}
+	var pages []image.Image
+	for y := b.Min.Y; y < b.Max.Y; y += pageH {
+		page := image.NewRGBA(image.Rect(0, 0, b.Dx(), pageH))
+		draw.Draw(page, page.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+		draw.Draw(page, page.Bounds(), tall, image.Pt(b.Min.X, y), draw.Src)
+		pages = append(pages, page)
+	}
+	return pages
+}
@@ -0,0 +1,136 @@
+package stitch
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+// document は行ごとに模様の違う縦長の画像を返します。
+func document(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{uint8(y * 7), uint8(y*13 + x), uint8(y / 3 * x), 255})
+		}
+	}
+	return img
+}
+
+// view は doc の上から top 行目を先頭に、高さ h だけ見えている画面です。
+func view(doc *image.RGBA, top, h int) image.Image {
+	b := doc.Bounds()
+	img := image.NewRGBA(image.Rect(0, 0, b.Dx(), h))
+	draw.Draw(img, img.Bounds(), doc, image.Pt(0, top), draw.Src)
+	return img
+}
+
+func TestStitcher(t *testing.T) {
+	const w, h = 40, 60
+	tests := []struct {
+		name   string
+		tops   []int // 各フレームの先頭行
+		shifts []int // Add が返す伸びた行数
+		misses int
+	}{
+		{"一定のスクロール", []int{0, 20, 40, 60}, []int{60, 20, 20, 20}, 0},
+		{"スクロール量が変わる", []int{0, 10, 45, 50}, []int{60, 10, 35, 5}, 0},
+		{"動かなかったフレーム", []int{0, 30, 30, 50}, []int{60, 30, 0, 20}, 0},
+		{"重なりが無ければそのまま下につなげる", []int{0, 200}, []int{60, 60}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := document(w, 400)
+			var s Stitcher
+			for i, top := range tt.tops {
+				if got := s.Add(view(doc, top, h)); got != tt.shifts[i] {
+					t.Errorf("Add(frame %d) = %d, want %d", i, got, tt.shifts[i])
+				}
+			}
+			if s.Misses != tt.misses {
+				t.Errorf("Misses = %d, want %d", s.Misses, tt.misses)
+			}
+			total := 0
+			for _, n := range tt.shifts {
+				total += n
+			}
+			if gw, gh := s.Size(); gw != w || gh != total {
+				t.Errorf("Size = %dx%d, want %dx%d", gw, gh, w, total)
+			}
+			if tt.misses > 0 {
+				return
+			}
+			// 重なりを正しく見つけていれば、つなげた画像は元の画像の先頭部分と同じになる
+			img := s.Image()
+			for y := 0; y < total; y++ {
+				for x := 0; x < w; x++ {
+					if img.At(x, y) != doc.At(x, y) {
+						t.Fatalf("pixel (%d,%d) = %v, want %v", x, y, img.At(x, y), doc.At(x, y))
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestFindShiftBlankRows(t *testing.T) {
+	// 背景だけの行は一致しても重なりの根拠にしない
+	blank := image.NewRGBA(image.Rect(0, 0, 40, 60))
+	draw.Draw(blank, blank.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	if _, ok := FindShift(blank, blank); ok {
+		t.Error("FindShift found an overlap between blank frames")
+	}
+}
+
+func TestFindShiftPrefersLargerOverlap(t *testing.T) {
+	// 28 行ごとに同じ模様が繰り返すページを 2 行スクロールし、カーソルの 1 行だけが変わった。
+	// 30 行のスクロールでも重なる 10 行はすべて一致するが、ほぼ全体が一致する 2 行を選ぶ
+	const n = 40
+	ph, nh := make([]uint64, n), make([]uint64, n)
+	for i := range ph {
+		ph[i] = uint64(i%28) + 1
+	}
+	for i := range nh {
+		nh[i] = uint64((i+2)%28) + 1
+	}
+	nh[20] = 999
+	uniform := make([]bool, n)
+	if d, ok := findShift(ph, uniform, nh, uniform); !ok || d != 2 {
+		t.Errorf("findShift = %d, %v, want 2, true", d, ok)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name   string
+		h      int
+		aspect float64
+		pages  int
+		pageH  int
+	}{
+		{"分割しない", 250, 0, 1, 250},
+		{"ちょうど割り切れる", 300, 1.5, 2, 150},
+		{"余りは白で埋める", 320, 1.5, 3, 150},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pages := Split(document(100, tt.h), tt.aspect)
+			if len(pages) != tt.pages {
+				t.Fatalf("len(pages) = %d, want %d", len(pages), tt.pages)
+			}
+			for i, p := range pages {
+				if p.Bounds().Dy() != tt.pageH {
+					t.Errorf("page %d height = %d, want %d", i, p.Bounds().Dy(), tt.pageH)
+				}
+			}
+			last := pages[len(pages)-1]
+			if rest := tt.h - (tt.pages-1)*tt.pageH; rest < tt.pageH {
+				r, g, b, _ := last.At(0, tt.pageH-1).RGBA()
+				if r != 0xffff || g != 0xffff || b != 0xffff {
+					t.Errorf("padding is not white")
+				}
+			}
+		})
+	}
+}
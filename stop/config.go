@@ -0,0 +1,101 @@
+package stop
+
+import (
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"time"
+
+	"AutoScreenShot/compare"
+)
+
+// 条件の組み合わせ方
+const (
+	CombineAny = "any" // いずれかが成立したら終了（OR）
+	CombineAll = "all" // すべてが成立したら終了（AND）
+)
+
+// Config は設定ダイアログで指定する終了条件です。0 や空文字の項目は使いません。
+type Config struct {
+	MaxCount      int    // 最大枚数
+	SameFrames    int    // 同一フレームの連続数（従来の「3枚連続同一」は 3）
+	MaxElapsedSec int    // 最大経過時間（秒）
+	MaxBytesMB    int    // 最大書き込み量（MB）
+	EndScreenPath string // 終了画面の画像ファイル（JPG/PNG）
+	ErrorStreak   int    // キャプチャ失敗の連続回数（0 なら最初の失敗で中止）
+	Expr          string // 終了条件の式（ExprVars の変数を使う。例: "count >= 300 || elapsedSec > 3600"）
+	Combine       string // CombineAny または CombineAll（空なら CombineAny）
+}
+
+// Build は設定から終了条件を組み立てます。有効な条件が1つも無ければ nil を返します。
+// Combine（AND/OR）は利用者の条件（連続同一・経過時間・書き込み量・終了画面・式）にだけ適用し、
+// 最大枚数とキャプチャ失敗の連続回数は、組み合わせ方に関係なくどちらかが成立したら終了する上限として OR で加えます。
+// 終了画面の画像は cmp（除外範囲を含む）でハッシュ化するため、キャプチャ範囲と同じ大きさの画像を指定してください。
+func (c Config) Build(cmp compare.Comparer) (Condition, error) {
+	switch c.Combine {
+	case "", CombineAny, CombineAll:
+	default:
+		return nil, fmt.Errorf("不明な組み合わせ方です: %q", c.Combine)
+	}
+	var conds []Condition
+	if c.SameFrames > 0 {
+		conds = append(conds, SameFrames(c.SameFrames))
+	}
+	if c.MaxElapsedSec > 0 {
+		conds = append(conds, MaxElapsed(time.Duration(c.MaxElapsedSec)*time.Second))
+	}
+	if c.MaxBytesMB > 0 {
+		conds = append(conds, MaxBytes(int64(c.MaxBytesMB)*1024*1024))
+	}
+	if c.EndScreenPath != "" {
+		h, err := hashImageFile(c.EndScreenPath, cmp)
+		if err != nil {
+			return nil, fmt.Errorf("終了画面の画像を読み込めません: %w", err)
+		}
+		conds = append(conds, EndScreen{Hash: h, Comparer: cmp})
+	}
+	if c.Expr != "" {
+		e, err := ParseExpr(c.Expr)
+		if err != nil {
+			return nil, err
+		}
+		conds = append(conds, e)
+	}
+
+	var limits Any
+	if c.MaxCount > 0 {
+		limits = append(limits, MaxCount(c.MaxCount))
+	}
+	if c.ErrorStreak > 0 {
+		limits = append(limits, ErrorStreak(c.ErrorStreak))
+	}
+	switch {
+	case len(conds) == 0:
+	case c.Combine == CombineAll:
+		limits = append(limits, All(conds))
+	default:
+		limits = append(limits, Any(conds))
+	}
+	switch len(limits) {
+	case 0:
+		return nil, nil
+	case 1:
+		return limits[0], nil
+	}
+	return limits, nil
+}
+
+func hashImageFile(path string, cmp compare.Comparer) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, err
+	}
+	return cmp.Sum(img)
+}
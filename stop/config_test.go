@@ -0,0 +1,57 @@
+package stop
+
+import (
+	"testing"
+
+	"AutoScreenShot/compare"
+)
+
+func TestBuildLimitsOutsideCombine(t *testing.T) {
+	all := Config{MaxCount: 10, SameFrames: 3, MaxElapsedSec: 60, ErrorStreak: 1, Combine: CombineAll}
+	tests := []struct {
+		name string
+		cfg  Config
+		s    State
+		want bool
+	}{
+		{"AND は利用者の条件がすべて成立したら終了", all, State{Count: 2, SameStreak: 3, Elapsed: 61e9}, true},
+		{"AND は1つだけでは終了しない", all, State{Count: 2, SameStreak: 3}, false},
+		{"失敗の連続数が 0 でも AND で終了できる", all, State{Count: 2, SameStreak: 3, Elapsed: 61e9, ErrorStreak: 0}, true},
+		{"最大枚数は AND でも上限", all, State{Count: 10}, true},
+		{"失敗の連続回数は AND でも上限", all, State{ErrorStreak: 1}, true},
+		{"OR はいずれかで終了", Config{SameFrames: 3, MaxElapsedSec: 60}, State{SameStreak: 3}, true},
+		{"上限だけ", Config{MaxCount: 5}, State{Count: 4}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := tt.cfg.Build(compare.Comparer{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := c.Met(tt.s); got != tt.want {
+				t.Errorf("Met(%+v) = %v, want %v（%s）", tt.s, got, tt.want, c)
+			}
+		})
+	}
+}
+
+func TestBuildNoConditions(t *testing.T) {
+	c, err := Config{}.Build(compare.Comparer{})
+	if err != nil || c != nil {
+		t.Errorf("Build() = %v, %v; want nil, nil", c, err)
+	}
+	if _, err := (Config{MaxCount: 1, Combine: "xor"}).Build(compare.Comparer{}); err == nil {
+		t.Error("不明な組み合わせ方がエラーになりません")
+	}
+}
+
+func TestWhichReportsLimit(t *testing.T) {
+	c, err := Config{MaxCount: 3, SameFrames: 2, MaxElapsedSec: 10, Combine: CombineAll}.Build(compare.Comparer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	met := Which(c, State{Count: 3, SameStreak: 2})
+	if len(met) != 1 || met[0] != MaxCount(3) {
+		t.Errorf("Which = %v, want [最大枚数 3 枚]", met)
+	}
+}
@@ -0,0 +1,98 @@
+package stop
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/Knetic/govaluate.v3"
+)
+
+// ExprVars は式の中で使える変数名と説明です。
+var ExprVars = map[string]string{
+	"count":       "保存した枚数",
+	"sameStreak":  "同一フレームの連続数（最新フレームを含む）",
+	"elapsedSec":  "開始からの経過秒数",
+	"bytes":       "保存した画像の合計バイト数",
+	"errorStreak": "キャプチャ失敗の連続回数",
+	"distance":    "直前のフレームとのハミング距離（知覚ハッシュのときだけ。完全一致や比較できないときは -1）",
+}
+
+// Expr は実行状況の変数を使った条件式です（例: "count >= 300 || sameStreak >= 5"）。
+// 終了条件と、保存をスキップする条件の両方に使います。
+type Expr struct {
+	src  string
+	expr *govaluate.EvaluableExpression
+}
+
+// ParseExpr は条件式を解析します。未知の変数や真偽値にならない式はエラーになるため、
+// 実行開始前（設定の読み込み時）に呼んで確認してください。
+func ParseExpr(src string) (*Expr, error) {
+	src = strings.TrimSpace(src)
+	expr, err := govaluate.NewEvaluableExpression(src)
+	if err != nil {
+		return nil, fmt.Errorf("式 %q を解析できません: %w", src, err)
+	}
+	for _, v := range expr.Vars() {
+		if _, ok := ExprVars[v]; !ok {
+			return nil, fmt.Errorf("式 %q に不明な変数 %q があります（使える変数: %s）", src, v, strings.Join(exprVarNames(), ", "))
+		}
+	}
+	e := &Expr{src: src, expr: expr}
+	// すべて 0 の状況で評価して、真偽値が返ることを確認しておく
+	if _, err := e.eval(State{}); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Met は状況 s で式が真なら true を返します。評価に失敗した場合は false です。
+func (e *Expr) Met(s State) bool {
+	ok, err := e.eval(s)
+	return err == nil && ok
+}
+
+// Uses は式が変数 name を使っているか返します。
+func (e *Expr) Uses(name string) bool {
+	for _, v := range e.expr.Vars() {
+		if v == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *Expr) String() string { return "式 " + e.src }
+
+func (e *Expr) eval(s State) (bool, error) {
+	v, err := e.expr.Evaluate(s.Vars())
+	if err != nil {
+		return false, fmt.Errorf("式 %q を評価できません: %w", e.src, err)
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("式 %q の結果が真偽値ではありません: %v", e.src, v)
+	}
+	return b, nil
+}
+
+// Vars は式の評価に渡す変数を返します。数値はすべて float64 です。
+func (s State) Vars() map[string]interface{} {
+	return map[string]interface{}{
+		"count":       float64(s.Count),
+		"sameStreak":  float64(s.SameStreak),
+		"elapsedSec":  s.Elapsed.Seconds(),
+		"bytes":       float64(s.BytesWritten),
+		"errorStreak": float64(s.ErrorStreak),
+		"distance":    float64(s.Distance),
+	}
+}
+
+func exprVarNames() []string {
+	names := make([]string, 0, len(ExprVars))
+	for n := range ExprVars {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,76 @@
+package stop
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseExpr(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		wantErr string // エラーに含まれる文字列（空ならエラーにならない）
+	}{
+		{"比較", "count >= 300", ""},
+		{"組み合わせ", " sameStreak >= 5 || (elapsedSec > 600 && bytes > 1000000000) ", ""},
+		{"距離", "distance >= 0 && distance <= 3", ""},
+		{"不明な変数", "pages >= 3", `不明な変数 "pages"`},
+		{"構文エラー", "count >=", "解析できません"},
+		{"真偽値にならない", "count + 1", "真偽値ではありません"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseExpr(tt.src)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("ParseExpr(%q): %v", tt.src, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("ParseExpr(%q) error = %v, want %q", tt.src, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExprMet(t *testing.T) {
+	tests := []struct {
+		src  string
+		s    State
+		want bool
+	}{
+		{"count >= 3", State{Count: 3}, true},
+		{"count >= 3", State{Count: 2}, false},
+		{"sameStreak >= 2 && count > 10", State{Count: 11, SameStreak: 2}, true},
+		{"sameStreak >= 2 && count > 10", State{Count: 10, SameStreak: 2}, false},
+		{"elapsedSec >= 1.5", State{Elapsed: 1500 * time.Millisecond}, true},
+		{"bytes > 1000 || errorStreak >= 3", State{ErrorStreak: 3}, true},
+		{"distance >= 0 && distance <= 4", State{Distance: -1}, false},
+		{"distance >= 0 && distance <= 4", State{Distance: 4}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			e, err := ParseExpr(tt.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := e.Met(tt.s); got != tt.want {
+				t.Errorf("Met(%+v) = %v, want %v", tt.s, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExprUses(t *testing.T) {
+	e, err := ParseExpr("bytes > 1000000 || count >= 10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]bool{"bytes": true, "count": true, "sameStreak": false} {
+		if got := e.Uses(name); got != want {
+			t.Errorf("Uses(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
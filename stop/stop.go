@@ -0,0 +1,165 @@
+package stop
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"AutoScreenShot/compare"
+)
+
+// State は1枚キャプチャするごとに終了条件へ渡す実行状況です。
+type State struct {
+	Count        int           // 保存した枚数
+	SameStreak   int           // 直前までと同一と判定されたフレームの連続数（最新フレームを含む）
+	Elapsed      time.Duration // 開始からの経過時間
+	BytesWritten int64         // 保存した画像の合計バイト数
+	ErrorStreak  int           // キャプチャ失敗の連続回数
+	Hash         []byte        // 最新フレームのハッシュ（compare.Comparer.Sum の値）
+	Distance     int           // 直前のフレームとのハミング距離（compare.Comparer.Distance の値。知覚ハッシュ以外では -1）
+}
+
+// Condition は終了条件です。
+type Condition interface {
+	// Met は状況 s で終了すべきなら true を返します。
+	Met(s State) bool
+	// String はセッション記録に残す条件の説明です。
+	String() string
+}
+
+// MaxCount は保存枚数が指定数に達したら成立します。
+type MaxCount int
+
+func (n MaxCount) Met(s State) bool { return s.Count >= int(n) }
+func (n MaxCount) String() string   { return fmt.Sprintf("最大枚数 %d 枚", int(n)) }
+
+// SameFrames は同一フレームが指定数連続したら成立します。
+type SameFrames int
+
+func (n SameFrames) Met(s State) bool { return s.SameStreak >= int(n) }
+func (n SameFrames) String() string   { return fmt.Sprintf("%d 枚連続同一", int(n)) }
+
+// MaxElapsed は開始からの経過時間が指定時間を超えたら成立します。
+type MaxElapsed time.Duration
+
+func (d MaxElapsed) Met(s State) bool { return s.Elapsed >= time.Duration(d) }
+func (d MaxElapsed) String() string   { return fmt.Sprintf("経過時間 %v", time.Duration(d)) }
+
+// MaxBytes は保存した画像の合計サイズが指定バイト数に達したら成立します。
+type MaxBytes int64
+
+func (n MaxBytes) Met(s State) bool { return s.BytesWritten >= int64(n) }
+func (n MaxBytes) String() string {
+	return fmt.Sprintf("書き込み量 %.1f MB", float64(n)/(1024*1024))
+}
+
+// ErrorStreak はキャプチャ失敗が指定回数連続したら成立します。
+type ErrorStreak int
+
+func (n ErrorStreak) Met(s State) bool { return s.ErrorStreak >= int(n) }
+func (n ErrorStreak) String() string {
+	return fmt.Sprintf("キャプチャ失敗 %d 回連続", int(n))
+}
+
+// EndScreen は最新フレームが基準の「終了画面」と同一とみなせたら成立します。
+type EndScreen struct {
+	Hash     []byte // 終了画面のハッシュ（Comparer.Sum の値）
+	Comparer compare.Comparer
+}
+
+func (e EndScreen) Met(s State) bool { return e.Comparer.Same(s.Hash, e.Hash) }
+func (e EndScreen) String() string   { return "終了画面に一致" }
+
+// Any はいずれかの条件が成立したら成立します（OR）。
+type Any []Condition
+
+func (a Any) Met(s State) bool {
+	for _, c := range a {
+		if c.Met(s) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a Any) String() string { return join(a, " または ") }
+
+// All はすべての条件が成立したら成立します（AND）。空なら成立しません。
+type All []Condition
+
+func (a All) Met(s State) bool {
+	if len(a) == 0 {
+		return false
+	}
+	for _, c := range a {
+		if !c.Met(s) {
+			return false
+		}
+	}
+	return true
+}
+
+func (a All) String() string { return join(a, " かつ ") }
+
+func join(cs []Condition, sep string) string {
+	parts := make([]string, len(cs))
+	for i, c := range cs {
+		parts[i] = c.String()
+		if _, ok := c.(Any); ok {
+			parts[i] = "(" + parts[i] + ")"
+		} else if _, ok := c.(All); ok {
+			parts[i] = "(" + parts[i] + ")"
+		}
+	}
+	return strings.Join(parts, sep)
+}
+
+// Which は c が状況 s で成立しているとき、成立に寄与した末端の条件を返します。
+// 成立していなければ nil を返します。
+func Which(c Condition, s State) []Condition {
+	if c == nil || !c.Met(s) {
+		return nil
+	}
+	var met []Condition
+	switch c := c.(type) {
+	case Any:
+		for _, sub := range c {
+			met = append(met, Which(sub, s)...)
+		}
+	case All:
+		for _, sub := range c {
+			met = append(met, Which(sub, s)...)
+		}
+	default:
+		met = append(met, c)
+	}
+	return met
+}
+
+// UsesBytes は c が書き込み量（State.BytesWritten）を見る条件を含むか返します。
+func UsesBytes(c Condition) bool {
+	switch c := c.(type) {
+	case MaxBytes:
+		return true
+	case *Expr:
+		return c.Uses("bytes")
+	case Any:
+		for _, sub := range c {
+			if UsesBytes(sub) {
+				return true
+			}
+		}
+	case All:
+		for _, sub := range c {
+			if UsesBytes(sub) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Reason は成立した条件の説明を返します（セッション記録用）。
+func Reason(met []Condition) string {
+	return join(met, "、")
+}
@@ -0,0 +1,201 @@
+package trim
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"AutoScreenShot/imgutil"
+	"AutoScreenShot/layout"
+	"AutoScreenShot/output"
+)
+
+// 余白の切り取り方
+const (
+	ModeOff     = ""        // 切り取らない
+	ModePage    = "page"    // ページごとに内容の範囲で切り取る
+	ModeUniform = "uniform" // 全ページの内容の範囲を合わせた1つの範囲で切り取る（ページの大きさがそろう）
+)
+
+// DefaultTolerance は背景色とみなす色の差（RGB の差の最大値）の初期値です。JPG のノイズを吸収できる程度にします。
+const DefaultTolerance = 24
+
+// Options は余白の切り取りの設定です。
+type Options struct {
+	Mode      string
+	Tolerance int // 背景色とみなす色の差（0〜255。0 以下なら DefaultTolerance）
+	Padding   int // 内容の範囲の外側に残す幅（ピクセル）
+	// Layout は切り取ったあとに1枚の画像を複数ページに分ける方法です（Apply で使います）。
+	Layout layout.Options
+}
+
+// Result は Apply の結果です。
+type Result struct {
+	Trimmed int             // 切り取った画像の数（ModePage では分けたページの数）
+	Box     image.Rectangle // ModeUniform で使った範囲（内容が見つからなければ空）
+}
+
+// Bounds は img の周囲の背景を除いた内容の範囲を返します。
+// 背景色は外周の画素で最も多い色とし、そこから opt.Tolerance より離れた画素を含む行と列の範囲を内容とします。
+// 内容が見つからない（空白ページ）ときは false を返します。
+func Bounds(img image.Image, opt Options) (image.Rectangle, bool) {
+	b := img.Bounds()
+	if b.Empty() {
+		return b, false
+	}
+	tol := opt.Tolerance
+	if tol <= 0 {
+		tol = DefaultTolerance
+	}
+	bg := imgutil.EdgeColor(img, b)
+	// 行・列ごとに背景と違う画素を数え、数画素だけのノイズには反応しないようにする
+	rows := make([]int, b.Dy())
+	cols := make([]int, b.Dx())
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if imgutil.ColorDiff(img.At(x, y), bg) > tol {
+				rows[y-b.Min.Y]++
+				cols[x-b.Min.X]++
+			}
+		}
+	}
+	y0, y1, ok := span(rows, max(1, b.Dx()/500))
+	if !ok {
+		return image.Rectangle{}, false
+	}
+	x0, x1, ok := span(cols, max(1, b.Dy()/500))
+	if !ok {
+		return image.Rectangle{}, false
+	}
+	r := image.Rect(b.Min.X+x0, b.Min.Y+y0, b.Min.X+x1, b.Min.Y+y1)
+	return r.Inset(-opt.Padding).Intersect(b), true
+}
+
+// span は counts が min を超える最初と最後の位置を [start, end) で返します。
+func span(counts []int, min int) (start, end int, ok bool) {
+	start = -1
+	for i, n := range counts {
+		if n > min {
+			if start < 0 {
+				start = i
+			}
+			end = i + 1
+		}
+	}
+	return start, end, start >= 0
+}
+
+// Apply は paths の画像の余白を opt.Mode に従って切り取り、opt.Layout で分けてから w の形式で保存し直します。
+// 切り取った画像の保存を最初のエンコードにするため、paths には分けていない可逆の画像（DirSink の Lossless で保存した PNG など）を渡してください。
+// ModeUniform ではすべての画像の内容を合わせた1つの範囲で切ってから分けるので、分けたページの大きさもそろいます（のどで分けるときは、のどの位置の分だけ違います）。
+// ModePage では分けたページごとに内容の範囲で切り取り、内容が見つからないページ（空白ページ）はそのままにします。
+// 保存した画像は元のファイルと置き換え、名前（拡張子や分けたページの番号）が変われば元のファイルを消します。
+func Apply(paths []string, opt Options, w output.ImageWriter) (Result, error) {
+	var res Result
+	if opt.Mode == ModeOff {
+		return res, nil
+	}
+	if opt.Mode != ModePage && opt.Mode != ModeUniform {
+		return res, fmt.Errorf("不明な余白の切り取り方です: %q", opt.Mode)
+	}
+	if opt.Mode == ModeUniform {
+		for _, p := range paths {
+			img, err := load(p)
+			if err != nil {
+				return res, fmt.Errorf("%s: %w", filepath.Base(p), err)
+			}
+			if box, ok := Bounds(img, opt); ok {
+				res.Box = res.Box.Union(box)
+			}
+		}
+	}
+	for _, p := range paths {
+		img, err := load(p)
+		if err != nil {
+			return res, fmt.Errorf("%s: %w", filepath.Base(p), err)
+		}
+		changed := false
+		if opt.Mode == ModeUniform {
+			// 大きさの違う画像もあるため、範囲は画像ごとに画像の内側に収める
+			if box := res.Box.Intersect(img.Bounds()); !box.Empty() && box != img.Bounds() {
+				img, changed = imgutil.Crop(img, box), true
+				res.Trimmed++
+			}
+		}
+		parts := layout.Split(img, opt.Layout)
+		if opt.Mode == ModePage {
+			for i, part := range parts {
+				if box, ok := Bounds(part, opt); ok && box != part.Bounds() {
+					parts[i], changed = imgutil.Crop(part, box), true
+					res.Trimmed++
+				}
+			}
+		}
+		// 切り取りも分割もせず形式も同じなら、保存し直さない（非可逆の形式で劣化させないように）
+		if !changed && len(parts) == 1 && strings.EqualFold(filepath.Ext(p), w.Ext()) {
+			continue
+		}
+		if err := save(p, parts, w); err != nil {
+			return res, fmt.Errorf("%s: %w", filepath.Base(p), err)
+		}
+	}
+	return res, nil
+}
+
+func load(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	return img, err
+}
+
+// save は parts を w の形式で path の代わりに保存します。分けたページには番号を付けます（screenshot_00012.png → screenshot_00012_1.jpg …）。
+// 一時ファイルに書いてから置き換えるので、途中で失敗しても元の画像は残ります。
+func save(path string, parts []image.Image, w output.ImageWriter) error {
+	stem := strings.TrimSuffix(path, filepath.Ext(path))
+	names := make([]string, 0, len(parts))
+	for i, part := range parts {
+		name := stem + w.Ext()
+		if len(parts) > 1 {
+			name = fmt.Sprintf("%s_%d%s", stem, i+1, w.Ext())
+		}
+		if err := write(name+".tmp", part, w); err != nil {
+			for _, done := range names {
+				os.Remove(done + ".tmp")
+			}
+			return err
+		}
+		names = append(names, name)
+	}
+	for _, name := range names {
+		if err := os.Rename(name+".tmp", name); err != nil {
+			return err
+		}
+	}
+	if !slices.Contains(names, path) {
+		return os.Remove(path)
+	}
+	return nil
+}
+
+// write は img を w の形式で path に書き込みます。失敗したらファイルを削除します。
+func write(path string, img image.Image, w output.ImageWriter) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = w.Encode(f, img)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+	return err
+}
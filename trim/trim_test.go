@@ -0,0 +1,179 @@
+package trim
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"AutoScreenShot/layout"
+	"AutoScreenShot/output"
+)
+
+// frame は灰色の背景の w×h の画像の boxes を黒く塗った画像を返します。
+func frame(w, h int, boxes ...image.Rectangle) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.Gray{Y: 128}), image.Point{}, draw.Src)
+	for _, b := range boxes {
+		draw.Draw(img, b, image.NewUniform(color.Black), image.Point{}, draw.Src)
+	}
+	return img
+}
+
+func writePNG(t *testing.T, path string, img image.Image) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// sizes は dir の画像の名前と大きさを返します。
+func sizes(t *testing.T, dir string) map[string]image.Point {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := map[string]image.Point{}
+	for _, e := range entries {
+		f, err := os.Open(filepath.Join(dir, e.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg, _, err := image.DecodeConfig(f)
+		f.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", e.Name(), err)
+		}
+		out[e.Name()] = image.Pt(cfg.Width, cfg.Height)
+	}
+	return out
+}
+
+func TestBounds(t *testing.T) {
+	tests := []struct {
+		name string
+		img  image.Image
+		opt  Options
+		want image.Rectangle
+		ok   bool
+	}{
+		{"内容の範囲", frame(100, 80, image.Rect(20, 10, 60, 50)), Options{}, image.Rect(20, 10, 60, 50), true},
+		{"余白を残す", frame(100, 80, image.Rect(20, 10, 60, 50)), Options{Padding: 4}, image.Rect(16, 6, 64, 54), true},
+		{"画像の外には広げない", frame(100, 80, image.Rect(0, 0, 60, 50)), Options{Padding: 4}, image.Rect(0, 0, 64, 54), true},
+		{"空白ページ", frame(100, 80), Options{}, image.Rectangle{}, false},
+		{"許容差までは背景", frame(100, 80), Options{Tolerance: 200}, image.Rectangle{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Bounds(tt.img, tt.opt)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("Bounds = %v, %v; want %v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	// 見開きの左右で内容の位置が違う2枚のキャプチャ
+	frames := []*image.RGBA{
+		frame(200, 100, image.Rect(30, 20, 90, 80), image.Rect(110, 10, 150, 90)),
+		frame(200, 100, image.Rect(40, 30, 95, 70), image.Rect(105, 20, 170, 60)),
+	}
+	tests := []struct {
+		name    string
+		opt     Options
+		w       output.ImageWriter
+		want    map[string]image.Point
+		trimmed int
+	}{
+		{
+			"全ページ同じ範囲で切ってから分ける",
+			Options{Mode: ModeUniform, Layout: layout.Options{Mode: layout.SplitCenter}},
+			output.JPEGWriter{},
+			map[string]image.Point{
+				"screenshot_00001_1.jpg": {70, 80}, "screenshot_00001_2.jpg": {70, 80},
+				"screenshot_00002_1.jpg": {70, 80}, "screenshot_00002_2.jpg": {70, 80},
+			},
+			2,
+		},
+		{
+			"分けたページごとに切る",
+			Options{Mode: ModePage, Layout: layout.Options{Mode: layout.SplitCenter}},
+			output.PNGWriter{},
+			map[string]image.Point{
+				"screenshot_00001_1.png": {60, 60}, "screenshot_00001_2.png": {40, 80},
+				"screenshot_00002_1.png": {55, 40}, "screenshot_00002_2.png": {65, 40},
+			},
+			4,
+		},
+		{
+			"分けない",
+			Options{Mode: ModeUniform},
+			output.JPEGWriter{},
+			map[string]image.Point{"screenshot_00001.jpg": {140, 80}, "screenshot_00002.jpg": {140, 80}},
+			2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			var paths []string
+			for i, f := range frames {
+				p := filepath.Join(dir, output.PageFileName(i+1, 0, ".png"))
+				writePNG(t, p, f)
+				paths = append(paths, p)
+			}
+			res, err := Apply(paths, tt.opt, tt.w)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Trimmed != tt.trimmed {
+				t.Errorf("Trimmed = %d, want %d", res.Trimmed, tt.trimmed)
+			}
+			got := sizes(t, dir)
+			if len(got) != len(tt.want) {
+				names := make([]string, 0, len(got))
+				for n := range got {
+					names = append(names, n)
+				}
+				sort.Strings(names)
+				t.Fatalf("files = %v", names)
+			}
+			for name, size := range tt.want {
+				if got[name] != size {
+					t.Errorf("%s = %v, want %v", name, got[name], size)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyKeepsUnchangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "screenshot_00001.png")
+	writePNG(t, p, frame(50, 50))
+	before, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Apply([]string{p}, Options{Mode: ModePage}, output.PNGWriter{}); err != nil {
+		t.Fatal(err)
+	}
+	after, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(before) != string(after) {
+		t.Error("切り取らないページを保存し直しました")
+	}
+}
@@ -4,49 +4,124 @@ package ui
 
 import (
 	"fmt"
+	"image"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
+	"AutoScreenShot/capture"
+	"AutoScreenShot/compare"
+	"AutoScreenShot/dedup"
 	"AutoScreenShot/focus"
+	"AutoScreenShot/keyboard"
+	"AutoScreenShot/layout"
+	"AutoScreenShot/output"
+	"AutoScreenShot/session"
+	"AutoScreenShot/stop"
+	"AutoScreenShot/trim"
 
 	"github.com/lxn/walk"
 	"github.com/lxn/win"
 )
 
 // Settings はメインループに渡す設定です。
-type Settings struct {
-	Region           Region
-	OutputFolder     string
-	KeyOperation     string
-	FocusWindowTitle string // 開始前にフォーカスするウィンドウのタイトル（空なら行わない）
-	MaxCount         int
-	StopOnThreeSame  bool
-	DelayMsAfterKey  int
-	PDFTitle         string // PDFのタイトル（デフォルトは screenshot-YYYY-MM-DD_HH-MM-SS）
-}
+type Settings = session.Settings
+
+// compareModes は「同一判定」コンボボックスの並び順に対応する比較モードです。
+var compareModes = []compare.Mode{compare.ModeExact, compare.ModeAverage, compare.ModeDifference, compare.ModeDCT}
+
+var compareModeNames = []string{"完全一致", "aHash（平均）", "dHash（差分）", "pHash（DCT）"}
+
+// blankActions は「空白ページ」コンボボックスの並び順に対応する扱いです。
+var blankActions = []string{compare.BlankKeep, compare.BlankSkip, compare.BlankMark, compare.BlankRecapture}
+
+var blankActionNames = []string{"通常のページとして扱う", "保存しない", "記録して保存", "撮り直す"}
+
+// dedupActions は「重複ページ」コンボボックスの並び順に対応する扱いです。
+var dedupActions = []string{dedup.ActionOff, dedup.ActionRemove, dedup.ActionFlag}
+
+var dedupActionNames = []string{"そのまま", "削除する", dedup.DuplicatesDirName + " フォルダへ移動"}
+
+// splitModes は「ページ分割」コンボボックスの並び順に対応する分け方です。
+var splitModes = []string{layout.SplitNone, layout.SplitCenter, layout.SplitGutter, layout.SplitGrid}
+
+var splitModeNames = []string{"分けない", "中央で左右に分ける", "のどを検出して左右に分ける", "格子に分ける"}
+
+// readingOrders は「読み順」コンボボックスの並び順に対応する読み順です。
+var readingOrders = []string{layout.OrderLTR, layout.OrderRTL}
+
+var readingOrderNames = []string{"左から右", "右から左"}
+
+// trimModes は「余白の切り取り」コンボボックスの並び順に対応する切り取り方です。
+var trimModes = []string{trim.ModeOff, trim.ModePage, trim.ModeUniform}
+
+var trimModeNames = []string{"切り取らない", "ページごとに切り取る", "全ページ同じ範囲で切り取る"}
+
+// imageFormats は「保存形式」コンボボックスの並び順に対応する保存形式です。
+var imageFormats = []string{output.FormatJPEG, output.FormatPNG, output.FormatPNG8, output.FormatTIFF}
+
+var imageFormatNames = []string{"JPEG", "PNG", "PNG（256 色）", "TIFF"}
+
+// pdfLayouts は「PDF の表示」コンボボックスの並び順に対応するページの並べ方です。
+var pdfLayouts = []string{output.PDFLayoutDefault, output.PDFLayoutSingle, output.PDFLayoutSpread}
+
+var pdfLayoutNames = []string{"ビューアーに任せる", "1ページずつ", "見開き"}
+
+// pdfPageSizes と pdfPageNUps は「PDF のページ」コンボボックスの並び順に対応するページの大きさの決め方と、用紙1枚に並べるページ数です。
+var pdfPageSizes = []string{output.PDFSizeImage, output.PDFSizePaper, output.PDFSizeNUp, output.PDFSizeNUp}
+
+var pdfPageNUps = []int{0, 0, 2, 4}
+
+var pdfPageSizeNames = []string{"画像の大きさ", "用紙に合わせる", "用紙に2ページ", "用紙に4ページ"}
+
+// papers は「用紙」コンボボックスの並び順に対応する用紙です。
+var papers = []string{output.PaperA4, output.PaperLetter, output.PaperB5}
+
+var paperNames = []string{"A4", "レター", "B5（JIS）"}
+
+// tiffCompressions は「マルチページ TIFF」の圧縮コンボボックスの並び順に対応する圧縮方式です。
+var tiffCompressions = []string{output.TIFFLZW, output.TIFFDeflate, output.TIFFNone, output.TIFFG4}
+
+var tiffCompressionNames = []string{"LZW", "Deflate", "圧縮しない", "G4（白黒2値）"}
 
 // RunSettingsDialog は設定ダイアログを表示し、ユーザーが「開始」を押したとき設定を返します。
 // キャンセル時は ok が false です。
 func RunSettingsDialog() (Settings, bool) {
 	var dlg *walk.Dialog
-	var folderEdit, keyEdit, pdfTitleEdit *walk.LineEdit
+	var folderEdit, keyEdit, altKeyEdit, pdfTitleEdit *walk.LineEdit
+	var pdfAuthorEdit, pdfSubjectEdit, pdfKeywordsEdit, pdfCreatorEdit, pdfInfoEdit *walk.LineEdit
+	var xmpCheck *walk.CheckBox
+	var turnRetriesEdit *walk.NumberEdit
+	var stitchCheck, relativeCheck *walk.CheckBox
+	var dedupCombo, blankCombo *walk.ComboBox
+	var pdfSkipBlankCheck *walk.CheckBox
+	var stitchAspectEdit *walk.NumberEdit
+	var splitCombo, orderCombo *walk.ComboBox
+	var splitColsEdit, splitRowsEdit *walk.NumberEdit
+	var trimCombo, formatCombo *walk.ComboBox
+	var trimToleranceEdit *walk.NumberEdit
+	var tiffCheck, cbzCheck, epubCheck, coverCheck *walk.CheckBox
+	var pdfLayoutCombo, pdfPageSizeCombo, paperCombo *walk.ComboBox
+	var marginEdit, pdfDPIEdit *walk.NumberEdit
+	var screenDPICheck *walk.CheckBox
+	var tiffCombo *walk.ComboBox
+	var tiffDPIEdit *walk.NumberEdit
 	var focusCombo *walk.ComboBox
-	var maxCountEdit *walk.NumberEdit
-	var delayEdit *walk.NumberEdit
-	var stopThreeCheck *walk.CheckBox
-	var regionLabel *walk.Label
+	var maxCountEdit, sameFramesEdit, elapsedEdit, bytesEdit, errorStreakEdit *walk.NumberEdit
+	var endScreenEdit, stopExprEdit, skipExprEdit *walk.LineEdit
+	var chapterKeyEdit, chapterExprEdit, chapterTitleEdit *walk.LineEdit
+	var combineCombo *walk.ComboBox
+	var delayEdit, stableFramesEdit, stableMinEdit, stableMaxEdit *walk.NumberEdit
+	var compareCombo *walk.ComboBox
+	var toleranceEdit *walk.NumberEdit
+	var regionLabel, maskLabel *walk.Label
 	var startBtn *walk.PushButton
 
-	settings := Settings{
-		KeyOperation:    "Enter",
-		MaxCount:        500,
-		StopOnThreeSame: true,
-		DelayMsAfterKey: 500,
-		PDFTitle:        "screenshot-" + time.Now().Format("2006-01-02_15-04-05"),
-	}
+	settings := session.DefaultSettings()
 
 	dlg, err := walk.NewDialog(nil)
 	if err != nil {
@@ -78,6 +153,31 @@ func RunSettingsDialog() (Settings, bool) {
 			regionLabel.SetText(strconv.Itoa(reg.Width) + " x " + strconv.Itoa(reg.Height))
 		}
 	})
+	detectBtn, _ := walk.NewPushButton(regionComp)
+	detectBtn.SetText("自動検出...")
+	detectBtn.SetToolTipText("キーを1回送って前後の画面を比べ、変化した範囲（ページ）を提案します")
+	detectBtn.Clicked().Attach(func() {
+		title := focusCombo.Text()
+		if title == "(なし)" {
+			title = ""
+		}
+		if keyEdit.Text() != "" && !showConfirm("範囲の自動検出", "対象アプリを前面にしてキー「"+keyEdit.Text()+"」を1回送り、変化した範囲を検出します（ページが1つ進みます）。よろしいですか？") {
+			return
+		}
+		reg, err := detectRegion(dlg, keyEdit.Text(), title, time.Duration(delayEdit.Value())*time.Millisecond)
+		if err != nil {
+			showError(err.Error())
+			return
+		}
+		if reg, ok := ConfirmRegion(reg); ok {
+			settings.Region = reg
+			regionLabel.SetText(strconv.Itoa(reg.Width) + " x " + strconv.Itoa(reg.Height))
+		}
+	})
+	relativeCheck, _ = walk.NewCheckBox(regionComp)
+	relativeCheck.SetText("ウィンドウ基準")
+	relativeCheck.SetChecked(settings.RegionRelative)
+	relativeCheck.SetToolTipText("範囲を「フォーカスするアプリ」のウィンドウからの相対位置で記録し、ウィンドウを動かしても同じ部分を撮ります")
 
 	// 保存フォルダ
 	folderComp, _ := walk.NewComposite(dlg)
@@ -103,6 +203,18 @@ func RunSettingsDialog() (Settings, bool) {
 			folderEdit.SetText(path)
 		}
 	})
+	if l, err := walk.NewLabel(folderComp); err == nil {
+		l.SetText("保存形式:")
+	}
+	formatCombo, _ = walk.NewComboBox(folderComp)
+	formatCombo.SetModel(imageFormatNames)
+	formatCombo.SetCurrentIndex(0)
+	for i, f := range imageFormats {
+		if f == settings.ImageFormat {
+			formatCombo.SetCurrentIndex(i)
+		}
+	}
+	formatCombo.SetToolTipText("JPEG は小さく保存できますが、細かい文字がにじみます。PNG・TIFF は劣化しません。PNG（256 色）は文字や図のページを小さく保存します")
 
 	// キー操作（フォーカス時にキーを押すとそのキーで設定される）
 	keyComp, _ := walk.NewComposite(dlg)
@@ -128,6 +240,35 @@ func RunSettingsDialog() (Settings, bool) {
 		l.SetText(" (欄をクリックしてキーを押すと設定)")
 	}
 
+	// ページがめくれなかったときの再送
+	retryComp, _ := walk.NewComposite(dlg)
+	retryComp.SetLayout(walk.NewHBoxLayout())
+	if l, err := walk.NewLabel(retryComp); err == nil {
+		l.SetText("めくれない時の再送回数 (0=確認しない):")
+	}
+	turnRetriesEdit, _ = walk.NewNumberEdit(retryComp)
+	turnRetriesEdit.SetRange(0, 10)
+	turnRetriesEdit.SetValue(float64(settings.TurnRetries))
+	turnRetriesEdit.SetToolTipText("キー送信後の画面が直前と同一なら、キーを送り直します")
+	if l, err := walk.NewLabel(retryComp); err == nil {
+		l.SetText("再送キー:")
+	}
+	altKeyEdit, _ = walk.NewLineEdit(retryComp)
+	altKeyEdit.SetReadOnly(true)
+	altKeyEdit.SetToolTipText("空ならキー操作と同じキーを送ります。欄をクリックしてキーを押すと設定、Delete で消去")
+	altKeyEdit.KeyDown().Attach(func(key walk.Key) {
+		if key == walk.KeyReturn {
+			return
+		}
+		if key == walk.KeyDelete || key == walk.KeyBack {
+			altKeyEdit.SetText("")
+			return
+		}
+		if s := keyOperationString(walk.ModifiersDown(), key); s != "" {
+			altKeyEdit.SetText(s)
+		}
+	})
+
 	// フォーカスするアプリケーション
 	focusComp, _ := walk.NewComposite(dlg)
 	focusComp.SetLayout(walk.NewHBoxLayout())
@@ -165,10 +306,174 @@ func RunSettingsDialog() (Settings, bool) {
 	}
 	maxCountEdit, _ = walk.NewNumberEdit(endComp)
 	maxCountEdit.SetRange(0, 99999)
-	maxCountEdit.SetValue(float64(settings.MaxCount))
-	stopThreeCheck, _ = walk.NewCheckBox(endComp)
-	stopThreeCheck.SetText("3枚連続同一で終了")
-	stopThreeCheck.SetChecked(settings.StopOnThreeSame)
+	maxCountEdit.SetValue(float64(settings.Stop.MaxCount))
+	if l, err := walk.NewLabel(endComp); err == nil {
+		l.SetText("連続同一の枚数 (0=無効):")
+	}
+	sameFramesEdit, _ = walk.NewNumberEdit(endComp)
+	sameFramesEdit.SetRange(0, 100)
+	sameFramesEdit.SetValue(float64(settings.Stop.SameFrames))
+
+	endComp2, _ := walk.NewComposite(dlg)
+	endComp2.SetLayout(walk.NewHBoxLayout())
+	if l, err := walk.NewLabel(endComp2); err == nil {
+		l.SetText("経過時間(秒):")
+	}
+	elapsedEdit, _ = walk.NewNumberEdit(endComp2)
+	elapsedEdit.SetRange(0, 86400)
+	elapsedEdit.SetValue(float64(settings.Stop.MaxElapsedSec))
+	if l, err := walk.NewLabel(endComp2); err == nil {
+		l.SetText("書き込み量(MB):")
+	}
+	bytesEdit, _ = walk.NewNumberEdit(endComp2)
+	bytesEdit.SetRange(0, 1000000)
+	bytesEdit.SetValue(float64(settings.Stop.MaxBytesMB))
+	if l, err := walk.NewLabel(endComp2); err == nil {
+		l.SetText("キャプチャ失敗の連続回数:")
+	}
+	errorStreakEdit, _ = walk.NewNumberEdit(endComp2)
+	errorStreakEdit.SetRange(0, 100)
+	errorStreakEdit.SetValue(float64(settings.Stop.ErrorStreak))
+
+	endScreenComp, _ := walk.NewComposite(dlg)
+	endScreenComp.SetLayout(walk.NewHBoxLayout())
+	if l, err := walk.NewLabel(endScreenComp); err == nil {
+		l.SetText("終了画面:")
+	}
+	endScreenEdit, _ = walk.NewLineEdit(endScreenComp)
+	endScreenEdit.SetText(settings.Stop.EndScreenPath)
+	endScreenEdit.SetToolTipText("この画像と同一の画面がキャプチャされたら終了します（空なら使いません）")
+	endScreenBtn, _ := walk.NewPushButton(endScreenComp)
+	endScreenBtn.SetText("参照...")
+	endScreenBtn.Clicked().Attach(func() {
+		fd := walk.FileDialog{
+			Title:  "終了画面の画像を選択",
+			Filter: "画像 (*.jpg;*.jpeg;*.png)|*.jpg;*.jpeg;*.png",
+		}
+		if ok, err := fd.ShowOpen(dlg); err == nil && ok {
+			endScreenEdit.SetText(fd.FilePath)
+		}
+	})
+	if l, err := walk.NewLabel(endScreenComp); err == nil {
+		l.SetText("組み合わせ:")
+	}
+	combineCombo, _ = walk.NewComboBox(endScreenComp)
+	combineCombo.SetModel([]string{"いずれかで終了", "すべて満たしたら終了"})
+	if settings.Stop.Combine == stop.CombineAll {
+		combineCombo.SetCurrentIndex(1)
+	} else {
+		combineCombo.SetCurrentIndex(0)
+	}
+
+	// 条件式
+	exprHelp := "使える変数: count, sameStreak, elapsedSec, bytes, errorStreak, distance（例: count >= 300 || sameStreak >= 5）"
+	exprComp, _ := walk.NewComposite(dlg)
+	exprComp.SetLayout(walk.NewHBoxLayout())
+	if l, err := walk.NewLabel(exprComp); err == nil {
+		l.SetText("終了条件の式:")
+	}
+	stopExprEdit, _ = walk.NewLineEdit(exprComp)
+	stopExprEdit.SetText(settings.Stop.Expr)
+	stopExprEdit.SetToolTipText(exprHelp)
+	if l, err := walk.NewLabel(exprComp); err == nil {
+		l.SetText("スキップ条件の式:")
+	}
+	skipExprEdit, _ = walk.NewLineEdit(exprComp)
+	skipExprEdit.SetText(settings.SkipExpr)
+	skipExprEdit.SetToolTipText("真になったフレームは保存しません。" + exprHelp)
+
+	// 章の区切り（PDF のしおり）
+	chapterComp, _ := walk.NewComposite(dlg)
+	chapterComp.SetLayout(walk.NewHBoxLayout())
+	if l, err := walk.NewLabel(chapterComp); err == nil {
+		l.SetText("章の区切りのキー:")
+	}
+	chapterKeyEdit, _ = walk.NewLineEdit(chapterComp)
+	chapterKeyEdit.SetReadOnly(true)
+	chapterKeyEdit.SetText(settings.ChapterKey)
+	chapterKeyEdit.SetToolTipText("実行中に押すと、次に保存するページを章の始まりにします。キーは対象アプリにも届くため、使わないキー（F9 など）を選んでください。欄をクリックしてキーを押すと設定、Delete で消去")
+	chapterKeyEdit.KeyDown().Attach(func(key walk.Key) {
+		if key == walk.KeyReturn {
+			return
+		}
+		if key == walk.KeyDelete || key == walk.KeyBack {
+			chapterKeyEdit.SetText("")
+			return
+		}
+		if s := keyOperationString(walk.ModifiersDown(), key); s != "" {
+			chapterKeyEdit.SetText(s)
+		}
+	})
+	if l, err := walk.NewLabel(chapterComp); err == nil {
+		l.SetText("章の区切りの式:")
+	}
+	chapterExprEdit, _ = walk.NewLineEdit(chapterComp)
+	chapterExprEdit.SetText(settings.ChapterExpr)
+	chapterExprEdit.SetToolTipText("真になったページを章の始まりにします。count は保存するページの番号です（例: count % 20 == 1）。" + exprHelp)
+	if l, err := walk.NewLabel(chapterComp); err == nil {
+		l.SetText("章の名前:")
+	}
+	chapterTitleEdit, _ = walk.NewLineEdit(chapterComp)
+	chapterTitleEdit.SetText(settings.ChapterTitle)
+	chapterTitleEdit.SetToolTipText("{n} は章の番号です。空なら「第{n}章」。名前やページ番号の表示は、終了後に出力フォルダの chapters.txt を編集して変えられます")
+
+	// 同一判定
+	compareComp, _ := walk.NewComposite(dlg)
+	compareComp.SetLayout(walk.NewHBoxLayout())
+	if l, err := walk.NewLabel(compareComp); err == nil {
+		l.SetText("同一判定:")
+	}
+	compareCombo, _ = walk.NewComboBox(compareComp)
+	compareCombo.SetModel(compareModeNames)
+	compareCombo.SetCurrentIndex(0)
+	if l, err := walk.NewLabel(compareComp); err == nil {
+		l.SetText("許容距離 (0〜64):")
+	}
+	toleranceEdit, _ = walk.NewNumberEdit(compareComp)
+	toleranceEdit.SetRange(0, 64)
+	toleranceEdit.SetValue(float64(settings.CompareTolerance))
+	toleranceEdit.SetToolTipText("知覚ハッシュ（aHash/dHash/pHash）でこの距離以下なら同一とみなします。完全一致では使いません。")
+
+	// 比較から除外する範囲
+	maskComp, _ := walk.NewComposite(dlg)
+	maskComp.SetLayout(walk.NewHBoxLayout())
+	if l, err := walk.NewLabel(maskComp); err == nil {
+		l.SetText("比較から除外:")
+	}
+	maskLabel, _ = walk.NewLabel(maskComp)
+	updateMaskLabel := func() {
+		if len(settings.IgnoreMasks) == 0 {
+			maskLabel.SetText("(なし)")
+		} else {
+			maskLabel.SetText(strconv.Itoa(len(settings.IgnoreMasks)) + " 箇所")
+		}
+	}
+	updateMaskLabel()
+	addMaskBtn, _ := walk.NewPushButton(maskComp)
+	addMaskBtn.SetText("除外範囲を追加...")
+	addMaskBtn.SetToolTipText("ページ番号や時計など、毎回変わる部分を選択すると同一判定で無視します")
+	addMaskBtn.Clicked().Attach(func() {
+		if settings.Region.Width <= 0 || settings.Region.Height <= 0 {
+			showError("先にキャプチャ範囲を選択してください。")
+			return
+		}
+		reg, ok := SelectRegion()
+		if !ok {
+			return
+		}
+		if m, ok := relativeMask(settings.Region, reg); ok {
+			settings.IgnoreMasks = append(settings.IgnoreMasks, m)
+			updateMaskLabel()
+		} else {
+			showError("除外範囲はキャプチャ範囲の内側を選択してください。")
+		}
+	})
+	clearMaskBtn, _ := walk.NewPushButton(maskComp)
+	clearMaskBtn.SetText("クリア")
+	clearMaskBtn.Clicked().Attach(func() {
+		settings.IgnoreMasks = nil
+		updateMaskLabel()
+	})
 
 	// 待機時間
 	delayComp, _ := walk.NewComposite(dlg)
@@ -180,6 +485,190 @@ func RunSettingsDialog() (Settings, bool) {
 	delayEdit.SetRange(0, 10000)
 	delayEdit.SetValue(float64(settings.DelayMsAfterKey))
 
+	stableComp, _ := walk.NewComposite(dlg)
+	stableComp.SetLayout(walk.NewHBoxLayout())
+	if l, err := walk.NewLabel(stableComp); err == nil {
+		l.SetText("安定待ち 連続一致枚数 (0=固定待機):")
+	}
+	stableFramesEdit, _ = walk.NewNumberEdit(stableComp)
+	stableFramesEdit.SetRange(0, 20)
+	stableFramesEdit.SetValue(float64(settings.StableFrames))
+	stableFramesEdit.SetToolTipText("キー送信後、画面がこの枚数連続で一致するまでキャプチャを繰り返し、落ち着いた画面だけを保存します")
+	if l, err := walk.NewLabel(stableComp); err == nil {
+		l.SetText("最小(ms):")
+	}
+	stableMinEdit, _ = walk.NewNumberEdit(stableComp)
+	stableMinEdit.SetRange(0, 10000)
+	stableMinEdit.SetValue(float64(settings.StableMinMs))
+	if l, err := walk.NewLabel(stableComp); err == nil {
+		l.SetText("最大(ms):")
+	}
+	stableMaxEdit, _ = walk.NewNumberEdit(stableComp)
+	stableMaxEdit.SetRange(0, 60000)
+	stableMaxEdit.SetValue(float64(settings.StableMaxMs))
+
+	// 空白ページ
+	blankComp, _ := walk.NewComposite(dlg)
+	blankComp.SetLayout(walk.NewHBoxLayout())
+	if l, err := walk.NewLabel(blankComp); err == nil {
+		l.SetText("空白ページ:")
+	}
+	blankCombo, _ = walk.NewComboBox(blankComp)
+	blankCombo.SetModel(blankActionNames)
+	blankCombo.SetCurrentIndex(0)
+	for i, a := range blankActions {
+		if a == settings.BlankAction {
+			blankCombo.SetCurrentIndex(i)
+		}
+	}
+	blankCombo.SetToolTipText("白い画面や区切りページを検出します。空白ページは同一フレームの連続に数えません")
+	pdfSkipBlankCheck, _ = walk.NewCheckBox(blankComp)
+	pdfSkipBlankCheck.SetText("PDF から空白ページを除く")
+	pdfSkipBlankCheck.SetChecked(settings.PDFSkipBlank)
+
+	// 重複ページ
+	dedupComp, _ := walk.NewComposite(dlg)
+	dedupComp.SetLayout(walk.NewHBoxLayout())
+	if l, err := walk.NewLabel(dedupComp); err == nil {
+		l.SetText("重複ページ（終了後に全体から検出）:")
+	}
+	dedupCombo, _ = walk.NewComboBox(dedupComp)
+	dedupCombo.SetModel(dedupActionNames)
+	dedupCombo.SetCurrentIndex(0)
+	for i, a := range dedupActions {
+		if a == settings.DedupAction {
+			dedupCombo.SetCurrentIndex(i)
+		}
+	}
+	dedupCombo.SetToolTipText("同一判定の設定（許容距離・除外範囲）で、前に出たページと同じページを探します。結果は " + dedup.ReportFileName + " に書き出します")
+
+	// 見開きなどのページ分割
+	splitComp, _ := walk.NewComposite(dlg)
+	splitComp.SetLayout(walk.NewHBoxLayout())
+	if l, err := walk.NewLabel(splitComp); err == nil {
+		l.SetText("ページ分割:")
+	}
+	splitCombo, _ = walk.NewComboBox(splitComp)
+	splitCombo.SetModel(splitModeNames)
+	splitCombo.SetCurrentIndex(0)
+	for i, m := range splitModes {
+		if m == settings.SplitMode {
+			splitCombo.SetCurrentIndex(i)
+		}
+	}
+	splitCombo.SetToolTipText("見開きで表示したキャプチャを、1ページずつの画像に分けて保存します（screenshot_00001_1.jpg …）")
+	if l, err := walk.NewLabel(splitComp); err == nil {
+		l.SetText("列:")
+	}
+	splitColsEdit, _ = walk.NewNumberEdit(splitComp)
+	splitColsEdit.SetDecimals(0)
+	splitColsEdit.SetRange(1, 8)
+	splitColsEdit.SetValue(float64(max(settings.SplitCols, 2)))
+	if l, err := walk.NewLabel(splitComp); err == nil {
+		l.SetText("行:")
+	}
+	splitRowsEdit, _ = walk.NewNumberEdit(splitComp)
+	splitRowsEdit.SetDecimals(0)
+	splitRowsEdit.SetRange(1, 8)
+	splitRowsEdit.SetValue(float64(max(settings.SplitRows, 1)))
+	if l, err := walk.NewLabel(splitComp); err == nil {
+		l.SetText("読み順:")
+	}
+	orderCombo, _ = walk.NewComboBox(splitComp)
+	orderCombo.SetModel(readingOrderNames)
+	orderCombo.SetCurrentIndex(0)
+	for i, o := range readingOrders {
+		if o == settings.ReadingOrder {
+			orderCombo.SetCurrentIndex(i)
+		}
+	}
+	orderCombo.SetToolTipText("縦書きの本やマンガは「右から左」にすると、右のページから順に番号を付けます")
+
+	// 余白の切り取り
+	trimComp, _ := walk.NewComposite(dlg)
+	trimComp.SetLayout(walk.NewHBoxLayout())
+	if l, err := walk.NewLabel(trimComp); err == nil {
+		l.SetText("余白の切り取り（終了後）:")
+	}
+	trimCombo, _ = walk.NewComboBox(trimComp)
+	trimCombo.SetModel(trimModeNames)
+	trimCombo.SetCurrentIndex(0)
+	for i, m := range trimModes {
+		if m == settings.TrimMode {
+			trimCombo.SetCurrentIndex(i)
+		}
+	}
+	trimCombo.SetToolTipText("ページの周りに写ったビューアーの背景を切り取ります。「全ページ同じ範囲」ならページの大きさがそろいます")
+	if l, err := walk.NewLabel(trimComp); err == nil {
+		l.SetText("背景色の許容差:")
+	}
+	trimToleranceEdit, _ = walk.NewNumberEdit(trimComp)
+	trimToleranceEdit.SetDecimals(0)
+	trimToleranceEdit.SetRange(1, 255)
+	trimToleranceEdit.SetValue(float64(trim.DefaultTolerance))
+	if settings.TrimTolerance > 0 {
+		trimToleranceEdit.SetValue(float64(settings.TrimTolerance))
+	}
+	trimToleranceEdit.SetToolTipText("外周で最も多い色からこの差（0〜255）までを背景とみなします")
+
+	// マルチページ TIFF
+	tiffComp, _ := walk.NewComposite(dlg)
+	tiffComp.SetLayout(walk.NewHBoxLayout())
+	tiffCheck, _ = walk.NewCheckBox(tiffComp)
+	tiffCheck.SetText("マルチページ TIFF にも書き出す")
+	tiffCheck.SetChecked(settings.ExportTIFF)
+	tiffCheck.SetToolTipText("PDF と同じページを同じ順で1つの TIFF ファイルにまとめます")
+	if l, err := walk.NewLabel(tiffComp); err == nil {
+		l.SetText("圧縮:")
+	}
+	tiffCombo, _ = walk.NewComboBox(tiffComp)
+	tiffCombo.SetModel(tiffCompressionNames)
+	tiffCombo.SetCurrentIndex(0)
+	for i, c := range tiffCompressions {
+		if c == settings.TIFFCompression {
+			tiffCombo.SetCurrentIndex(i)
+		}
+	}
+	tiffCombo.SetToolTipText("G4 はページを白黒にするため、文字だけのページに向きます")
+	if l, err := walk.NewLabel(tiffComp); err == nil {
+		l.SetText("解像度 (DPI):")
+	}
+	tiffDPIEdit, _ = walk.NewNumberEdit(tiffComp)
+	tiffDPIEdit.SetDecimals(0)
+	tiffDPIEdit.SetRange(1, 2400)
+	tiffDPIEdit.SetValue(96)
+	if settings.TIFFDPI > 0 {
+		tiffDPIEdit.SetValue(float64(settings.TIFFDPI))
+	}
+
+	// タブレット・電子書籍リーダー向け
+	bookComp, _ := walk.NewComposite(dlg)
+	bookComp.SetLayout(walk.NewHBoxLayout())
+	cbzCheck, _ = walk.NewCheckBox(bookComp)
+	cbzCheck.SetText("CBZ にも書き出す")
+	cbzCheck.SetChecked(settings.ExportCBZ)
+	cbzCheck.SetToolTipText("コミックビューアー向けに、ページの画像と ComicInfo.xml を1つのファイルにまとめます")
+	epubCheck, _ = walk.NewCheckBox(bookComp)
+	epubCheck.SetText("EPUB にも書き出す")
+	epubCheck.SetChecked(settings.ExportEPUB)
+	epubCheck.SetToolTipText("電子書籍リーダー向けに、1ページ1画面の固定レイアウト EPUB にします。読む向きは「読み順」に合わせます")
+
+	// スクロール連結
+	stitchComp, _ := walk.NewComposite(dlg)
+	stitchComp.SetLayout(walk.NewHBoxLayout())
+	stitchCheck, _ = walk.NewCheckBox(stitchComp)
+	stitchCheck.SetText("スクロールキャプチャを縦につなげる")
+	stitchCheck.SetChecked(settings.StitchMode)
+	stitchCheck.SetToolTipText("PageDown などでスクロールしたときの重なりを検出し、1枚の縦長画像にします")
+	if l, err := walk.NewLabel(stitchComp); err == nil {
+		l.SetText("ページ比率 高さ/幅 (0=分割しない):")
+	}
+	stitchAspectEdit, _ = walk.NewNumberEdit(stitchComp)
+	stitchAspectEdit.SetDecimals(3)
+	stitchAspectEdit.SetRange(0, 10)
+	stitchAspectEdit.SetValue(settings.StitchPageAspect)
+	stitchAspectEdit.SetToolTipText("A4 縦なら 1.414。縦長画像をこの比率のページに分割して PDF にします")
+
 	// PDFタイトル
 	pdfTitleComp, _ := walk.NewComposite(dlg)
 	pdfTitleComp.SetLayout(walk.NewHBoxLayout())
@@ -188,7 +677,117 @@ func RunSettingsDialog() (Settings, bool) {
 	}
 	pdfTitleEdit, _ = walk.NewLineEdit(pdfTitleComp)
 	pdfTitleEdit.SetText(settings.PDFTitle)
-	pdfTitleEdit.SetToolTipText("PDFのメタデータタイトル。")
+	pdfTitleEdit.SetToolTipText("PDFのメタデータタイトル。" + placeholderHelp)
+
+	// PDF のプロパティ
+	pdfMetaComp, _ := walk.NewComposite(dlg)
+	pdfMetaComp.SetLayout(walk.NewHBoxLayout())
+	if l, err := walk.NewLabel(pdfMetaComp); err == nil {
+		l.SetText("作成者:")
+	}
+	pdfAuthorEdit, _ = walk.NewLineEdit(pdfMetaComp)
+	pdfAuthorEdit.SetText(settings.PDFAuthor)
+	pdfAuthorEdit.SetToolTipText(placeholderHelp)
+	if l, err := walk.NewLabel(pdfMetaComp); err == nil {
+		l.SetText("サブタイトル:")
+	}
+	pdfSubjectEdit, _ = walk.NewLineEdit(pdfMetaComp)
+	pdfSubjectEdit.SetText(settings.PDFSubject)
+	pdfSubjectEdit.SetToolTipText("PDF の件名（Subject）です。" + placeholderHelp)
+	if l, err := walk.NewLabel(pdfMetaComp); err == nil {
+		l.SetText("キーワード:")
+	}
+	pdfKeywordsEdit, _ = walk.NewLineEdit(pdfMetaComp)
+	pdfKeywordsEdit.SetText(settings.PDFKeywords)
+	pdfKeywordsEdit.SetToolTipText("カンマ区切りで指定します。" + placeholderHelp)
+
+	pdfMeta2Comp, _ := walk.NewComposite(dlg)
+	pdfMeta2Comp.SetLayout(walk.NewHBoxLayout())
+	if l, err := walk.NewLabel(pdfMeta2Comp); err == nil {
+		l.SetText("作成アプリ:")
+	}
+	pdfCreatorEdit, _ = walk.NewLineEdit(pdfMeta2Comp)
+	pdfCreatorEdit.SetText(settings.PDFCreator)
+	pdfCreatorEdit.SetToolTipText("PDF の作成アプリケーション（Creator）です。" + placeholderHelp)
+	if l, err := walk.NewLabel(pdfMeta2Comp); err == nil {
+		l.SetText("独自の項目:")
+	}
+	pdfInfoEdit, _ = walk.NewLineEdit(pdfMeta2Comp)
+	pdfInfoEdit.SetText(formatInfo(settings.PDFInfo))
+	pdfInfoEdit.SetToolTipText("PDF の XMP メタデータに加える項目を「名前=値」のセミコロン区切りで指定します（例: Department=総務部; DocType=議事録）。名前は文字・数字・_ - . だけです。" + placeholderHelp)
+	xmpCheck, _ = walk.NewCheckBox(pdfMeta2Comp)
+	xmpCheck.SetText("XMP メタデータも埋め込む")
+	xmpCheck.SetChecked(settings.PDFXMP)
+
+	// PDF の表示
+	pdfViewComp, _ := walk.NewComposite(dlg)
+	pdfViewComp.SetLayout(walk.NewHBoxLayout())
+	if l, err := walk.NewLabel(pdfViewComp); err == nil {
+		l.SetText("PDF の表示:")
+	}
+	pdfLayoutCombo, _ = walk.NewComboBox(pdfViewComp)
+	pdfLayoutCombo.SetModel(pdfLayoutNames)
+	pdfLayoutCombo.SetCurrentIndex(0)
+	for i, l := range pdfLayouts {
+		if l == settings.PDFPageLayout {
+			pdfLayoutCombo.SetCurrentIndex(i)
+		}
+	}
+	pdfLayoutCombo.SetToolTipText("PDF を開いたときのページの並べ方です。「読み順」が右から左なら、見開きも右から左に並べます")
+	coverCheck, _ = walk.NewCheckBox(pdfViewComp)
+	coverCheck.SetText("見開きで表紙を単独で表示する")
+	coverCheck.SetChecked(settings.PDFCoverAlone)
+
+	// PDF のページの大きさ
+	pdfPageComp, _ := walk.NewComposite(dlg)
+	pdfPageComp.SetLayout(walk.NewHBoxLayout())
+	if l, err := walk.NewLabel(pdfPageComp); err == nil {
+		l.SetText("PDF のページ:")
+	}
+	pdfPageSizeCombo, _ = walk.NewComboBox(pdfPageComp)
+	pdfPageSizeCombo.SetModel(pdfPageSizeNames)
+	pdfPageSizeCombo.SetCurrentIndex(0)
+	for i, m := range pdfPageSizes {
+		if m == settings.PDFPageSize && (m != output.PDFSizeNUp || pdfPageNUps[i] == settings.PDFNUp) {
+			pdfPageSizeCombo.SetCurrentIndex(i)
+		}
+	}
+	pdfPageSizeCombo.SetToolTipText("「画像の大きさ」は画像を DPI から求めた実際の大きさのページにします。" +
+		"「用紙に合わせる」は画像を余白の内側に収め、用紙の向きをページごとに画像に合わせます。" +
+		"「用紙にNページ」は配布資料の印刷用に、用紙1枚に複数のページを読み順に並べます")
+	if l, err := walk.NewLabel(pdfPageComp); err == nil {
+		l.SetText("用紙:")
+	}
+	paperCombo, _ = walk.NewComboBox(pdfPageComp)
+	paperCombo.SetModel(paperNames)
+	paperCombo.SetCurrentIndex(0)
+	for i, p := range papers {
+		if p == settings.PDFPaper {
+			paperCombo.SetCurrentIndex(i)
+		}
+	}
+	if l, err := walk.NewLabel(pdfPageComp); err == nil {
+		l.SetText("余白(mm):")
+	}
+	marginEdit, _ = walk.NewNumberEdit(pdfPageComp)
+	marginEdit.SetDecimals(0)
+	marginEdit.SetRange(0, 50)
+	marginEdit.SetValue(settings.PDFMarginMm)
+	if l, err := walk.NewLabel(pdfPageComp); err == nil {
+		l.SetText("解像度 (DPI):")
+	}
+	pdfDPIEdit, _ = walk.NewNumberEdit(pdfPageComp)
+	pdfDPIEdit.SetDecimals(0)
+	pdfDPIEdit.SetRange(1, 2400)
+	pdfDPIEdit.SetValue(96)
+	if settings.PDFDPI > 0 {
+		pdfDPIEdit.SetValue(float64(settings.PDFDPI))
+	}
+	pdfDPIEdit.SetToolTipText("「画像の大きさ」のページの解像度です（既定 96）")
+	screenDPICheck, _ = walk.NewCheckBox(pdfPageComp)
+	screenDPICheck.SetText("画面の DPI を使う")
+	screenDPICheck.SetChecked(settings.PDFScreenDPI)
+	screenDPICheck.SetToolTipText("キャプチャした画面の DPI（表示スケール 150% なら 144）を解像度にして、画面で見たときの大きさのページにします")
 
 	// ボタン
 	btnComp, _ := walk.NewComposite(dlg)
@@ -198,20 +797,104 @@ func RunSettingsDialog() (Settings, bool) {
 	startBtn.SetText("開始")
 	startBtn.Clicked().Attach(func() {
 		settings.OutputFolder = folderEdit.Text()
+		if i := formatCombo.CurrentIndex(); i >= 0 && i < len(imageFormats) {
+			settings.ImageFormat = imageFormats[i]
+		}
 		settings.KeyOperation = keyEdit.Text()
+		settings.AltKeyOperation = altKeyEdit.Text()
+		settings.TurnRetries = int(turnRetriesEdit.Value())
 		if t := focusCombo.Text(); t == "(なし)" || t == "" {
 			settings.FocusWindowTitle = ""
 		} else {
 			settings.FocusWindowTitle = t
 		}
-		settings.MaxCount = int(maxCountEdit.Value())
-		settings.StopOnThreeSame = stopThreeCheck.Checked()
+		settings.Stop.MaxCount = int(maxCountEdit.Value())
+		settings.Stop.SameFrames = int(sameFramesEdit.Value())
+		settings.Stop.MaxElapsedSec = int(elapsedEdit.Value())
+		settings.Stop.MaxBytesMB = int(bytesEdit.Value())
+		settings.Stop.ErrorStreak = int(errorStreakEdit.Value())
+		settings.Stop.EndScreenPath = endScreenEdit.Text()
+		settings.Stop.Expr = strings.TrimSpace(stopExprEdit.Text())
+		settings.SkipExpr = strings.TrimSpace(skipExprEdit.Text())
+		settings.ChapterKey = chapterKeyEdit.Text()
+		settings.ChapterExpr = strings.TrimSpace(chapterExprEdit.Text())
+		settings.ChapterTitle = strings.TrimSpace(chapterTitleEdit.Text())
+		if combineCombo.CurrentIndex() == 1 {
+			settings.Stop.Combine = stop.CombineAll
+		} else {
+			settings.Stop.Combine = stop.CombineAny
+		}
+		if i := compareCombo.CurrentIndex(); i >= 0 && i < len(compareModes) {
+			settings.CompareMode = string(compareModes[i])
+		}
+		settings.CompareTolerance = int(toleranceEdit.Value())
 		settings.DelayMsAfterKey = int(delayEdit.Value())
+		settings.StableFrames = int(stableFramesEdit.Value())
+		settings.StableMinMs = int(stableMinEdit.Value())
+		settings.StableMaxMs = int(stableMaxEdit.Value())
+		if i := blankCombo.CurrentIndex(); i >= 0 && i < len(blankActions) {
+			settings.BlankAction = blankActions[i]
+		}
+		settings.PDFSkipBlank = pdfSkipBlankCheck.Checked()
+		if i := dedupCombo.CurrentIndex(); i >= 0 && i < len(dedupActions) {
+			settings.DedupAction = dedupActions[i]
+		}
+		settings.StitchMode = stitchCheck.Checked()
+		settings.StitchPageAspect = stitchAspectEdit.Value()
+		if i := splitCombo.CurrentIndex(); i >= 0 && i < len(splitModes) {
+			settings.SplitMode = splitModes[i]
+		}
+		settings.SplitCols = int(splitColsEdit.Value())
+		settings.SplitRows = int(splitRowsEdit.Value())
+		if i := orderCombo.CurrentIndex(); i >= 0 && i < len(readingOrders) {
+			settings.ReadingOrder = readingOrders[i]
+		}
+		if i := trimCombo.CurrentIndex(); i >= 0 && i < len(trimModes) {
+			settings.TrimMode = trimModes[i]
+		}
+		settings.TrimTolerance = int(trimToleranceEdit.Value())
+		settings.ExportTIFF = tiffCheck.Checked()
+		if i := tiffCombo.CurrentIndex(); i >= 0 && i < len(tiffCompressions) {
+			settings.TIFFCompression = tiffCompressions[i]
+		}
+		settings.TIFFDPI = int(tiffDPIEdit.Value())
+		settings.ExportCBZ = cbzCheck.Checked()
+		settings.ExportEPUB = epubCheck.Checked()
+		if i := pdfLayoutCombo.CurrentIndex(); i >= 0 && i < len(pdfLayouts) {
+			settings.PDFPageLayout = pdfLayouts[i]
+		}
+		settings.PDFCoverAlone = coverCheck.Checked()
+		if i := pdfPageSizeCombo.CurrentIndex(); i >= 0 && i < len(pdfPageSizes) {
+			settings.PDFPageSize = pdfPageSizes[i]
+			if pdfPageNUps[i] > 0 {
+				settings.PDFNUp = pdfPageNUps[i]
+			}
+		}
+		if i := paperCombo.CurrentIndex(); i >= 0 && i < len(papers) {
+			settings.PDFPaper = papers[i]
+		}
+		settings.PDFMarginMm = marginEdit.Value()
+		settings.PDFDPI = int(pdfDPIEdit.Value())
+		settings.PDFScreenDPI = screenDPICheck.Checked()
 		if s := pdfTitleEdit.Text(); s != "" {
 			settings.PDFTitle = s
 		} else {
 			settings.PDFTitle = "screenshot-" + time.Now().Format("2006-01-02_15-04-05")
 		}
+		settings.PDFAuthor = strings.TrimSpace(pdfAuthorEdit.Text())
+		settings.PDFSubject = strings.TrimSpace(pdfSubjectEdit.Text())
+		settings.PDFKeywords = strings.TrimSpace(pdfKeywordsEdit.Text())
+		settings.PDFCreator = strings.TrimSpace(pdfCreatorEdit.Text())
+		settings.PDFXMP = xmpCheck.Checked()
+		info, err := parseInfo(pdfInfoEdit.Text())
+		if err == nil {
+			err = output.CheckPDFInfo(info)
+		}
+		if err != nil {
+			showError(err.Error())
+			return
+		}
+		settings.PDFInfo = info
 		// 必須項目のチェック（ダイアログを閉じる前に表示する）
 		if settings.Region.Width <= 0 || settings.Region.Height <= 0 {
 			showError("キャプチャ範囲を選択してください。「範囲を選択...」で範囲を指定してください。")
@@ -221,6 +904,36 @@ func RunSettingsDialog() (Settings, bool) {
 			showError("保存先フォルダを指定してください。")
 			return
 		}
+		// 式の誤りは実行の途中ではなく開始前に知らせる
+		for _, e := range []string{settings.Stop.Expr, settings.SkipExpr} {
+			if e == "" {
+				continue
+			}
+			if _, err := stop.ParseExpr(e); err != nil {
+				showError(err.Error())
+				return
+			}
+		}
+		// ウィンドウ基準なら、選択した範囲をそのウィンドウのクライアント領域からの相対座標にする
+		settings.RegionRelative = relativeCheck.Checked()
+		if settings.RegionRelative {
+			if settings.FocusWindowTitle == "" {
+				showError("ウィンドウ基準の範囲には「フォーカスするアプリ」を指定してください。")
+				return
+			}
+			w, ok := focus.FindByTitle(settings.FocusWindowTitle)
+			if !ok {
+				showError("ウィンドウが見つかりません: " + settings.FocusWindowTitle)
+				return
+			}
+			client, _ := focus.ClientRect(w)
+			rel, ok := session.RelativeRegion(settings.Region, client)
+			if !ok {
+				showError("キャプチャ範囲がウィンドウの内側にありません。ウィンドウの中の範囲を選択してください。")
+				return
+			}
+			settings.Region = rel
+		}
 		dlg.Accept()
 	})
 	cancelBtn, _ := walk.NewPushButton(btnComp)
@@ -246,6 +959,96 @@ func RunSettingsDialog() (Settings, bool) {
 	return settings, true
 }
 
+// detectRegion はダイアログを隠して対象のウィンドウ（無ければ画面全体）をキャプチャし、キーを1回送ってから
+// もう一度キャプチャして、変化した範囲をスクリーン座標で返します。
+func detectRegion(dlg *walk.Dialog, key, title string, delay time.Duration) (Region, error) {
+	if strings.TrimSpace(key) == "" {
+		return Region{}, fmt.Errorf("先に「キー操作」を設定してください。")
+	}
+	dlg.SetVisible(false)
+	defer dlg.SetVisible(true)
+
+	bx, by, bw, bh := virtualScreenBounds()
+	bounds := image.Rect(bx, by, bx+bw, by+bh)
+	if title != "" {
+		if w, ok := focus.FindByTitle(title); ok {
+			focus.SetForegroundByTitle(title)
+			if client, state := focus.ClientRect(w); state == focus.StateNormal && !client.Empty() {
+				bounds = client
+			}
+		}
+	}
+	time.Sleep(500 * time.Millisecond) // ダイアログが消え、ウィンドウが前面になるまで待つ
+	grab := func() (image.Image, error) {
+		return capture.Capture(capture.Region{X: bounds.Min.X, Y: bounds.Min.Y, Width: bounds.Dx(), Height: bounds.Dy()})
+	}
+	before, err := grab()
+	if err != nil {
+		return Region{}, fmt.Errorf("キャプチャに失敗しました: %v", err)
+	}
+	if err := keyboard.Send(key); err != nil {
+		return Region{}, fmt.Errorf("キー送信に失敗しました: %v", err)
+	}
+	time.Sleep(max(delay, 500*time.Millisecond))
+	after, err := grab()
+	if err != nil {
+		return Region{}, fmt.Errorf("キャプチャに失敗しました: %v", err)
+	}
+	r, ok := capture.DetectContent(before, after, capture.DetectOptions{})
+	if !ok {
+		return Region{}, fmt.Errorf("キーを送っても画面が変化しませんでした。キー操作と対象アプリを確認してください。")
+	}
+	r = r.Sub(after.Bounds().Min).Add(bounds.Min)
+	return Region{X: r.Min.X, Y: r.Min.Y, Width: r.Dx(), Height: r.Dy()}, nil
+}
+
+// relativeMask は画面座標で選択した mask を region の左上を原点とする座標に変換し、
+// region の内側に切り詰めて返します。重なりが無ければ false を返します。
+func relativeMask(region, mask Region) (Region, bool) {
+	x1, y1 := max(mask.X, region.X), max(mask.Y, region.Y)
+	x2 := min(mask.X+mask.Width, region.X+region.Width)
+	y2 := min(mask.Y+mask.Height, region.Y+region.Height)
+	if x2 <= x1 || y2 <= y1 {
+		return Region{}, false
+	}
+	return Region{X: x1 - region.X, Y: y1 - region.Y, Width: x2 - x1, Height: y2 - y1}, true
+}
+
+// placeholderHelp は PDF のタイトルとプロパティで使える置き換えの説明です。
+const placeholderHelp = "{date} は開始日、{count} はページ数、{window} はフォーカスするアプリに置き換えます。"
+
+// parseInfo は「名前=値」のセミコロン区切りを PDF の独自の項目にします。空なら nil を返します。
+func parseInfo(s string) (map[string]string, error) {
+	var info map[string]string
+	for _, f := range strings.Split(s, ";") {
+		if strings.TrimSpace(f) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(f, "=")
+		if !ok {
+			return nil, fmt.Errorf("独自の項目 %q は「名前=値」で指定してください", strings.TrimSpace(f))
+		}
+		if info == nil {
+			info = map[string]string{}
+		}
+		info[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return info, nil
+}
+
+// formatInfo は PDF の独自の項目を parseInfo で読める形にします。
+func formatInfo(info map[string]string) string {
+	keys := make([]string, 0, len(info))
+	for k := range info {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for i, k := range keys {
+		keys[i] = k + "=" + info[k]
+	}
+	return strings.Join(keys, "; ")
+}
+
 // showError はエラーメッセージをメッセージボックスで表示します。
 func showError(msg string) {
 	title, _ := syscall.UTF16PtrFromString("エラー")
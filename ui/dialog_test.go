@@ -0,0 +1,26 @@
+//go:build windows
+
+package ui
+
+import "testing"
+
+func TestRelativeMask(t *testing.T) {
+	region := Region{X: 100, Y: 50, Width: 200, Height: 100}
+	tests := []struct {
+		name string
+		mask Region
+		want Region
+		ok   bool
+	}{
+		{"内側", Region{X: 110, Y: 60, Width: 20, Height: 10}, Region{X: 10, Y: 10, Width: 20, Height: 10}, true},
+		{"はみ出した部分は切り詰める", Region{X: 280, Y: 40, Width: 50, Height: 30}, Region{X: 180, Y: 0, Width: 20, Height: 20}, true},
+		{"範囲全体", Region{X: 0, Y: 0, Width: 1000, Height: 1000}, Region{X: 0, Y: 0, Width: 200, Height: 100}, true},
+		{"外側", Region{X: 0, Y: 0, Width: 100, Height: 50}, Region{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := relativeMask(region, tt.mask)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("%s: relativeMask = %+v, %v, want %+v, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
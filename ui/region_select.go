@@ -7,124 +7,66 @@ import (
 	"syscall"
 	"unsafe"
 
+	"AutoScreenShot/session"
+
 	"github.com/kbinani/screenshot"
 	"github.com/lxn/win"
 )
 
 // Region はキャプチャ範囲を表します。
-type Region struct {
-	X, Y, Width, Height int
-}
+type Region = session.Region
 
 // SelectRegion は全画面オーバーレイを表示し、マウスドラッグで矩形を選択させます。
 // 選択された範囲と true を返します。Esc でキャンセルした場合は false を返します。
 func SelectRegion() (Region, bool) {
+	return selectRegion(nil)
+}
+
+// ConfirmRegion は提案された範囲 proposal を枠で示した全画面オーバーレイを表示します。
+// Enter でその範囲を採用し、ドラッグすると選び直せます。Esc でキャンセルした場合は false を返します。
+func ConfirmRegion(proposal Region) (Region, bool) {
+	return selectRegion(&proposal)
+}
+
+// selectState はオーバーレイ1回分の状態です。ウィンドウクラスは1度だけ登録するため、
+// ウィンドウプロシージャは呼び出しごとのクロージャではなく、この状態を通して結果を返します。
+type selectState struct {
+	mu                 sync.Mutex
+	bx, by             int     // オーバーレイの左上（スクリーン座標）
+	proposal           *Region // 提案された範囲（スクリーン座標。nil なら無し）
+	result             Region
+	ok                 bool
+	startX, startY     int
+	currentX, currentY int
+	dragging           bool
+}
+
+var (
+	selecting     *selectState
+	registerClass sync.Once
+)
+
+const wndClassName = "AutoScreenShotRegionSelect"
+
+func selectRegion(proposal *Region) (Region, bool) {
 	bx, by, bw, bh := virtualScreenBounds()
 	if bw <= 0 || bh <= 0 {
 		return Region{}, false
 	}
-
-	var result Region
-	var ok bool
-	var mu sync.Mutex
-
-	var startX, startY int
-	var currentX, currentY int
-	dragging := false
-
-	const wndClassName = "AutoScreenShotRegionSelect"
-
-	win.RegisterClassEx(&win.WNDCLASSEX{
-		CbSize: uint32(unsafe.Sizeof(win.WNDCLASSEX{})),
-		Style:  win.CS_HREDRAW | win.CS_VREDRAW,
-		LpfnWndProc: syscall.NewCallback(func(hwnd win.HWND, msg uint32, wParam, lParam uintptr) uintptr {
-			switch msg {
-			case win.WM_LBUTTONDOWN:
-				mu.Lock()
-				startX = int(win.LOWORD(uint32(lParam)))
-				startY = int(win.HIWORD(uint32(lParam)))
-				currentX = startX
-				currentY = startY
-				dragging = true
-				mu.Unlock()
-				win.InvalidateRect(hwnd, nil, true)
-				return 0
-			case win.WM_MOUSEMOVE:
-				if wParam&win.MK_LBUTTON != 0 {
-					mu.Lock()
-					currentX = int(win.LOWORD(uint32(lParam)))
-					currentY = int(win.HIWORD(uint32(lParam)))
-					mu.Unlock()
-					win.InvalidateRect(hwnd, nil, true)
-				}
-				return 0
-			case win.WM_LBUTTONUP:
-				mu.Lock()
-				if dragging {
-					ex := int(win.LOWORD(uint32(lParam)))
-					ey := int(win.HIWORD(uint32(lParam)))
-					x1, x2 := startX, ex
-					if x1 > x2 {
-						x1, x2 = x2, x1
-					}
-					y1, y2 := startY, ey
-					if y1 > y2 {
-						y1, y2 = y2, y1
-					}
-					w := x2 - x1
-					h := y2 - y1
-					if w > 2 && h > 2 {
-						result = Region{X: x1 + bx, Y: y1 + by, Width: w, Height: h}
-						ok = true
-					}
-					dragging = false
-					win.PostQuitMessage(0)
-				}
-				mu.Unlock()
-				return 0
-			case win.WM_KEYDOWN:
-				if wParam == win.VK_ESCAPE {
-					win.PostQuitMessage(0)
-					return 0
-				}
-				return 0
-			case win.WM_PAINT:
-				var ps win.PAINTSTRUCT
-				hdc := win.BeginPaint(hwnd, &ps)
-				if hdc != 0 {
-					mu.Lock()
-					sx, sy := startX, startY
-					cx, cy := currentX, currentY
-					dr := dragging
-					mu.Unlock()
-					if dr {
-						x1, x2 := sx, cx
-						if x1 > x2 {
-							x1, x2 = x2, x1
-						}
-						y1, y2 := sy, cy
-						if y1 > y2 {
-							y1, y2 = y2, y1
-						}
-						pen := createPen(win.PS_SOLID, 3, uint32(win.RGB(255, 0, 0)))
-						oldPen := win.SelectObject(hdc, win.HGDIOBJ(pen))
-						win.SelectObject(hdc, win.GetStockObject(win.NULL_BRUSH))
-						win.Rectangle_(hdc, int32(x1), int32(y1), int32(x2), int32(y2))
-						win.SelectObject(hdc, oldPen)
-						win.DeleteObject(win.HGDIOBJ(pen))
-					}
-				}
-				win.EndPaint(hwnd, &ps)
-				return 0
-			case win.WM_DESTROY:
-				return 0
-			}
-			return win.DefWindowProc(hwnd, msg, wParam, lParam)
-		}),
-		HInstance:     win.GetModuleHandle(nil),
-		LpszClassName: syscall.StringToUTF16Ptr(wndClassName),
-		HCursor:       win.LoadCursor(0, win.MAKEINTRESOURCE(win.IDC_CROSS)),
-		HbrBackground: win.HBRUSH(win.COLOR_WINDOW + 1),
+	st := &selectState{bx: bx, by: by, proposal: proposal}
+	selecting = st
+	defer func() { selecting = nil }()
+
+	registerClass.Do(func() {
+		win.RegisterClassEx(&win.WNDCLASSEX{
+			CbSize:        uint32(unsafe.Sizeof(win.WNDCLASSEX{})),
+			Style:         win.CS_HREDRAW | win.CS_VREDRAW,
+			LpfnWndProc:   syscall.NewCallback(selectWndProc),
+			HInstance:     win.GetModuleHandle(nil),
+			LpszClassName: syscall.StringToUTF16Ptr(wndClassName),
+			HCursor:       win.LoadCursor(0, win.MAKEINTRESOURCE(win.IDC_CROSS)),
+			HbrBackground: win.HBRUSH(win.COLOR_WINDOW + 1),
+		})
 	})
 
 	hwnd := win.CreateWindowEx(
@@ -139,6 +81,7 @@ func SelectRegion() (Region, bool) {
 		return Region{}, false
 	}
 	setLayeredWindowAttributes(hwnd, 0, 180, 0x2) // LWA_ALPHA = 0x2
+	win.SetForegroundWindow(hwnd)
 
 	var msg win.MSG
 	for win.GetMessage(&msg, 0, 0, 0) != 0 {
@@ -148,7 +91,114 @@ func SelectRegion() (Region, bool) {
 
 	win.DestroyWindow(hwnd)
 
-	return result, ok
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.result, st.ok
+}
+
+func selectWndProc(hwnd win.HWND, msg uint32, wParam, lParam uintptr) uintptr {
+	st := selecting
+	if st == nil {
+		return win.DefWindowProc(hwnd, msg, wParam, lParam)
+	}
+	switch msg {
+	case win.WM_LBUTTONDOWN:
+		st.mu.Lock()
+		st.startX = int(win.LOWORD(uint32(lParam)))
+		st.startY = int(win.HIWORD(uint32(lParam)))
+		st.currentX = st.startX
+		st.currentY = st.startY
+		st.dragging = true
+		st.mu.Unlock()
+		win.InvalidateRect(hwnd, nil, true)
+		return 0
+	case win.WM_MOUSEMOVE:
+		if wParam&win.MK_LBUTTON != 0 {
+			st.mu.Lock()
+			st.currentX = int(win.LOWORD(uint32(lParam)))
+			st.currentY = int(win.HIWORD(uint32(lParam)))
+			st.mu.Unlock()
+			win.InvalidateRect(hwnd, nil, true)
+		}
+		return 0
+	case win.WM_LBUTTONUP:
+		st.mu.Lock()
+		if st.dragging {
+			ex := int(win.LOWORD(uint32(lParam)))
+			ey := int(win.HIWORD(uint32(lParam)))
+			x1, x2 := st.startX, ex
+			if x1 > x2 {
+				x1, x2 = x2, x1
+			}
+			y1, y2 := st.startY, ey
+			if y1 > y2 {
+				y1, y2 = y2, y1
+			}
+			w := x2 - x1
+			h := y2 - y1
+			if w > 2 && h > 2 {
+				st.result = Region{X: x1 + st.bx, Y: y1 + st.by, Width: w, Height: h}
+				st.ok = true
+			}
+			st.dragging = false
+			win.PostQuitMessage(0)
+		}
+		st.mu.Unlock()
+		return 0
+	case win.WM_KEYDOWN:
+		switch wParam {
+		case win.VK_ESCAPE:
+			win.PostQuitMessage(0)
+		case win.VK_RETURN:
+			// 提案された範囲をそのまま採用する
+			st.mu.Lock()
+			if st.proposal != nil {
+				st.result, st.ok = *st.proposal, true
+				win.PostQuitMessage(0)
+			}
+			st.mu.Unlock()
+		}
+		return 0
+	case win.WM_PAINT:
+		var ps win.PAINTSTRUCT
+		hdc := win.BeginPaint(hwnd, &ps)
+		if hdc != 0 {
+			st.mu.Lock()
+			sx, sy := st.startX, st.startY
+			cx, cy := st.currentX, st.currentY
+			dr := st.dragging
+			p := st.proposal
+			st.mu.Unlock()
+			switch {
+			case dr:
+				drawFrame(hdc, sx, sy, cx, cy, win.RGB(255, 0, 0))
+			case p != nil:
+				x, y := p.X-st.bx, p.Y-st.by
+				drawFrame(hdc, x, y, x+p.Width, y+p.Height, win.RGB(0, 160, 255))
+			}
+		}
+		win.EndPaint(hwnd, &ps)
+		return 0
+	case win.WM_DESTROY:
+		return 0
+	}
+	return win.DefWindowProc(hwnd, msg, wParam, lParam)
+}
+
+// drawFrame は (x1, y1)-(x2, y2) を対角とする矩形の枠を描きます。
+func drawFrame(hdc win.HDC, x1, y1, x2, y2 int, color win.COLORREF) {
+	if x1 > x2 {
+		x1, x2 = x2, x1
+	}
+	if y1 > y2 {
+		y1, y2 = y2, y1
+	}
+	pen := createPen(win.PS_SOLID, 3, uint32(color))
+	oldPen := win.SelectObject(hdc, win.HGDIOBJ(pen))
+	win.SelectObject(hdc, win.GetStockObject(win.NULL_BRUSH))
+	win.Rectangle_(hdc, int32(x1), int32(y1), int32(x2), int32(y2))
+	win.SelectObject(hdc, oldPen)
+	win.DeleteObject(win.HGDIOBJ(pen))
 }
 
 func virtualScreenBounds() (x, y, width, height int) {